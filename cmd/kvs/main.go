@@ -43,55 +43,12 @@ func buildLogger(conf config.Config) (*zap.Logger, error) {
 	return logConfig.Build()
 }
 
-func newMapDictionary(conf config.Config) (storages.DataDictionary, error) {
-	memoryPool := storages.NewMemoryPool(conf.PreAllocated())
-
-	pool, err := storages.NewDataPool(memoryPool)
-	if err != nil {
-		return nil, err
+func initStorages(conf config.Config, memoryPool storages.MemoryPool) (storages.DataDictionary, error) {
+	if conf.MaxMemory() > 0 && conf.MaxMemory() < storages.MinMemory(conf.Mode(), conf.PreAllocated()) {
+		return nil, storages.ErrMemoryFloor
 	}
 
-	return storages.NewMapDictionary(pool), nil
-}
-
-func newSyncMapDictionary(conf config.Config) (storages.DataDictionary, error) {
-	memoryPool := storages.NewMemoryPool(conf.PreAllocated())
-
-	pool, err := storages.NewDataPool(memoryPool)
-	if err != nil {
-		return nil, err
-	}
-
-	return storages.NewSyncMapDictionary(pool), nil
-}
-
-func initStorages(conf config.Config) (dictionary storages.DataDictionary, err error) {
-	switch conf.Mode() {
-	case config.StorageModeMap:
-		return newMapDictionary(conf)
-
-	case config.StorageModeSyncMap:
-		return newSyncMapDictionary(conf)
-
-	case config.StorageModePartitionedMap:
-		return storages.NewPartitionedDictionary(
-			storages.DefaultPartitionNum,
-			storages.DefaultPartitionMask,
-			func() (storages.DataDictionary, error) {
-				return newMapDictionary(conf)
-			},
-		)
-	case config.StorageModePartitionedSyncMap:
-		return storages.NewPartitionedDictionary(
-			storages.DefaultPartitionNum,
-			storages.DefaultPartitionMask,
-			func() (storages.DataDictionary, error) {
-				return newSyncMapDictionary(conf)
-			},
-		)
-	}
-
-	return
+	return storages.NewDataDictionary(conf.Mode(), memoryPool, conf.TimeSource())
 }
 
 func main() {
@@ -115,23 +72,53 @@ func main() {
 		zap.String(config.LOGLEVEL, string(conf.LogLevel())),
 		zap.Int(config.PORT, conf.Port()),
 		zap.Duration(config.EXPIRATION, conf.Expiration()),
+		zap.Duration(config.MAXTTL, conf.MaxTTL()),
 		zap.Duration(config.MAINTENANCE, conf.Maintenance()),
 		zap.Int(config.PREALLOCATED, conf.PreAllocated()),
+		zap.Int64(config.MAXMEMORY, conf.MaxMemory()),
+		zap.String(config.EVICTION, string(conf.EvictionPolicy())),
+		zap.Float64(config.COMPACTION, conf.CompactionRatio()),
 		zap.String(config.MODE, string(conf.Mode())),
+		zap.String(config.KEYSPREFIX, conf.KeysPrefix()),
+		zap.Bool(config.ROOTKEYS, conf.RootKeys()),
+		zap.Int(config.BINARYPORT, conf.BinaryPort()),
+		zap.Int(config.BINARYCONNS, conf.BinaryMaxConns()),
+		zap.Int(config.REDISPORT, conf.RedisPort()),
+		zap.Int(config.REDISCONNS, conf.RedisMaxConns()),
+		zap.Int(config.MEMCACHEPORT, conf.MemcachedPort()),
+		zap.Int(config.MEMCACHECONS, conf.MemcachedMaxConns()),
+		zap.Int(config.GRPCPORT, conf.GRPCPort()),
+		zap.String(config.SNAPSHOT, conf.SnapshotPath()),
+		zap.Duration(config.SNAPSHOTINT, conf.SnapshotInterval()),
+		zap.String(config.JOURNAL, conf.JournalPath()),
+		zap.String(config.JOURNALFSYNC, string(conf.JournalFsync())),
 	)
 
 	logger.Info("init: data dictionary")
 
-	dictionary, err := initStorages(conf)
+	memoryPool := storages.NewMemoryPool(conf.PreAllocated(), conf.MaxMemory())
+
+	dictionary, err := initStorages(conf, memoryPool)
 	if err != nil {
-		logger.Fatal("failed to init data dictionary")
+		logger.Fatal("failed to init data dictionary",
+			zap.Error(err),
+		)
 	}
 
 	logger.Info("init: storages")
 
-	storages, err := storages.NewInMemStorages(
+	var journal storages.Journal
+
+	appendOnlyLog := storages.NewAppendOnlyLog(conf)
+
+	if conf.JournalPath() != "" {
+		journal = appendOnlyLog
+	}
+
+	dataStorages, err := storages.NewInMemStorages(
 		conf,
 		dictionary,
+		journal,
 	)
 	if err != nil {
 		logger.Fatal("failed to init data pool",
@@ -139,12 +126,27 @@ func main() {
 		)
 	}
 
+	var maintenance []server.Maintenance
+
+	snapshot := storages.NewSnapshot(conf, dataStorages)
+
+	if conf.SnapshotPath() != "" {
+		maintenance = append(maintenance, snapshot)
+	}
+
+	if conf.JournalPath() != "" {
+		maintenance = append(maintenance, appendOnlyLog)
+	}
+
+	buckets := storages.NewBuckets(conf, dataStorages, dictionary, memoryPool)
+
 	logger.Info("init: server")
 
 	srv := server.NewServer(
 		logger,
 		conf,
-		storages,
+		buckets,
+		maintenance...,
 	)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -160,6 +162,7 @@ func main() {
 		cancel()
 	}()
 
+	// the server is started before restoring to answer probes of health and readiness
 	go func() {
 		logger.Info("start: server")
 
@@ -172,11 +175,100 @@ func main() {
 		}
 	}()
 
+	if conf.SnapshotPath() != "" {
+		logger.Info("init: restore snapshot")
+
+		restored, err := snapshot.Load()
+		if err != nil {
+			logger.Fatal("failed to restore snapshot",
+				zap.Error(err),
+			)
+		}
+
+		logger.Info("restored",
+			zap.Int("records", restored),
+		)
+	}
+
+	if conf.JournalPath() != "" {
+		logger.Info("init: replay journal")
+
+		replayed, err := appendOnlyLog.Replay(dataStorages)
+		if err != nil {
+			logger.Fatal("failed to replay journal",
+				zap.Error(err),
+			)
+		}
+
+		logger.Info("replayed",
+			zap.Int("entries", replayed),
+		)
+
+		err = appendOnlyLog.Open(dataStorages)
+		if err != nil {
+			logger.Fatal("failed to open journal",
+				zap.Error(err),
+			)
+		}
+	}
+
+	if conf.SnapshotPath() != "" || conf.JournalPath() != "" {
+		logger.Info("init: restore buckets")
+
+		restored, err := buckets.Restore()
+		if err != nil {
+			logger.Fatal("failed to restore buckets",
+				zap.Error(err),
+			)
+		}
+
+		logger.Info("restored buckets",
+			zap.Int("records", restored),
+		)
+	}
+
+	logger.Info("ready: server")
+
+	srv.Ready()
+
 	<-ctx.Done()
 
 	logger.Info("stop: server")
 
 	srv.Stop()
 
+	if conf.SnapshotPath() != "" {
+		logger.Info("save: snapshot")
+
+		err := snapshot.Save(context.Background())
+		if err != nil {
+			logger.Error("failed to save snapshot",
+				zap.Error(err),
+			)
+		}
+	}
+
+	if conf.SnapshotPath() != "" || conf.JournalPath() != "" {
+		logger.Info("close: buckets")
+
+		err := buckets.Close()
+		if err != nil {
+			logger.Error("failed to close buckets",
+				zap.Error(err),
+			)
+		}
+	}
+
+	if conf.JournalPath() != "" {
+		logger.Info("close: journal")
+
+		err := appendOnlyLog.Close()
+		if err != nil {
+			logger.Error("failed to close journal",
+				zap.Error(err),
+			)
+		}
+	}
+
 	logger.Info("finish")
 }
@@ -3,9 +3,12 @@ module github.com/7phs/kvs
 go 1.15
 
 require (
+	github.com/golang/protobuf v1.4.3
 	github.com/minio/highwayhash v1.0.1
-	github.com/stretchr/testify v1.4.0
+	github.com/stretchr/testify v1.5.1
 	github.com/valyala/fasthttp v1.16.0
 	go.uber.org/zap v1.16.0
 	golang.org/x/sync v0.0.0-20190423024810-112230192c58
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.25.0
 )
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"os"
 	"strconv"
 	"strings"
@@ -11,16 +13,53 @@ const (
 	LOGLEVEL     = "LOG_LEVEL"
 	PORT         = "PORT"
 	EXPIRATION   = "EXPIRATION"
+	MAXTTL       = "MAX_TTL"
 	MAINTENANCE  = "MAINTENANCE"
 	PREALLOCATED = "PREALLOCATED"
+	MAXMEMORY    = "MAX_MEMORY"
 	MODE         = "STORAGE_MODE"
+	HASHSEED     = "HASH_SEED"
+	SNAPSHOT     = "SNAPSHOT_PATH"
+	SNAPSHOTINT  = "SNAPSHOT_INTERVAL"
+	JOURNAL      = "JOURNAL_PATH"
+	JOURNALFSYNC = "JOURNAL_FSYNC"
+	EVICTION     = "EVICTION_POLICY"
+	COMPACTION   = "COMPACTION_RATIO"
+	KEYSPREFIX   = "KEYS_PREFIX"
+	ROOTKEYS     = "ROOT_KEYS"
+	BINARYPORT   = "BINARY_PORT"
+	BINARYCONNS  = "BINARY_MAX_CONNS"
+	REDISPORT    = "REDIS_PORT"
+	REDISCONNS   = "REDIS_MAX_CONNS"
+	MEMCACHEPORT = "MEMCACHED_PORT"
+	MEMCACHECONS = "MEMCACHED_MAX_CONNS"
+	GRPCPORT     = "GRPC_PORT"
+
+	HashSeedSize = 32
 
 	defaultLogLevel     = LogLevelInfo
 	defaultPort         = 9889
 	defaultExpiration   = 30 * time.Minute
+	defaultMaxTTL       = 0
 	defaultMaintenance  = 10 * time.Minute
 	defaultPreAllocated = 1024 * 1024
+	defaultMaxMemory    = 0
 	defaultStorageMode  = StorageModePartitionedMap
+	defaultSnapshot     = ""
+	defaultSnapshotInt  = 10 * time.Minute
+	defaultJournal      = ""
+	defaultFsyncPolicy  = FsyncEverySecond
+	defaultEviction     = EvictionNone
+	defaultCompaction   = 0.5
+	defaultKeysPrefix   = "/v1/kv"
+	defaultRootKeys     = true
+	defaultBinaryPort   = 0
+	defaultBinaryConns  = 1024
+	defaultRedisPort    = 0
+	defaultRedisConns   = 1024
+	defaultMemcachePort = 0
+	defaultMemcacheCons = 1024
+	defaultGRPCPort     = 0
 )
 
 const (
@@ -30,6 +69,20 @@ const (
 	StorageModePartitionedSyncMap StorageMode = "partitioned-sync-map"
 )
 
+const (
+	FsyncAlways      FsyncPolicy = "always"
+	FsyncEverySecond FsyncPolicy = "everysec"
+	FsyncNever       FsyncPolicy = "never"
+)
+
+const (
+	EvictionNone        EvictionPolicy = "noeviction"
+	EvictionLRU         EvictionPolicy = "lru"
+	EvictionLFU         EvictionPolicy = "lfu"
+	EvictionRandom      EvictionPolicy = "random"
+	EvictionVolatileTTL EvictionPolicy = "volatile-ttl"
+)
+
 const (
 	LogLevelDebug   LogLevel = "DEBUG"
 	LogLevelInfo    LogLevel = "INFO"
@@ -41,6 +94,10 @@ type StorageMode string
 
 type LogLevel string
 
+type FsyncPolicy string
+
+type EvictionPolicy string
+
 type TimeSource interface {
 	Now() time.Time
 }
@@ -55,20 +112,56 @@ type Config interface {
 	LogLevel() LogLevel
 	Port() int
 	Expiration() time.Duration
+	MaxTTL() time.Duration
 	Maintenance() time.Duration
 	Mode() StorageMode
 	PreAllocated() int
+	MaxMemory() int64
+	EvictionPolicy() EvictionPolicy
+	CompactionRatio() float64
+	KeysPrefix() string
+	RootKeys() bool
+	BinaryPort() int
+	BinaryMaxConns() int
+	RedisPort() int
+	RedisMaxConns() int
+	MemcachedPort() int
+	MemcachedMaxConns() int
+	GRPCPort() int
 	TimeSource() TimeSource
+	HashSeed() [HashSeedSize]byte
+	SnapshotPath() string
+	SnapshotInterval() time.Duration
+	JournalPath() string
+	JournalFsync() FsyncPolicy
 }
 
 type EnvConfig struct {
 	logLevel    LogLevel
 	port        int
 	expiration  time.Duration
+	maxTTL      time.Duration
 	maintenance time.Duration
 	mode        StorageMode
 	preAllocted int
+	maxMemory   int64
+	eviction    EvictionPolicy
+	compaction  float64
+	keysPrefix  string
+	rootKeys    bool
+	binaryPort  int
+	binaryConns int
+	redisPort   int
+	redisConns  int
+	memcachePrt int
+	memcacheCns int
+	grpcPort    int
 	timeSource  TimeSource
+	hashSeed    [HashSeedSize]byte
+	snapshot    string
+	snapshotInt time.Duration
+	journal     string
+	fsync       FsyncPolicy
 }
 
 func NewConfigFromEnv() (Config, error) {
@@ -82,6 +175,11 @@ func NewConfigFromEnv() (Config, error) {
 		return nil, err
 	}
 
+	maxTTL, err := getDurationOr(MAXTTL, defaultMaxTTL)
+	if err != nil {
+		return nil, err
+	}
+
 	maintenance, err := getDurationOr(MAINTENANCE, defaultMaintenance)
 	if err != nil {
 		return nil, err
@@ -92,14 +190,92 @@ func NewConfigFromEnv() (Config, error) {
 		return nil, err
 	}
 
+	maxMemory, err := getInt64Or(MAXMEMORY, defaultMaxMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	compaction, err := getFloatOr(COMPACTION, defaultCompaction)
+	if err != nil {
+		return nil, err
+	}
+
+	rootKeys, err := getBoolOr(ROOTKEYS, defaultRootKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	binaryPort, err := getIntOr(BINARYPORT, defaultBinaryPort)
+	if err != nil {
+		return nil, err
+	}
+
+	binaryConns, err := getIntOr(BINARYCONNS, defaultBinaryConns)
+	if err != nil {
+		return nil, err
+	}
+
+	redisPort, err := getIntOr(REDISPORT, defaultRedisPort)
+	if err != nil {
+		return nil, err
+	}
+
+	redisConns, err := getIntOr(REDISCONNS, defaultRedisConns)
+	if err != nil {
+		return nil, err
+	}
+
+	memcachePort, err := getIntOr(MEMCACHEPORT, defaultMemcachePort)
+	if err != nil {
+		return nil, err
+	}
+
+	memcacheConns, err := getIntOr(MEMCACHECONS, defaultMemcacheCons)
+	if err != nil {
+		return nil, err
+	}
+
+	grpcPort, err := getIntOr(GRPCPORT, defaultGRPCPort)
+	if err != nil {
+		return nil, err
+	}
+
+	hashSeed, err := getHashSeed(HASHSEED)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotInt, err := getDurationOr(SNAPSHOTINT, defaultSnapshotInt)
+	if err != nil {
+		return nil, err
+	}
+
 	return &EnvConfig{
 		logLevel:    parseLogLevel(),
 		port:        port,
 		expiration:  expiration,
+		maxTTL:      maxTTL,
 		maintenance: maintenance,
 		mode:        parseMode(),
 		preAllocted: preAllocated,
+		maxMemory:   maxMemory,
+		eviction:    parseEvictionPolicy(),
+		compaction:  compaction,
+		keysPrefix:  parseKeysPrefix(),
+		rootKeys:    rootKeys,
+		binaryPort:  binaryPort,
+		binaryConns: binaryConns,
+		redisPort:   redisPort,
+		redisConns:  redisConns,
+		memcachePrt: memcachePort,
+		memcacheCns: memcacheConns,
+		grpcPort:    grpcPort,
 		timeSource:  systemTime{},
+		hashSeed:    hashSeed,
+		snapshot:    getStringOr(SNAPSHOT, defaultSnapshot),
+		snapshotInt: snapshotInt,
+		journal:     getStringOr(JOURNAL, defaultJournal),
+		fsync:       parseFsyncPolicy(),
 	}, nil
 }
 
@@ -115,6 +291,10 @@ func (o *EnvConfig) Expiration() time.Duration {
 	return o.expiration
 }
 
+func (o *EnvConfig) MaxTTL() time.Duration {
+	return o.maxTTL
+}
+
 func (o *EnvConfig) Maintenance() time.Duration {
 	return o.maintenance
 }
@@ -127,10 +307,78 @@ func (o *EnvConfig) PreAllocated() int {
 	return o.preAllocted
 }
 
+func (o *EnvConfig) MaxMemory() int64 {
+	return o.maxMemory
+}
+
+func (o *EnvConfig) EvictionPolicy() EvictionPolicy {
+	return o.eviction
+}
+
+func (o *EnvConfig) CompactionRatio() float64 {
+	return o.compaction
+}
+
+func (o *EnvConfig) KeysPrefix() string {
+	return o.keysPrefix
+}
+
+func (o *EnvConfig) RootKeys() bool {
+	return o.rootKeys
+}
+
+func (o *EnvConfig) BinaryPort() int {
+	return o.binaryPort
+}
+
+func (o *EnvConfig) BinaryMaxConns() int {
+	return o.binaryConns
+}
+
+func (o *EnvConfig) RedisPort() int {
+	return o.redisPort
+}
+
+func (o *EnvConfig) RedisMaxConns() int {
+	return o.redisConns
+}
+
+func (o *EnvConfig) MemcachedPort() int {
+	return o.memcachePrt
+}
+
+func (o *EnvConfig) MemcachedMaxConns() int {
+	return o.memcacheCns
+}
+
+func (o *EnvConfig) GRPCPort() int {
+	return o.grpcPort
+}
+
 func (o *EnvConfig) TimeSource() TimeSource {
 	return o.timeSource
 }
 
+func (o *EnvConfig) HashSeed() [HashSeedSize]byte {
+	return o.hashSeed
+}
+
+func (o *EnvConfig) SnapshotPath() string {
+	return o.snapshot
+}
+
+func (o *EnvConfig) SnapshotInterval() time.Duration {
+	return o.snapshotInt
+}
+
+func (o *EnvConfig) JournalPath() string {
+	return o.journal
+}
+
+func (o *EnvConfig) JournalFsync() FsyncPolicy {
+	return o.fsync
+}
+
 func getIntOr(key string, defV int) (int, error) {
 	v := os.Getenv(key)
 	if v == "" {
@@ -140,6 +388,33 @@ func getIntOr(key string, defV int) (int, error) {
 	return strconv.Atoi(v)
 }
 
+func getInt64Or(key string, defV int64) (int64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return defV, nil
+	}
+
+	return strconv.ParseInt(v, 10, 64)
+}
+
+func getFloatOr(key string, defV float64) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return defV, nil
+	}
+
+	return strconv.ParseFloat(v, 64)
+}
+
+func getBoolOr(key string, defV bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return defV, nil
+	}
+
+	return strconv.ParseBool(v)
+}
+
 func getDurationOr(key string, defV time.Duration) (time.Duration, error) {
 	v := os.Getenv(key)
 	if v == "" {
@@ -158,6 +433,24 @@ func getStringOr(key string, defV string) string {
 	return v
 }
 
+func getHashSeed(key string) (seed [HashSeedSize]byte, err error) {
+	v := os.Getenv(key)
+	if v == "" {
+		_, err = rand.Read(seed[:])
+
+		return seed, err
+	}
+
+	decoded, err := hex.DecodeString(v)
+	if err != nil || len(decoded) != HashSeedSize {
+		return seed, ErrInvalidHashSeed
+	}
+
+	copy(seed[:], decoded)
+
+	return seed, nil
+}
+
 func parseLogLevel() LogLevel {
 	level := LogLevel(strings.ToUpper(getStringOr(LOGLEVEL, string(defaultLogLevel))))
 
@@ -174,14 +467,65 @@ func parseLogLevel() LogLevel {
 
 func parseMode() StorageMode {
 	mode := StorageMode(getStringOr(MODE, string(defaultStorageMode)))
+	if !mode.Valid() {
+		return defaultStorageMode
+	}
+
+	return mode
+}
 
-	switch mode {
+func (o StorageMode) Valid() bool {
+	switch o {
 	case StorageModeMap,
 		StorageModeSyncMap,
 		StorageModePartitionedMap,
 		StorageModePartitionedSyncMap:
-		return mode
+		return true
 	default:
-		return defaultStorageMode
+		return false
+	}
+}
+
+func parseFsyncPolicy() FsyncPolicy {
+	policy := FsyncPolicy(strings.ToLower(getStringOr(JOURNALFSYNC, string(defaultFsyncPolicy))))
+
+	switch policy {
+	case FsyncAlways,
+		FsyncEverySecond,
+		FsyncNever:
+		return policy
+	default:
+		return defaultFsyncPolicy
+	}
+}
+
+func parseEvictionPolicy() EvictionPolicy {
+	policy := EvictionPolicy(strings.ToLower(getStringOr(EVICTION, string(defaultEviction))))
+	if !policy.Valid() {
+		return defaultEviction
 	}
+
+	return policy
+}
+
+func (o EvictionPolicy) Valid() bool {
+	switch o {
+	case EvictionNone,
+		EvictionLRU,
+		EvictionLFU,
+		EvictionRandom,
+		EvictionVolatileTTL:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseKeysPrefix() string {
+	prefix := strings.Trim(getStringOr(KEYSPREFIX, defaultKeysPrefix), "/")
+	if prefix == "" {
+		return ""
+	}
+
+	return "/" + prefix
 }
@@ -1 +1,11 @@
 package config
+
+const (
+	ErrInvalidHashSeed Error = "invalid_hash_seed"
+)
+
+type Error string
+
+func (o Error) Error() string {
+	return string(o)
+}
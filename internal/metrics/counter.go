@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"bufio"
+	"sync"
+	"sync/atomic"
+)
+
+type Counter struct {
+	desc
+
+	lock   sync.RWMutex
+	series series
+	counts map[string]*uint64
+}
+
+func (o *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc: desc{
+			name:   name,
+			help:   help,
+			kind:   typeCounter,
+			labels: labels,
+		},
+		counts: make(map[string]*uint64),
+	}
+
+	o.register(c)
+
+	return c
+}
+
+func (o *Counter) Inc(values ...string) {
+	atomic.AddUint64(o.count(values), 1)
+}
+
+func (o *Counter) count(values []string) *uint64 {
+	key := joinValues(values)
+
+	o.lock.RLock()
+	count, ok := o.counts[key]
+	o.lock.RUnlock()
+
+	if ok {
+		return count
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if count, ok = o.counts[key]; !ok {
+		count = new(uint64)
+		o.counts[key] = count
+		o.series.add(key, values)
+	}
+
+	return count
+}
+
+func (o *Counter) write(w *bufio.Writer) {
+	o.writeHeader(w)
+
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	for _, key := range o.series.keys {
+		o.writeSample(w, "", o.series.values[key], "", "", float64(atomic.LoadUint64(o.counts[key])))
+	}
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"bufio"
+)
+
+type SampleFunc func(v float64, values ...string)
+
+type Func struct {
+	desc
+
+	collect func(sample SampleFunc)
+}
+
+func (o *Registry) GaugeFunc(name, help string, fn func(sample SampleFunc), labels ...string) {
+	o.register(&Func{
+		desc: desc{
+			name:   name,
+			help:   help,
+			kind:   typeGauge,
+			labels: labels,
+		},
+		collect: fn,
+	})
+}
+
+func (o *Registry) CounterFunc(name, help string, fn func(sample SampleFunc), labels ...string) {
+	o.register(&Func{
+		desc: desc{
+			name:   name,
+			help:   help,
+			kind:   typeCounter,
+			labels: labels,
+		},
+		collect: fn,
+	})
+}
+
+func (o *Func) write(w *bufio.Writer) {
+	o.writeHeader(w)
+
+	o.collect(func(v float64, values ...string) {
+		o.writeSample(w, "", values, "", "", v)
+	})
+}
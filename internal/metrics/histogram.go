@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sync"
+)
+
+type Histogram struct {
+	desc
+
+	buckets []float64
+
+	lock   sync.RWMutex
+	series series
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	sync.Mutex
+
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+var DefaultBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5, 10}
+
+func (o *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc: desc{
+			name:   name,
+			help:   help,
+			kind:   typeHistogram,
+			labels: labels,
+		},
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+
+	o.register(h)
+
+	return h
+}
+
+func (o *Histogram) Observe(v float64, values ...string) {
+	value := o.value(values)
+
+	value.Lock()
+	defer value.Unlock()
+
+	for i, bound := range o.buckets {
+		if v <= bound {
+			value.counts[i]++
+		}
+	}
+
+	value.count++
+	value.sum += v
+}
+
+func (o *Histogram) value(values []string) *histogramValue {
+	key := joinValues(values)
+
+	o.lock.RLock()
+	value, ok := o.values[key]
+	o.lock.RUnlock()
+
+	if ok {
+		return value
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if value, ok = o.values[key]; !ok {
+		value = &histogramValue{
+			counts: make([]uint64, len(o.buckets)),
+		}
+		o.values[key] = value
+		o.series.add(key, values)
+	}
+
+	return value
+}
+
+func (o *Histogram) write(w *bufio.Writer) {
+	o.writeHeader(w)
+
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	for _, key := range o.series.keys {
+		var (
+			labels = o.series.values[key]
+			value  = o.values[key]
+		)
+
+		value.Lock()
+
+		for i, bound := range o.buckets {
+			o.writeSample(w, "_bucket", labels, "le", formatFloat(bound), float64(value.counts[i]))
+		}
+
+		o.writeSample(w, "_bucket", labels, "le", formatFloat(math.Inf(1)), float64(value.count))
+		o.writeSample(w, "_sum", labels, "", "", value.sum)
+		o.writeSample(w, "_count", labels, "", "", float64(value.count))
+
+		value.Unlock()
+	}
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+
+	labelSeparator = "\xff"
+)
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+type Registry struct {
+	sync.Mutex
+
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (o *Registry) register(c collector) {
+	o.Lock()
+	defer o.Unlock()
+
+	o.collectors = append(o.collectors, c)
+}
+
+func (o *Registry) Write(w io.Writer) error {
+	o.Lock()
+	collectors := append([]collector(nil), o.collectors...)
+	o.Unlock()
+
+	buf := bufio.NewWriter(w)
+
+	for _, c := range collectors {
+		c.write(buf)
+	}
+
+	return buf.Flush()
+}
+
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (o *desc) writeHeader(w *bufio.Writer) {
+	w.WriteString("# HELP ")
+	w.WriteString(o.name)
+	w.WriteByte(' ')
+	w.WriteString(escapeHelp(o.help))
+	w.WriteString("\n# TYPE ")
+	w.WriteString(o.name)
+	w.WriteByte(' ')
+	w.WriteString(o.kind)
+	w.WriteByte('\n')
+}
+
+func (o *desc) writeSample(w *bufio.Writer, suffix string, values []string, extra, extraValue string, v float64) {
+	w.WriteString(o.name)
+	w.WriteString(suffix)
+
+	if len(values) > 0 || extra != "" {
+		w.WriteByte('{')
+
+		for i, value := range values {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+
+			writeLabel(w, o.labels[i], value)
+		}
+
+		if extra != "" {
+			if len(values) > 0 {
+				w.WriteByte(',')
+			}
+
+			writeLabel(w, extra, extraValue)
+		}
+
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	w.WriteString(escapeLabel(value))
+	w.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+type series struct {
+	keys   []string
+	values map[string][]string
+}
+
+func (o *series) add(key string, values []string) {
+	if o.values == nil {
+		o.values = make(map[string][]string)
+	}
+
+	o.values[key] = append([]string(nil), values...)
+	o.keys = append(o.keys, key)
+	sort.Strings(o.keys)
+}
+
+func joinValues(values []string) string {
+	return strings.Join(values, labelSeparator)
+}
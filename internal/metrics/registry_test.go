@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	registry := NewRegistry()
+
+	requests := registry.Counter("requests_total", "Number of requests.", "method", "status")
+	requests.Inc("GET", "200")
+	requests.Inc("GET", "200")
+	requests.Inc("POST", "507")
+
+	duration := registry.Histogram("duration_seconds", "Duration.", []float64{0.1, 1}, "method")
+	duration.Observe(0.05, "GET")
+	duration.Observe(0.5, "GET")
+
+	registry.GaugeFunc("keys", "Number of \"keys\".\nStored.", func(sample SampleFunc) {
+		sample(3, "0")
+		sample(4, "1")
+	}, "partition")
+
+	var buf bytes.Buffer
+
+	require.NoError(t, registry.Write(&buf))
+	assert.Equal(t, `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 2
+requests_total{method="POST",status="507"} 1
+# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{method="GET",le="0.1"} 1
+duration_seconds_bucket{method="GET",le="1"} 2
+duration_seconds_bucket{method="GET",le="+Inf"} 2
+duration_seconds_sum{method="GET"} 0.55
+duration_seconds_count{method="GET"} 2
+# HELP keys Number of "keys".\nStored.
+# TYPE keys gauge
+keys{partition="0"} 3
+keys{partition="1"} 4
+`, buf.String())
+}
+
+func TestRegistry_EscapeLabel(t *testing.T) {
+	registry := NewRegistry()
+
+	registry.Counter("total", "Total.", "path").Inc("a\"b\\c\nd")
+
+	var buf bytes.Buffer
+
+	require.NoError(t, registry.Write(&buf))
+	assert.Contains(t, buf.String(), `total{path="a\"b\\c\nd"} 1`)
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/binary"
+	"net/http"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/7phs/kvs/pkg/protocol"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	pathBatchGet = "/_batch/get"
+	pathBatchSet = "/_batch/set"
+
+	contentTypeBatch = "application/x-kvs-batch"
+
+	maxBatchItems = 10000
+	maxTTLMillis  = int64(1<<63-1) / int64(time.Millisecond)
+)
+
+type batchReader struct {
+	buf []byte
+	err error
+}
+
+func (o *batchReader) more() bool {
+	return o.err == nil && len(o.buf) > 0
+}
+
+func (o *batchReader) uvarint() uint64 {
+	if o.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(o.buf)
+	if n <= 0 {
+		o.err = ErrBadRequest
+		return 0
+	}
+
+	o.buf = o.buf[n:]
+
+	return v
+}
+
+func (o *batchReader) bytes() []byte {
+	sz := o.uvarint()
+	if o.err != nil {
+		return nil
+	}
+
+	if sz > uint64(len(o.buf)) {
+		o.err = ErrBadRequest
+		return nil
+	}
+
+	v := o.buf[:sz:sz]
+	o.buf = o.buf[sz:]
+
+	return v
+}
+
+func parseBatchGet(body []byte) ([][]byte, error) {
+	var (
+		r    = batchReader{buf: body}
+		keys [][]byte
+	)
+
+	for r.more() {
+		if len(keys) == maxBatchItems {
+			return nil, ErrBadRequest
+		}
+
+		keys = append(keys, r.bytes())
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return keys, nil
+}
+
+func parseBatchSet(body []byte) ([]storages.BatchItem, error) {
+	var (
+		r     = batchReader{buf: body}
+		items []storages.BatchItem
+	)
+
+	for r.more() {
+		if len(items) == maxBatchItems {
+			return nil, ErrBadRequest
+		}
+
+		key := r.bytes()
+		ttl := r.uvarint()
+		value := r.bytes()
+
+		if ttl > uint64(maxTTLMillis) {
+			return nil, ErrBadRequest
+		}
+
+		items = append(items, storages.BatchItem{
+			Key:   key,
+			Value: value,
+			TTL:   time.Duration(ttl) * time.Millisecond,
+		})
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return items, nil
+}
+
+func (o *DefaultServer) batchGetHandler(ctx *fasthttp.RequestCtx) {
+	storage, ok := o.batchStorages(ctx)
+	if !ok {
+		return
+	}
+
+	keys, err := parseBatchGet(ctx.Request.Body())
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	values, errs := storage.GetBatch(keys)
+
+	var (
+		buf  []byte
+		size [binary.MaxVarintLen64]byte
+	)
+
+	for i := range values {
+		status := protocolStatus(errs[i])
+		buf = append(buf, byte(status))
+
+		if status == protocol.StatusOK {
+			value := values[i].Bytes()
+			n := binary.PutUvarint(size[:], uint64(len(value)))
+			buf = append(append(buf, size[:n]...), value...)
+
+			values[i].Free()
+		}
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType(contentTypeBatch)
+	ctx.SetBody(buf)
+}
+
+func (o *DefaultServer) batchSetHandler(ctx *fasthttp.RequestCtx) {
+	storage, ok := o.batchStorages(ctx)
+	if !ok {
+		return
+	}
+
+	items, err := parseBatchSet(ctx.Request.Body())
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	errs := storage.AddBatch(items)
+
+	buf := make([]byte, len(errs))
+	for i, err := range errs {
+		buf[i] = byte(protocolStatus(err))
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType(contentTypeBatch)
+	ctx.SetBody(buf)
+}
+
+func (o *DefaultServer) batchStorages(ctx *fasthttp.RequestCtx) (storages.Storages, bool) {
+	if string(ctx.Method()) != http.MethodPost {
+		ctx.Error("Unsupported method", fasthttp.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	if o.isStarting() {
+		ctx.Error("Not ready", fasthttp.StatusServiceUnavailable)
+		return nil, false
+	}
+
+	bucket, err := o.bucket(ctx)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return nil, false
+	}
+
+	return bucket.Storages(), true
+}
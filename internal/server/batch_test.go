@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/7phs/kvs/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// batchFrame appends fields of a batch: uvarint numbers and byte strings prefixed by uvarint lengths.
+func batchFrame(fields ...interface{}) []byte {
+	var buf []byte
+
+	for _, field := range fields {
+		switch v := field.(type) {
+		case uint64:
+			buf = appendUvarint(buf, v)
+		case string:
+			buf = append(appendUvarint(buf, uint64(len(v))), v...)
+		case []byte:
+			buf = append(buf, v...)
+		}
+	}
+
+	return buf
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+
+	return append(buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func TestParseBatchGet(t *testing.T) {
+	var (
+		maxKeys  []interface{}
+		overflow = bytes.Repeat([]byte{0xff}, binary.MaxVarintLen64+1)
+	)
+
+	for i := 0; i < maxBatchItems; i++ {
+		maxKeys = append(maxKeys, "k")
+	}
+
+	testSuites := []struct {
+		name  string
+		body  []byte
+		count int
+		err   error
+	}{
+		{name: "empty"},
+		{name: "keys", body: batchFrame("k1", "", "key-3"), count: 3},
+		{name: "max keys", body: batchFrame(maxKeys...), count: maxBatchItems},
+		{name: "too many keys", body: batchFrame(append(maxKeys, "k")...), err: ErrBadRequest},
+		{name: "truncated length", body: []byte{0x80}, err: ErrBadRequest},
+		{name: "overflowed length", body: overflow, err: ErrBadRequest},
+		{name: "length over a body", body: batchFrame(uint64(3), []byte("k1")), err: ErrBadRequest},
+		{name: "truncated last key", body: batchFrame("k1", uint64(3), []byte("k")), err: ErrBadRequest},
+		{name: "huge length", body: batchFrame(uint64(1<<63), []byte("k")), err: ErrBadRequest},
+	}
+
+	for _, test := range testSuites {
+		keys, err := parseBatchGet(test.body)
+
+		assert.Equal(t, test.err, err, test.name)
+		assert.Len(t, keys, test.count, test.name)
+	}
+
+	keys, err := parseBatchGet(batchFrame("k1", "", "key-3"))
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("k1"), {}, []byte("key-3")}, keys)
+}
+
+func TestParseBatchSet(t *testing.T) {
+	var maxItems []interface{}
+
+	for i := 0; i < maxBatchItems; i++ {
+		maxItems = append(maxItems, "k", uint64(0), "v")
+	}
+
+	testSuites := []struct {
+		name  string
+		body  []byte
+		count int
+		err   error
+	}{
+		{name: "empty"},
+		{name: "items", body: batchFrame("k1", uint64(0), "v1", "k2", uint64(1500), ""), count: 2},
+		{name: "max items", body: batchFrame(maxItems...), count: maxBatchItems},
+		{name: "too many items", body: batchFrame(append(maxItems, "k", uint64(0), "v")...), err: ErrBadRequest},
+		{name: "max ttl", body: batchFrame("k", uint64(maxTTLMillis), "v"), count: 1},
+		{name: "too long ttl", body: batchFrame("k", uint64(maxTTLMillis+1), "v"), err: ErrBadRequest},
+		{name: "missed ttl", body: batchFrame("k"), err: ErrBadRequest},
+		{name: "missed value", body: batchFrame("k", uint64(0)), err: ErrBadRequest},
+		{name: "truncated ttl", body: batchFrame("k", []byte{0x80}), err: ErrBadRequest},
+		{name: "truncated value", body: batchFrame("k", uint64(0), uint64(2), []byte("v")), err: ErrBadRequest},
+		{name: "truncated key", body: batchFrame(uint64(2), []byte("k")), err: ErrBadRequest},
+	}
+
+	for _, test := range testSuites {
+		items, err := parseBatchSet(test.body)
+
+		assert.Equal(t, test.err, err, test.name)
+		assert.Len(t, items, test.count, test.name)
+	}
+
+	items, err := parseBatchSet(batchFrame("k1", uint64(0), "v1", "k2", uint64(1500), ""))
+	require.NoError(t, err)
+	assert.Equal(t, []storages.BatchItem{
+		{Key: []byte("k1"), Value: []byte("v1")},
+		{Key: []byte("k2"), Value: []byte{}, TTL: 1500 * time.Millisecond},
+	}, items)
+}
+
+func TestBatchHandlers(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	body := batchFrame("/k1", uint64(0), "v1", "/k2", uint64(10), "v2")
+
+	ctx := serveTestRequest(srv, http.MethodPost, pathBatchSet, string(body), nil)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, []byte{byte(protocol.StatusOK), byte(protocol.StatusOK)}, ctx.Response.Body())
+
+	ctx = serveTestRequest(srv, http.MethodPost, pathBatchGet, string(batchFrame("/k1", "/missed", "/k2")), nil)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, contentTypeBatch, string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, batchFrame(
+		[]byte{byte(protocol.StatusOK)}, "v1",
+		[]byte{byte(protocol.StatusNotFound)},
+		[]byte{byte(protocol.StatusOK)}, "v2",
+	), ctx.Response.Body())
+
+	ctx = serveTestRequest(srv, http.MethodPost, pathBatchGet, string(batchFrame(uint64(3), []byte("/k"))), nil)
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodPost, pathBatchSet, string(batchFrame("/k")), nil)
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodGet, pathBatchGet, "", nil)
+	assert.Equal(t, fasthttp.StatusMethodNotAllowed, ctx.Response.StatusCode())
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/7phs/kvs/pkg/protocol"
+	"go.uber.org/zap"
+)
+
+const (
+	listenerBinary = "binary"
+
+	maxRetainedFrame = 1 << 20
+)
+
+func (o *DefaultServer) serveBinary(conn net.Conn) {
+	var (
+		w     = bufio.NewWriter(conn)
+		r     = bufio.NewReader(flushingReader{r: conn, w: w})
+		frame []byte
+		resp  []byte
+		err   error
+	)
+
+	for {
+		frame, err = protocol.ReadFrame(r, frame)
+		if err == protocol.ErrFrameTooLarge {
+			// a stream can't be synchronized after skipping a frame
+			_, _ = w.Write(protocol.AppendResponse(resp[:0], protocol.StatusBadRequest, nil))
+			_ = w.Flush()
+
+			return
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				o.logger.Debug("binary: connection is closed",
+					zap.String("remote", conn.RemoteAddr().String()),
+					zap.Error(err),
+				)
+			}
+
+			return
+		}
+
+		resp = o.binaryCommand(resp[:0], frame)
+
+		if cap(frame) > maxRetainedFrame {
+			frame = nil
+		}
+
+		_, err = w.Write(resp)
+		if err != nil {
+			return
+		}
+
+		if cap(resp) > maxRetainedFrame {
+			resp = nil
+		}
+	}
+}
+
+func (o *DefaultServer) binaryCommand(dst, frame []byte) []byte {
+	req, err := protocol.ParseRequest(frame)
+	if err != nil {
+		o.metrics.commands.Inc(listenerBinary, protocol.Op(0).String(), protocol.StatusBadRequest.String())
+
+		return protocol.AppendResponse(dst, protocol.StatusBadRequest, nil)
+	}
+
+	status := protocol.StatusOK
+	defer func() {
+		o.metrics.commands.Inc(listenerBinary, req.Op.String(), status.String())
+	}()
+
+	if o.isStarting() {
+		status = protocol.StatusNotReady
+
+		return protocol.AppendResponse(dst, status, nil)
+	}
+
+	switch req.Op {
+	case protocol.OpGet:
+		value, err := o.storages.Get(req.Key)
+		if err != nil {
+			status = protocolStatus(err)
+			break
+		}
+
+		dst = protocol.AppendResponse(dst, status, value.Bytes())
+		value.Free()
+
+		return dst
+
+	case protocol.OpSet:
+		ttl := req.TTL
+		if ttl == protocol.NoTTL {
+			ttl = storages.Persistent
+		}
+
+		status = protocolStatus(o.storages.Add(req.Key, req.Value, ttl))
+
+	case protocol.OpDelete:
+		status = protocolStatus(o.storages.Delete(req.Key))
+
+	case protocol.OpTTL:
+		ttl, err := o.storages.TTL(req.Key)
+		if err != nil {
+			status = protocolStatus(err)
+			break
+		}
+
+		return protocol.AppendTTLResponse(dst, ttl)
+	}
+
+	return protocol.AppendResponse(dst, status, nil)
+}
+
+func protocolStatus(err error) protocol.Status {
+	switch err {
+	case nil:
+		return protocol.StatusOK
+	case storages.ErrKeyNotFound, storages.ErrKeyExpired:
+		return protocol.StatusNotFound
+	case storages.ErrInvalidTTL:
+		return protocol.StatusBadRequest
+	case storages.ErrOutOfLimit:
+		return protocol.StatusOutOfLimit
+	default:
+		return protocol.StatusError
+	}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	pathAdminBuckets       = "/_admin/buckets"
+	pathAdminBucketsPrefix = "/_admin/buckets/"
+	pathBucketsPrefix      = "/_buckets/"
+
+	headerBucket = "X-Bucket"
+	argBucket    = "bucket"
+)
+
+type bucketRequest struct {
+	Mode       string `json:"mode"`
+	Expiration string `json:"expiration"`
+	MaxMemory  int64  `json:"max_memory"`
+	Eviction   string `json:"eviction"`
+}
+
+type bucketResponse struct {
+	Name       string     `json:"name"`
+	Mode       string     `json:"mode"`
+	Expiration string     `json:"expiration"`
+	MaxMemory  int64      `json:"max_memory"`
+	Eviction   string     `json:"eviction"`
+	Keys       infoKeys   `json:"keys"`
+	Memory     infoMemory `json:"memory"`
+}
+
+func newBucketResponse(bucket *storages.Bucket) bucketResponse {
+	var (
+		settings = bucket.Settings()
+		stats    = bucket.Storages().Stats()
+	)
+
+	return bucketResponse{
+		Name:       bucket.Name(),
+		Mode:       string(settings.Mode),
+		Expiration: settings.Expiration.String(),
+		MaxMemory:  settings.MaxMemory,
+		Eviction:   string(settings.Eviction),
+		Keys:       newInfoKeys(stats),
+		Memory:     newInfoMemory(stats),
+	}
+}
+
+func (o *DefaultServer) bucket(ctx *fasthttp.RequestCtx) (*storages.Bucket, error) {
+	name := headerOrArg(ctx, headerBucket, argBucket)
+	if len(name) == 0 {
+		return o.buckets.Default(), nil
+	}
+
+	return o.buckets.Get(string(name))
+}
+
+func (o *DefaultServer) bucketKeyHandler(ctx *fasthttp.RequestCtx, path []byte) {
+	index := bytes.IndexByte(path, '/')
+	if index <= 0 {
+		ctx.Error("Not found", fasthttp.StatusNotFound)
+		return
+	}
+
+	bucket, err := o.buckets.Get(string(path[:index]))
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	o.serveKey(ctx, bucket.Storages(), path[index:])
+}
+
+func (o *DefaultServer) adminBucketsHandler(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		ctx.Error("Unsupported method", fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	buckets := o.buckets.List()
+	resp := make([]bucketResponse, 0, len(buckets))
+
+	for _, bucket := range buckets {
+		resp = append(resp, newBucketResponse(bucket))
+	}
+
+	o.writeJSON(ctx, fasthttp.StatusOK, resp)
+}
+
+func (o *DefaultServer) adminBucketHandler(ctx *fasthttp.RequestCtx, name []byte) {
+	var (
+		bucket *storages.Bucket
+		status = fasthttp.StatusOK
+		err    error
+	)
+
+	switch string(ctx.Method()) {
+	case http.MethodGet:
+		bucket, err = o.buckets.Get(string(name))
+
+	case http.MethodPut, http.MethodPost:
+		if o.isStarting() {
+			ctx.Error("Not ready", fasthttp.StatusServiceUnavailable)
+			return
+		}
+
+		var settings storages.BucketSettings
+
+		settings, err = parseBucketSettings(ctx.Request.Body())
+		if err == nil {
+			bucket, err = o.buckets.Create(string(name), settings)
+			status = fasthttp.StatusCreated
+		}
+
+	case http.MethodDelete:
+		if o.isStarting() {
+			ctx.Error("Not ready", fasthttp.StatusServiceUnavailable)
+			return
+		}
+
+		err = o.buckets.Drop(string(name))
+		if err == nil {
+			ctx.SetStatusCode(fasthttp.StatusOK)
+			return
+		}
+
+	default:
+		ctx.Error("Unsupported method", fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	o.writeJSON(ctx, status, newBucketResponse(bucket))
+}
+
+func (o *DefaultServer) writeJSON(ctx *fasthttp.RequestCtx, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	ctx.SetStatusCode(status)
+	ctx.SetContentType("application/json")
+	ctx.SetBody(body)
+}
+
+func parseBucketSettings(body []byte) (storages.BucketSettings, error) {
+	var (
+		req      bucketRequest
+		settings storages.BucketSettings
+	)
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return settings, nil
+	}
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return settings, ErrBadRequest
+	}
+
+	settings.Mode = config.StorageMode(req.Mode)
+	settings.MaxMemory = req.MaxMemory
+	settings.Eviction = config.EvictionPolicy(req.Eviction)
+
+	if req.Expiration != "" {
+		expiration, err := time.ParseDuration(req.Expiration)
+		if err != nil || expiration <= 0 {
+			return settings, ErrBadRequest
+		}
+
+		settings.Expiration = expiration
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	pathIncrPrefix = "/_incr"
+	pathDecrPrefix = "/_decr"
+
+	headerDelta = "X-Delta"
+	argDelta    = "delta"
+)
+
+func (o *DefaultServer) incrHandler(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) {
+	o.serveCounter(ctx, storage, key, false)
+}
+
+func (o *DefaultServer) decrHandler(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) {
+	o.serveCounter(ctx, storage, key, true)
+}
+
+func (o *DefaultServer) serveCounter(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte, decr bool) {
+	delta, err := parseDelta(ctx)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	if decr {
+		delta = -delta
+	}
+
+	ttl, err := parseTTL(ctx)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	n, err := storage.Incr(key, delta, ttl)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(strconv.AppendInt(nil, n, 10))
+}
+
+func parseDelta(ctx *fasthttp.RequestCtx) (int64, error) {
+	v := headerOrArg(ctx, headerDelta, argDelta)
+	if len(v) == 0 {
+		return 1, nil
+	}
+
+	delta, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil || delta == math.MinInt64 {
+		return 0, ErrBadRequest
+	}
+
+	return delta, nil
+}
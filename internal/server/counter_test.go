@@ -0,0 +1,139 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseDelta(t *testing.T) {
+	testSuites := []struct {
+		uri     string
+		headers map[string]string
+		delta   int64
+		err     error
+	}{
+		{uri: "/key", delta: 1},
+		{uri: "/key?delta=5", delta: 5},
+		{uri: "/key?delta=-5", delta: -5},
+		{uri: "/key?delta=0", delta: 0},
+		{uri: "/key?delta=5", headers: map[string]string{headerDelta: "7"}, delta: 7},
+		{uri: "/key?delta=9223372036854775807", delta: 9223372036854775807},
+		{uri: "/key?delta=-9223372036854775807", delta: -9223372036854775807},
+		{uri: "/key?delta=-9223372036854775808", err: ErrBadRequest},
+		{uri: "/key?delta=9223372036854775808", err: ErrBadRequest},
+		{uri: "/key", headers: map[string]string{headerDelta: "1.5"}, err: ErrBadRequest},
+		{uri: "/key?delta=x", err: ErrBadRequest},
+	}
+
+	for _, test := range testSuites {
+		delta, err := parseDelta(newTestRequestCtx(test.uri, test.headers))
+		assert.Equal(t, test.err, err, test.uri)
+		assert.Equal(t, test.delta, delta, test.uri)
+	}
+}
+
+func TestServeCounter(t *testing.T) {
+	type request struct {
+		uri     string
+		headers map[string]string
+		status  int
+		body    string
+	}
+
+	testSuites := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "default delta",
+			requests: []request{
+				{uri: "/_incr/missed", status: fasthttp.StatusOK, body: "1"},
+				{uri: "/_incr/missed", status: fasthttp.StatusOK, body: "2"},
+				{uri: "/_decr/missed", status: fasthttp.StatusOK, body: "1"},
+				{uri: "/_decr/other", status: fasthttp.StatusOK, body: "-1"},
+			},
+		},
+		{
+			name: "delta by a header and an argument",
+			requests: []request{
+				{uri: "/_incr/number?delta=10", status: fasthttp.StatusOK, body: "52"},
+				{uri: "/_decr/number", headers: map[string]string{headerDelta: "50"}, status: fasthttp.StatusOK, body: "2"},
+				{uri: "/_incr/number?delta=-3", status: fasthttp.StatusOK, body: "-1"},
+				{uri: "/_decr/number?delta=-9223372036854775807", status: fasthttp.StatusOK, body: "9223372036854775806"},
+			},
+		},
+		{
+			name: "bad delta",
+			requests: []request{
+				{uri: "/_incr/number?delta=-9223372036854775808", status: fasthttp.StatusBadRequest},
+				{uri: "/_decr/number?delta=-9223372036854775808", status: fasthttp.StatusBadRequest},
+				{uri: "/_incr/number?delta=x", status: fasthttp.StatusBadRequest},
+				{uri: "/_incr/number?ttl=-1", status: fasthttp.StatusBadRequest},
+			},
+		},
+		{
+			name: "not an integer",
+			requests: []request{
+				{uri: "/_incr/text", status: fasthttp.StatusConflict},
+				{uri: "/_decr/text", status: fasthttp.StatusConflict},
+			},
+		},
+		{
+			name: "overflow",
+			requests: []request{
+				{uri: "/_incr/number?delta=9223372036854775765", status: fasthttp.StatusOK, body: "9223372036854775807"},
+				{uri: "/_incr/number", status: fasthttp.StatusConflict},
+				{uri: "/_decr/number?delta=9223372036854775807", status: fasthttp.StatusOK, body: "0"},
+				{uri: "/_decr/number?delta=9223372036854775807", status: fasthttp.StatusOK, body: "-9223372036854775807"},
+				{uri: "/_decr/number?delta=2", status: fasthttp.StatusConflict},
+				{uri: "/_decr/number", status: fasthttp.StatusOK, body: "-9223372036854775808"},
+			},
+		},
+	}
+
+	for _, test := range testSuites {
+		srv := newTestServer(t, nil)
+		require.NoError(t, srv.storages.Add([]byte("/number"), []byte("42"), 0))
+		require.NoError(t, srv.storages.Add([]byte("/text"), []byte("text"), 0))
+
+		for i, req := range test.requests {
+			ctx := serveTestRequest(srv, http.MethodPost, req.uri, "", req.headers)
+
+			name := test.name + ": " + strconv.Itoa(i)
+			assert.Equal(t, req.status, ctx.Response.StatusCode(), name)
+
+			if req.body != "" {
+				assert.Equal(t, req.body, string(ctx.Response.Body()), name)
+			}
+		}
+	}
+}
+
+func TestServeCounter_TTL(t *testing.T) {
+	srv := newTestServer(t, nil)
+	require.NoError(t, srv.storages.Add([]byte("/number"), []byte("1"), time.Hour))
+
+	ctx := serveTestRequest(srv, http.MethodPost, "/_incr/missed?ttl=10", "", nil)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	ttl, err := srv.storages.TTL([]byte("/missed"))
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, ttl)
+
+	// an existing key keeps its lifetime
+	ctx = serveTestRequest(srv, http.MethodPost, "/_incr/number?ttl=10", "", nil)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	ttl, err = srv.storages.TTL([]byte("/number"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, ttl)
+
+	ctx = serveTestRequest(srv, http.MethodGet, "/_incr/number", "", nil)
+	assert.Equal(t, fasthttp.StatusMethodNotAllowed, ctx.Response.StatusCode())
+}
@@ -0,0 +1,11 @@
+package server
+
+const (
+	ErrBadRequest Error = "bad_request"
+)
+
+type Error string
+
+func (o Error) Error() string {
+	return string(o)
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	pathTouchPrefix   = "/_touch"
+	pathPersistPrefix = "/_persist"
+	pathTTLPrefix     = "/_ttl"
+	pathGetDelPrefix  = "/_getdel"
+	pathGetExPrefix   = "/_getex"
+)
+
+func (o *DefaultServer) touchHandler(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) {
+	err := touch(ctx, storage, key)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+}
+
+func touch(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) error {
+	expireAt, ok, err := parseExpireAt(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return storage.ExpireAt(key, expireAt)
+	}
+
+	ttl, err := parseTTL(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ttl == 0 {
+		return ErrBadRequest
+	}
+
+	return storage.Expire(key, ttl)
+}
+
+func (o *DefaultServer) persistHandler(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) {
+	persisted, err := storage.Persist(key)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+
+	if persisted {
+		ctx.SetBodyString("1")
+	} else {
+		ctx.SetBodyString("0")
+	}
+}
+
+func (o *DefaultServer) ttlHandler(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) {
+	ttl, err := storage.TTL(key)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	ms := int64(-1)
+	if ttl != storages.Persistent {
+		ms = int64(ttl / time.Millisecond)
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(strconv.AppendInt(nil, ms, 10))
+}
+
+func (o *DefaultServer) getDeleteHandler(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) {
+	body, err := storage.GetDelete(key)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	defer body.Free()
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(body.Bytes())
+}
+
+func (o *DefaultServer) getExpireHandler(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) {
+	body, err := getExpire(ctx, storage, key)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	defer body.Free()
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(body.Bytes())
+}
+
+func getExpire(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) (storages.Buffer, error) {
+	expireAt, ok, err := parseExpireAt(ctx)
+	if err != nil {
+		return storages.Buffer{}, err
+	}
+
+	if ok {
+		return storage.GetExpireAt(key, expireAt)
+	}
+
+	ttl, err := parseTTL(ctx)
+	if err != nil {
+		return storages.Buffer{}, err
+	}
+
+	if ttl == 0 {
+		return storages.Buffer{}, ErrBadRequest
+	}
+
+	return storage.GetExpire(key, ttl)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestExpireHandlers(t *testing.T) {
+	now := time.Now()
+
+	type request struct {
+		method  string
+		uri     string
+		headers map[string]string
+		status  int
+		body    string
+	}
+
+	testSuites := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "touch",
+			requests: []request{
+				{
+					method:  http.MethodPost,
+					uri:     "/_touch/expiring",
+					headers: map[string]string{headerTTL: "100"},
+					status:  fasthttp.StatusOK,
+				},
+				{method: http.MethodGet, uri: "/_ttl/expiring", status: fasthttp.StatusOK, body: "100000"},
+				{method: http.MethodPost, uri: "/_touch/expiring?ttl=1m30s", status: fasthttp.StatusOK},
+				{method: http.MethodGet, uri: "/_ttl/expiring", status: fasthttp.StatusOK, body: "90000"},
+				{
+					method:  http.MethodPost,
+					uri:     "/_touch/persistent",
+					headers: map[string]string{headerExpireAt: strconv.FormatInt(now.Unix()+60, 10)},
+					status:  fasthttp.StatusOK,
+				},
+				{
+					method: http.MethodGet,
+					uri:    "/_ttl/persistent",
+					status: fasthttp.StatusOK,
+					body:   strconv.FormatInt(int64(time.Unix(now.Unix()+60, 0).Sub(now)/time.Millisecond), 10),
+				},
+			},
+		},
+		{
+			name: "touch without a lifetime",
+			requests: []request{
+				{method: http.MethodPost, uri: "/_touch/expiring", status: fasthttp.StatusBadRequest},
+				{
+					method:  http.MethodPost,
+					uri:     "/_touch/expiring",
+					headers: map[string]string{headerTTL: "0"},
+					status:  fasthttp.StatusBadRequest,
+				},
+				{method: http.MethodPost, uri: "/_touch/expiring?ttl=0s", status: fasthttp.StatusBadRequest},
+				{
+					method:  http.MethodPost,
+					uri:     "/_touch/expiring",
+					headers: map[string]string{headerExpireAt: "1"},
+					status:  fasthttp.StatusBadRequest,
+				},
+				{method: http.MethodGet, uri: "/_ttl/expiring", status: fasthttp.StatusOK, body: "60000"},
+			},
+		},
+		{
+			name: "persist",
+			requests: []request{
+				{method: http.MethodPost, uri: "/_persist/expiring", status: fasthttp.StatusOK, body: "1"},
+				{method: http.MethodPost, uri: "/_persist/expiring", status: fasthttp.StatusOK, body: "0"},
+				{method: http.MethodPost, uri: "/_persist/persistent", status: fasthttp.StatusOK, body: "0"},
+				{method: http.MethodGet, uri: "/_ttl/expiring", status: fasthttp.StatusOK, body: "-1"},
+			},
+		},
+		{
+			name: "ttl",
+			requests: []request{
+				{method: http.MethodGet, uri: "/_ttl/expiring", status: fasthttp.StatusOK, body: "60000"},
+				{method: http.MethodGet, uri: "/_ttl/persistent", status: fasthttp.StatusOK, body: "-1"},
+			},
+		},
+		{
+			name: "get and expire",
+			requests: []request{
+				{method: http.MethodPost, uri: "/_getex/persistent?ttl=10", status: fasthttp.StatusOK, body: "value"},
+				{method: http.MethodGet, uri: "/_ttl/persistent", status: fasthttp.StatusOK, body: "10000"},
+				{method: http.MethodPost, uri: "/_getex/persistent", status: fasthttp.StatusBadRequest},
+			},
+		},
+		{
+			name: "get and delete",
+			requests: []request{
+				{method: http.MethodPost, uri: "/_getdel/expiring", status: fasthttp.StatusOK, body: "value"},
+				{method: http.MethodPost, uri: "/_getdel/expiring", status: fasthttp.StatusNotFound},
+				{method: http.MethodGet, uri: "/_ttl/expiring", status: fasthttp.StatusNotFound},
+			},
+		},
+		{
+			name: "unknown key",
+			requests: []request{
+				{method: http.MethodPost, uri: "/_touch/unknown?ttl=10", status: fasthttp.StatusNotFound},
+				{method: http.MethodPost, uri: "/_persist/unknown", status: fasthttp.StatusNotFound},
+				{method: http.MethodGet, uri: "/_ttl/unknown", status: fasthttp.StatusNotFound},
+				{method: http.MethodPost, uri: "/_getdel/unknown", status: fasthttp.StatusNotFound},
+				{method: http.MethodPost, uri: "/_getex/unknown?ttl=10", status: fasthttp.StatusNotFound},
+			},
+		},
+		{
+			name: "wrong method",
+			requests: []request{
+				{method: http.MethodGet, uri: "/_touch/expiring?ttl=10", status: fasthttp.StatusMethodNotAllowed},
+				{method: http.MethodGet, uri: "/_persist/expiring", status: fasthttp.StatusMethodNotAllowed},
+				{method: http.MethodPost, uri: "/_ttl/expiring", status: fasthttp.StatusMethodNotAllowed},
+			},
+		},
+	}
+
+	for _, test := range testSuites {
+		srv := newTestServer(t, &mockConfig{Root: true, TimeS: constantTime(now)})
+		require.NoError(t, srv.storages.Add([]byte("/expiring"), []byte("value"), time.Minute))
+		require.NoError(t, srv.storages.Add([]byte("/persistent"), []byte("value"), storages.Persistent))
+
+		for i, req := range test.requests {
+			ctx := serveTestRequest(srv, req.method, req.uri, "", req.headers)
+
+			name := test.name + ": " + strconv.Itoa(i)
+			assert.Equal(t, req.status, ctx.Response.StatusCode(), name)
+
+			if req.body != "" {
+				assert.Equal(t, req.body, string(ctx.Response.Body()), name)
+			}
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/7phs/kvs/pkg/kvspb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	listenerGRPC = "grpc"
+
+	grpcScanPage = 1000
+)
+
+type grpcService struct {
+	kvspb.UnimplementedKVSServer
+
+	srv *DefaultServer
+}
+
+func newGRPCServer(srv *DefaultServer) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(
+			ctx context.Context,
+			req interface{},
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			resp, err := handler(ctx, req)
+			srv.grpcCommand(info.FullMethod, err)
+
+			return resp, err
+		}),
+		grpc.StreamInterceptor(func(
+			impl interface{},
+			stream grpc.ServerStream,
+			info *grpc.StreamServerInfo,
+			handler grpc.StreamHandler,
+		) error {
+			err := handler(impl, stream)
+			srv.grpcCommand(info.FullMethod, err)
+
+			return err
+		}),
+	)
+
+	kvspb.RegisterKVSServer(server, &grpcService{srv: srv})
+
+	return server
+}
+
+func (o *DefaultServer) grpcCommand(fullMethod string, err error) {
+	name := strings.ToLower(fullMethod[strings.LastIndexByte(fullMethod, '/')+1:])
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	o.metrics.commands.Inc(listenerGRPC, name, result)
+}
+
+func (o *DefaultServer) grpcListenAndServe() error {
+	port := fmt.Sprintf(":%d", o.conf.GRPCPort())
+
+	listener, err := net.Listen("tcp", port)
+	if err != nil {
+		return err
+	}
+
+	o.logger.Info("grpc: listen",
+		zap.String("port", port),
+	)
+
+	return o.grpc.Serve(listener)
+}
+
+func grpcError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case storages.ErrKeyNotFound,
+		storages.ErrKeyExpired,
+		storages.ErrBucketNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case ErrBadRequest,
+		storages.ErrInvalidTTL,
+		storages.ErrInvalidBucket:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case storages.ErrOutOfLimit,
+		storages.ErrWatchOverflow:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func (o *grpcService) storages(bucket string) (storages.Storages, error) {
+	if o.srv.isStarting() {
+		return nil, status.Error(codes.Unavailable, "not ready")
+	}
+
+	if bucket == "" {
+		return o.srv.buckets.Default().Storages(), nil
+	}
+
+	b, err := o.srv.buckets.Get(bucket)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return b.Storages(), nil
+}
+
+func grpcTTL(ttlMs int64) (time.Duration, error) {
+	if ttlMs < 0 || ttlMs > maxTTLMillis {
+		return 0, ErrBadRequest
+	}
+
+	return time.Duration(ttlMs) * time.Millisecond, nil
+}
+
+func (o *grpcService) Get(_ context.Context, req *kvspb.GetRequest) (*kvspb.GetResponse, error) {
+	storage, err := o.storages(req.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := storage.Get(req.Key)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &kvspb.GetResponse{
+		Value: append([]byte{}, value.Bytes()...),
+	}
+
+	value.Free()
+
+	return resp, nil
+}
+
+func (o *grpcService) Set(_ context.Context, req *kvspb.SetRequest) (*kvspb.SetResponse, error) {
+	storage, err := o.storages(req.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Key) == 0 {
+		return nil, grpcError(ErrBadRequest)
+	}
+
+	ttl, err := grpcTTL(req.TtlMs)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	err = storage.Add(req.Key, req.Value, ttl)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &kvspb.SetResponse{}, nil
+}
+
+func (o *grpcService) Delete(_ context.Context, req *kvspb.DeleteRequest) (*kvspb.DeleteResponse, error) {
+	storage, err := o.storages(req.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	err = storage.Delete(req.Key)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &kvspb.DeleteResponse{}, nil
+}
+
+func (o *grpcService) BatchGet(_ context.Context, req *kvspb.BatchGetRequest) (*kvspb.BatchGetResponse, error) {
+	storage, err := o.storages(req.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Keys) > maxBatchItems {
+		return nil, grpcError(ErrBadRequest)
+	}
+
+	values, errs := storage.GetBatch(req.Keys)
+
+	resp := &kvspb.BatchGetResponse{
+		Items: make([]*kvspb.GetItem, len(values)),
+	}
+
+	for i := range values {
+		item := &kvspb.GetItem{
+			Status: kvspb.Status(protocolStatus(errs[i])),
+		}
+
+		if errs[i] == nil {
+			item.Value = append([]byte{}, values[i].Bytes()...)
+			values[i].Free()
+		}
+
+		resp.Items[i] = item
+	}
+
+	return resp, nil
+}
+
+func (o *grpcService) BatchSet(_ context.Context, req *kvspb.BatchSetRequest) (*kvspb.BatchSetResponse, error) {
+	storage, err := o.storages(req.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Items) > maxBatchItems {
+		return nil, grpcError(ErrBadRequest)
+	}
+
+	var (
+		resp = &kvspb.BatchSetResponse{
+			Statuses: make([]kvspb.Status, len(req.Items)),
+		}
+		items   = make([]storages.BatchItem, 0, len(req.Items))
+		indexes = make([]int, 0, len(req.Items))
+	)
+
+	for i, item := range req.Items {
+		ttl, err := grpcTTL(item.TtlMs)
+		if err != nil || len(item.Key) == 0 {
+			resp.Statuses[i] = kvspb.Status_BAD_REQUEST
+			continue
+		}
+
+		items = append(items, storages.BatchItem{Key: item.Key, Value: item.Value, TTL: ttl})
+		indexes = append(indexes, i)
+	}
+
+	for i, err := range storage.AddBatch(items) {
+		resp.Statuses[indexes[i]] = kvspb.Status(protocolStatus(err))
+	}
+
+	return resp, nil
+}
+
+func (o *grpcService) Scan(req *kvspb.ScanRequest, stream kvspb.KVS_ScanServer) error {
+	storage, err := o.storages(req.Bucket)
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	cursor := uint64(0)
+
+	for {
+		keys, next := storage.Scan(req.Prefix, cursor, grpcScanPage)
+
+		if len(keys) > 0 {
+			if err := stream.Send(&kvspb.ScanResponse{Keys: keys}); err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return grpcError(ctx.Err())
+		}
+
+		cursor = next
+	}
+}
+
+func (o *grpcService) Watch(req *kvspb.WatchRequest, stream kvspb.KVS_WatchServer) error {
+	storage, err := o.storages(req.Bucket)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-o.srv.cancelCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err = storage.Watch(ctx, req.Prefix, func(event storages.Event) error {
+		return stream.Send(grpcEvent(event))
+	})
+
+	switch {
+	case o.srv.cancelCtx.Err() != nil:
+		return status.Error(codes.Unavailable, "server is stopping")
+	case err == context.Canceled:
+		return nil
+	default:
+		return grpcError(err)
+	}
+}
+
+func grpcEvent(event storages.Event) *kvspb.WatchEvent {
+	e := &kvspb.WatchEvent{
+		Key:   event.Key,
+		Value: event.Value,
+	}
+
+	switch event.Type {
+	case storages.EventSet:
+		e.Type = kvspb.EventType_EVENT_TYPE_SET
+	case storages.EventExpire:
+		e.Type = kvspb.EventType_EVENT_TYPE_EXPIRE
+	case storages.EventDelete:
+		e.Type = kvspb.EventType_EVENT_TYPE_DELETE
+	}
+
+	if event.Type != storages.EventDelete && !event.Expiration.Equal(storages.NoExpiration) {
+		e.ExpirationMs = event.Expiration.UnixNano() / int64(time.Millisecond)
+	}
+
+	return e
+}
+
+func (o *grpcService) Load(stream kvspb.KVS_LoadServer) error {
+	var (
+		resp    kvspb.LoadResponse
+		bucket  string
+		storage storages.Storages
+		items   []storages.BatchItem
+	)
+
+	flush := func() {
+		if len(items) == 0 {
+			return
+		}
+
+		for _, err := range storage.AddBatch(items) {
+			if err == nil {
+				resp.Stored++
+			} else {
+				resp.Failed++
+			}
+		}
+
+		items = items[:0]
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			flush()
+
+			return stream.SendAndClose(&resp)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if storage == nil || req.Bucket != bucket {
+			flush()
+
+			storage, err = o.storages(req.Bucket)
+			if err != nil {
+				return err
+			}
+
+			bucket = req.Bucket
+		}
+
+		ttl, err := grpcTTL(req.TtlMs)
+		if err != nil || len(req.Key) == 0 {
+			resp.Failed++
+			continue
+		}
+
+		items = append(items, storages.BatchItem{Key: req.Key, Value: req.Value, TTL: ttl})
+
+		if len(items) == maxBatchItems {
+			flush()
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	pathHealth = "/_health"
+	pathReady  = "/_ready"
+	pathInfo   = "/_info"
+)
+
+const (
+	stateStarting int32 = iota
+	stateReady
+	stateStopping
+)
+
+// Version is set on building by -ldflags "-X github.com/7phs/kvs/internal/server.Version=...".
+var Version = "dev"
+
+type infoResponse struct {
+	Version       string            `json:"version"`
+	Uptime        string            `json:"uptime"`
+	UptimeSeconds int64             `json:"uptime_seconds"`
+	Mode          string            `json:"mode"`
+	Ready         bool              `json:"ready"`
+	Config        map[string]string `json:"config"`
+	Keys          infoKeys          `json:"keys"`
+	Memory        infoMemory        `json:"memory"`
+}
+
+type infoKeys struct {
+	Total      uint64   `json:"total"`
+	Partitions []uint64 `json:"partitions,omitempty"`
+	Hits       uint64   `json:"hits"`
+	Misses     uint64   `json:"misses"`
+	Expired    uint64   `json:"expired"`
+	Collisions uint64   `json:"collisions"`
+	Evictions  uint64   `json:"evictions"`
+}
+
+type infoMemory struct {
+	AllocatedBytes uint64 `json:"allocated_bytes"`
+	LiveBytes      uint64 `json:"live_bytes"`
+	Chunks         uint64 `json:"chunks"`
+	QueuedChunks   uint64 `json:"queued_chunks"`
+}
+
+func (o *DefaultServer) Ready() {
+	atomic.CompareAndSwapInt32(&o.state, stateStarting, stateReady)
+
+	o.readyOnce.Do(func() {
+		close(o.readyCh)
+	})
+}
+
+func (o *DefaultServer) isReady() bool {
+	return atomic.LoadInt32(&o.state) == stateReady
+}
+
+func (o *DefaultServer) isStarting() bool {
+	return atomic.LoadInt32(&o.state) == stateStarting
+}
+
+func (o *DefaultServer) healthHandler(ctx *fasthttp.RequestCtx) {
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBodyString("OK")
+}
+
+func (o *DefaultServer) readyHandler(ctx *fasthttp.RequestCtx) {
+	if !o.isReady() {
+		ctx.Error("Not ready", fasthttp.StatusServiceUnavailable)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBodyString("OK")
+}
+
+func (o *DefaultServer) infoHandler(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		ctx.Error("Unsupported method", fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		uptime = time.Since(o.started)
+		stats  = o.storages.Stats()
+		resp   = infoResponse{
+			Version:       Version,
+			Uptime:        uptime.Truncate(time.Second).String(),
+			UptimeSeconds: int64(uptime / time.Second),
+			Mode:          string(o.conf.Mode()),
+			Ready:         o.isReady(),
+			Config:        configValues(o.conf),
+			Keys:          newInfoKeys(stats),
+			Memory:        newInfoMemory(stats),
+		}
+	)
+
+	o.writeJSON(ctx, fasthttp.StatusOK, resp)
+}
+
+func newInfoKeys(stats storages.Stats) infoKeys {
+	keys := infoKeys{
+		Total:      stats.Keys,
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Expired:    stats.Expired,
+		Collisions: stats.Collisions,
+		Evictions:  stats.Evictions,
+	}
+
+	for _, partition := range stats.Partitions {
+		keys.Partitions = append(keys.Partitions, partition.Keys)
+	}
+
+	return keys
+}
+
+func newInfoMemory(stats storages.Stats) infoMemory {
+	return infoMemory{
+		AllocatedBytes: stats.AllocatedBytes,
+		LiveBytes:      stats.LiveBytes,
+		Chunks:         stats.Chunks,
+		QueuedChunks:   stats.QueuedChunks,
+	}
+}
+
+func configValues(conf config.Config) map[string]string {
+	return map[string]string{
+		config.LOGLEVEL:     string(conf.LogLevel()),
+		config.PORT:         strconv.Itoa(conf.Port()),
+		config.EXPIRATION:   conf.Expiration().String(),
+		config.MAXTTL:       conf.MaxTTL().String(),
+		config.MAINTENANCE:  conf.Maintenance().String(),
+		config.PREALLOCATED: strconv.Itoa(conf.PreAllocated()),
+		config.MAXMEMORY:    strconv.FormatInt(conf.MaxMemory(), 10),
+		config.EVICTION:     string(conf.EvictionPolicy()),
+		config.COMPACTION:   strconv.FormatFloat(conf.CompactionRatio(), 'g', -1, 64),
+		config.MODE:         string(conf.Mode()),
+		config.KEYSPREFIX:   conf.KeysPrefix(),
+		config.ROOTKEYS:     strconv.FormatBool(conf.RootKeys()),
+		config.BINARYPORT:   strconv.Itoa(conf.BinaryPort()),
+		config.BINARYCONNS:  strconv.Itoa(conf.BinaryMaxConns()),
+		config.REDISPORT:    strconv.Itoa(conf.RedisPort()),
+		config.REDISCONNS:   strconv.Itoa(conf.RedisMaxConns()),
+		config.MEMCACHEPORT: strconv.Itoa(conf.MemcachedPort()),
+		config.MEMCACHECONS: strconv.Itoa(conf.MemcachedMaxConns()),
+		config.GRPCPORT:     strconv.Itoa(conf.GRPCPort()),
+		config.SNAPSHOT:     conf.SnapshotPath(),
+		config.SNAPSHOTINT:  conf.SnapshotInterval().String(),
+		config.JOURNAL:      conf.JournalPath(),
+		config.JOURNALFSYNC: string(conf.JournalFsync()),
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	srv := newTestServer(t, nil)
+	atomic.StoreInt32(&srv.state, stateStarting)
+
+	assertState := func(state string, ready bool, readyStatus, keyStatus int) {
+		t.Helper()
+
+		ctx := serveTestRequest(srv, http.MethodGet, pathHealth, "", nil)
+		assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode(), state)
+
+		ctx = serveTestRequest(srv, http.MethodGet, pathReady, "", nil)
+		assert.Equal(t, readyStatus, ctx.Response.StatusCode(), state)
+
+		ctx = serveTestRequest(srv, http.MethodGet, "/key", "", nil)
+		assert.Equal(t, keyStatus, ctx.Response.StatusCode(), state)
+
+		ctx = serveTestRequest(srv, http.MethodGet, pathInfo, "", nil)
+		require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode(), state)
+
+		var info infoResponse
+
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &info), state)
+		assert.Equal(t, ready, info.Ready, state)
+	}
+
+	assertState("starting", false, fasthttp.StatusServiceUnavailable, fasthttp.StatusServiceUnavailable)
+
+	srv.Ready()
+	assertState("ready", true, fasthttp.StatusOK, fasthttp.StatusNotFound)
+
+	// a stopping server isn't ready, but keys are served till listeners are closed
+	atomic.StoreInt32(&srv.state, stateStopping)
+	assertState("stopping", false, fasthttp.StatusServiceUnavailable, fasthttp.StatusNotFound)
+
+	srv.Ready()
+	assertState("stopped is not ready again", false, fasthttp.StatusServiceUnavailable, fasthttp.StatusNotFound)
+}
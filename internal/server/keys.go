@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	pathKeys = "/_keys"
+
+	argPrefix = "prefix"
+	argCursor = "cursor"
+	argLimit  = "limit"
+
+	defaultScanLimit = 100
+	maxScanLimit     = 1000
+)
+
+type keysResponse struct {
+	Keys   []string `json:"keys"`
+	Cursor string   `json:"cursor"`
+}
+
+func (o *DefaultServer) keysHandler(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		ctx.Error("Unsupported method", fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	if o.isStarting() {
+		ctx.Error("Not ready", fasthttp.StatusServiceUnavailable)
+		return
+	}
+
+	args := ctx.QueryArgs()
+
+	cursor, err := parseUintArg(args.Peek(argCursor), 0)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	limit, err := parseUintArg(args.Peek(argLimit), defaultScanLimit)
+	if err != nil || limit == 0 || limit > maxScanLimit {
+		o.handlerError(ctx, ErrBadRequest)
+		return
+	}
+
+	bucket, err := o.bucket(ctx)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	keys, next := bucket.Storages().Scan(args.Peek(argPrefix), cursor, int(limit))
+
+	resp := keysResponse{
+		Keys:   make([]string, 0, len(keys)),
+		Cursor: strconv.FormatUint(next, 10),
+	}
+
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, string(key))
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("application/json")
+	ctx.SetBody(body)
+}
+
+func parseUintArg(v []byte, defV uint64) (uint64, error) {
+	if len(v) == 0 {
+		return defV, nil
+	}
+
+	n, err := strconv.ParseUint(string(v), 10, 64)
+	if err != nil {
+		return 0, ErrBadRequest
+	}
+
+	return n, nil
+}
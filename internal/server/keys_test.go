@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func scanTestKeys(t *testing.T, srv *DefaultServer, uri string) keysResponse {
+	t.Helper()
+
+	ctx := serveTestRequest(srv, http.MethodGet, uri, "", nil)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode(), uri)
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()), uri)
+
+	var resp keysResponse
+
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &resp), uri)
+
+	return resp
+}
+
+func TestKeysHandler(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	for _, key := range []string{"/a/1", "/a/2", "/a/3", "/b/1"} {
+		require.NoError(t, srv.storages.Add([]byte(key), []byte("v"), 0))
+	}
+
+	resp := scanTestKeys(t, srv, pathKeys+"?prefix=/a/")
+	sort.Strings(resp.Keys)
+	assert.Equal(t, keysResponse{Keys: []string{"/a/1", "/a/2", "/a/3"}, Cursor: "0"}, resp)
+
+	resp = scanTestKeys(t, srv, pathKeys+"?prefix=/c/")
+	assert.Equal(t, keysResponse{Keys: []string{}, Cursor: "0"}, resp)
+
+	var (
+		keys   []string
+		cursor = "0"
+		pages  = 0
+	)
+
+	for {
+		resp = scanTestKeys(t, srv, pathKeys+"?limit=1&cursor="+cursor)
+		assert.LessOrEqual(t, len(resp.Keys), 1)
+
+		keys = append(keys, resp.Keys...)
+		cursor = resp.Cursor
+		pages++
+
+		// the last page is full, so the next empty one finishes scanning
+		if cursor == "0" || pages > 5 {
+			break
+		}
+	}
+
+	sort.Strings(keys)
+	assert.Equal(t, []string{"/a/1", "/a/2", "/a/3", "/b/1"}, keys)
+	assert.Equal(t, 5, pages)
+}
+
+func TestKeysHandler_Bucket(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	bucket, err := srv.buckets.Create("other", storages.BucketSettings{})
+	require.NoError(t, err)
+	require.NoError(t, bucket.Storages().Add([]byte("/other"), []byte("v"), 0))
+	require.NoError(t, srv.storages.Add([]byte("/default"), []byte("v"), 0))
+
+	resp := scanTestKeys(t, srv, pathKeys)
+	assert.Equal(t, []string{"/default"}, resp.Keys)
+
+	resp = scanTestKeys(t, srv, pathKeys+"?bucket=other")
+	assert.Equal(t, []string{"/other"}, resp.Keys)
+
+	ctx := serveTestRequest(srv, http.MethodGet, pathKeys, "", map[string]string{headerBucket: "other"})
+	assert.Equal(t, `{"keys":["/other"],"cursor":"0"}`, string(ctx.Response.Body()))
+
+	ctx = serveTestRequest(srv, http.MethodGet, pathKeys+"?bucket=missed", "", nil)
+	assert.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+}
+
+func TestKeysHandler_Errors(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	testSuites := []struct {
+		method string
+		uri    string
+		status int
+	}{
+		{method: http.MethodPost, uri: pathKeys, status: fasthttp.StatusMethodNotAllowed},
+		{method: http.MethodGet, uri: pathKeys + "?cursor=x", status: fasthttp.StatusBadRequest},
+		{method: http.MethodGet, uri: pathKeys + "?cursor=-1", status: fasthttp.StatusBadRequest},
+		{method: http.MethodGet, uri: pathKeys + "?limit=0", status: fasthttp.StatusBadRequest},
+		{method: http.MethodGet, uri: pathKeys + "?limit=x", status: fasthttp.StatusBadRequest},
+		{method: http.MethodGet, uri: pathKeys + "?limit=" + strconv.Itoa(maxScanLimit+1), status: fasthttp.StatusBadRequest},
+		{method: http.MethodGet, uri: pathKeys + "?limit=" + strconv.Itoa(maxScanLimit), status: fasthttp.StatusOK},
+	}
+
+	for _, test := range testSuites {
+		ctx := serveTestRequest(srv, test.method, test.uri, "", nil)
+		assert.Equal(t, test.status, ctx.Response.StatusCode(), test.method+" "+test.uri)
+	}
+
+	atomic.StoreInt32(&srv.state, stateStarting)
+
+	ctx := serveTestRequest(srv, http.MethodGet, pathKeys, "", nil)
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+}
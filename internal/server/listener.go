@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const acceptRetryDelay = 50 * time.Millisecond
+
+type ConnHandler func(conn net.Conn)
+
+type tcpListener struct {
+	sync.Mutex
+
+	logger   *zap.Logger
+	name     string
+	port     int
+	maxConns int
+	handler  ConnHandler
+
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	rejected uint64
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func newTCPListener(logger *zap.Logger, name string, port, maxConns int, handler ConnHandler) *tcpListener {
+	return &tcpListener{
+		logger:   logger,
+		name:     name,
+		port:     port,
+		maxConns: maxConns,
+		handler:  handler,
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+func (o *tcpListener) ListenAndServe() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", o.port))
+	if err != nil {
+		return err
+	}
+
+	o.Lock()
+	if o.closed {
+		o.Unlock()
+
+		return listener.Close()
+	}
+	o.listener = listener
+	o.Unlock()
+
+	o.logger.Info(o.name+": listen",
+		zap.Int("port", o.port),
+	)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if o.isClosed() {
+				return nil
+			}
+
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				time.Sleep(acceptRetryDelay)
+				continue
+			}
+
+			return err
+		}
+
+		if !o.track(conn) {
+			_ = conn.Close()
+			continue
+		}
+
+		go o.serve(conn)
+	}
+}
+
+func (o *tcpListener) serve(conn net.Conn) {
+	defer o.wg.Done()
+	defer o.untrack(conn)
+
+	o.handler(conn)
+}
+
+func (o *tcpListener) track(conn net.Conn) bool {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.closed {
+		return false
+	}
+
+	if o.maxConns > 0 && len(o.conns) >= o.maxConns {
+		o.rejected++
+
+		o.logger.Debug(o.name+": connection is rejected",
+			zap.String("remote", conn.RemoteAddr().String()),
+			zap.Int("limit", o.maxConns),
+		)
+
+		return false
+	}
+
+	o.conns[conn] = struct{}{}
+	o.wg.Add(1)
+
+	return true
+}
+
+func (o *tcpListener) untrack(conn net.Conn) {
+	o.Lock()
+	delete(o.conns, conn)
+	o.Unlock()
+
+	_ = conn.Close()
+}
+
+func (o *tcpListener) isClosed() bool {
+	o.Lock()
+	defer o.Unlock()
+
+	return o.closed
+}
+
+func (o *tcpListener) Connections() (int, uint64) {
+	o.Lock()
+	defer o.Unlock()
+
+	return len(o.conns), o.rejected
+}
+
+func (o *tcpListener) Shutdown() {
+	o.Lock()
+	o.closed = true
+
+	if o.listener != nil {
+		_ = o.listener.Close()
+	}
+
+	for conn := range o.conns {
+		_ = conn.Close()
+	}
+	o.Unlock()
+
+	o.wg.Wait()
+}
+
+type flushingReader struct {
+	r io.Reader
+	w *bufio.Writer
+}
+
+func (o flushingReader) Read(p []byte) (int, error) {
+	if o.w.Buffered() > 0 {
+		if err := o.w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return o.r.Read(p)
+}
+
+func (o *DefaultServer) listenerConnections(name string) (int, uint64) {
+	for _, listener := range o.listeners {
+		if listener.name == name {
+			return listener.Connections()
+		}
+	}
+
+	return 0, 0
+}
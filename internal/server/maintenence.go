@@ -13,9 +13,12 @@ type Maintenance interface {
 	Clean(ctx context.Context) error
 }
 
+type MaintenanceObserver func(id string, duration time.Duration, err error)
+
 type GroupMaintenance struct {
 	logger          *zap.Logger
 	maintenanceList []Maintenance
+	observe         MaintenanceObserver
 }
 
 func NewGroupMaintenance(logger *zap.Logger, m ...Maintenance) GroupMaintenance {
@@ -53,6 +56,11 @@ func (o *GroupMaintenance) Start(ctx context.Context, interval time.Duration) {
 				start := time.Now()
 
 				err := m.Clean(ctx)
+
+				if o.observe != nil {
+					o.observe(m.ID(), time.Since(start), err)
+				}
+
 				if err != nil {
 					o.logger.Error("failed to clean",
 						zap.String("id", m.ID()),
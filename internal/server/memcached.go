@@ -0,0 +1,593 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"go.uber.org/zap"
+)
+
+const (
+	listenerMemcached = "memcached"
+
+	memcachedServerVersion = "1.6.21"
+
+	memcachedMaxKeySize      = 250
+	memcachedMaxLine         = 64 * 1024
+	memcachedMaxValueSize    = 64 * 1024 * 1024
+	memcachedMaxRetained     = 1 << 20
+	memcachedRelativeExptime = 30 * 24 * 60 * 60
+
+	memcachedNoReply = "noreply"
+
+	memcachedStored    = "STORED"
+	memcachedNotStored = "NOT_STORED"
+	memcachedDeleted   = "DELETED"
+	memcachedNotFound  = "NOT_FOUND"
+	memcachedExists    = "EXISTS"
+	memcachedTouched   = "TOUCHED"
+	memcachedEnd       = "END"
+	memcachedOK        = "OK"
+
+	memcachedErrCommand    = "ERROR"
+	memcachedErrFormat     = "CLIENT_ERROR bad command line format"
+	memcachedErrLine       = "CLIENT_ERROR line is too long"
+	memcachedErrChunk      = "CLIENT_ERROR bad data chunk"
+	memcachedErrDelta      = "CLIENT_ERROR invalid numeric delta argument"
+	memcachedErrNonNumeric = "CLIENT_ERROR cannot increment or decrement non-numeric value"
+	memcachedErrTooLarge   = "SERVER_ERROR object too large for cache"
+	memcachedErrOOM        = "SERVER_ERROR out of memory storing object"
+	memcachedErrLoading    = "SERVER_ERROR kvs is loading the dataset in memory"
+	memcachedErrInternal   = "SERVER_ERROR internal error"
+
+	ErrNonNumeric Error = "non_numeric_value"
+)
+
+type memcachedCommand struct {
+	handler func(o *DefaultServer, c *memcachedConn, args [][]byte)
+	minArgs int
+	maxArgs int
+	storage bool
+	noreply bool
+	ready   bool
+}
+
+var memcachedCommands = map[string]memcachedCommand{
+	"get":       {handler: (*DefaultServer).memcachedGet, minArgs: 1, maxArgs: -1, ready: true},
+	"gets":      {handler: (*DefaultServer).memcachedGets, minArgs: 1, maxArgs: -1, ready: true},
+	"set":       {handler: (*DefaultServer).memcachedSet, minArgs: 4, maxArgs: 4, storage: true, noreply: true, ready: true},
+	"add":       {handler: (*DefaultServer).memcachedAdd, minArgs: 4, maxArgs: 4, storage: true, noreply: true, ready: true},
+	"replace":   {handler: (*DefaultServer).memcachedReplace, minArgs: 4, maxArgs: 4, storage: true, noreply: true, ready: true},
+	"cas":       {handler: (*DefaultServer).memcachedCas, minArgs: 5, maxArgs: 5, storage: true, noreply: true, ready: true},
+	"delete":    {handler: (*DefaultServer).memcachedDelete, minArgs: 1, maxArgs: 1, noreply: true, ready: true},
+	"touch":     {handler: (*DefaultServer).memcachedTouch, minArgs: 2, maxArgs: 2, noreply: true, ready: true},
+	"incr":      {handler: (*DefaultServer).memcachedIncr, minArgs: 2, maxArgs: 2, noreply: true, ready: true},
+	"decr":      {handler: (*DefaultServer).memcachedDecr, minArgs: 2, maxArgs: 2, noreply: true, ready: true},
+	"flush_all": {handler: (*DefaultServer).memcachedFlushAll, maxArgs: 1, noreply: true, ready: true},
+	"stats":     {handler: (*DefaultServer).memcachedStats, maxArgs: -1},
+	"version":   {handler: (*DefaultServer).memcachedVersion},
+	"quit":      {handler: (*DefaultServer).memcachedQuit},
+}
+
+type memcachedConn struct {
+	r *bufio.Reader
+	w *bufio.Writer
+
+	line []byte
+	data []byte
+
+	noreply bool
+	failed  bool
+	quit    bool
+}
+
+func (c *memcachedConn) reply(s string) {
+	if c.noreply {
+		return
+	}
+
+	_, _ = c.w.WriteString(s)
+	_, _ = c.w.WriteString("\r\n")
+}
+
+func (c *memcachedConn) error(s string) {
+	c.failed = true
+	c.reply(s)
+}
+
+func (c *memcachedConn) storageError(err error) {
+	switch err {
+	case storages.ErrOutOfLimit:
+		c.error(memcachedErrOOM)
+	default:
+		c.error(memcachedErrInternal)
+	}
+}
+
+func (o *DefaultServer) serveMemcached(conn net.Conn) {
+	w := bufio.NewWriter(conn)
+
+	c := &memcachedConn{
+		r: bufio.NewReaderSize(flushingReader{r: conn, w: w}, memcachedMaxLine),
+		w: w,
+	}
+
+	for !c.quit {
+		line, err := readLine(c.r)
+		if err == ErrProtocol {
+			c.error(memcachedErrLine)
+			_ = c.w.Flush()
+
+			return
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				o.logger.Debug("memcached: connection is closed",
+					zap.String("remote", conn.RemoteAddr().String()),
+					zap.Error(err),
+				)
+			}
+
+			return
+		}
+
+		c.line = append(c.line[:0], line...)
+
+		o.memcachedExec(c, bytes.Fields(c.line))
+
+		if cap(c.data) > memcachedMaxRetained {
+			c.data = nil
+		}
+	}
+
+	_ = c.w.Flush()
+}
+
+func (o *DefaultServer) memcachedExec(c *memcachedConn, args [][]byte) {
+	c.noreply = false
+	c.failed = false
+
+	if len(args) == 0 {
+		c.error(memcachedErrCommand)
+		return
+	}
+
+	name := string(args[0])
+	args = args[1:]
+
+	cmd, known := memcachedCommands[name]
+	if cmd.noreply && len(args) > 0 && string(args[len(args)-1]) == memcachedNoReply {
+		c.noreply = true
+		args = args[:len(args)-1]
+	}
+
+	switch {
+	case !known:
+		name = "unknown"
+		c.error(memcachedErrCommand)
+	case len(args) < cmd.minArgs,
+		cmd.maxArgs >= 0 && len(args) > cmd.maxArgs:
+		c.error(memcachedErrCommand)
+	case cmd.storage && !o.memcachedReadData(c, args[3]):
+	case cmd.ready && o.isStarting():
+		c.error(memcachedErrLoading)
+	default:
+		cmd.handler(o, c, args)
+	}
+
+	status := "ok"
+	if c.failed {
+		status = "error"
+	}
+
+	o.metrics.commands.Inc(listenerMemcached, name, status)
+}
+
+func (o *DefaultServer) memcachedReadData(c *memcachedConn, sizeArg []byte) bool {
+	size, err := strconv.Atoi(string(sizeArg))
+	if err != nil || size < 0 {
+		c.error(memcachedErrFormat)
+		return false
+	}
+
+	if size > memcachedMaxValueSize {
+		c.error(memcachedErrTooLarge)
+		c.quit = true
+
+		return false
+	}
+
+	if cap(c.data) < size+2 {
+		c.data = make([]byte, size+2)
+	}
+
+	c.data = c.data[:size+2]
+
+	_, err = io.ReadFull(c.r, c.data)
+	if err != nil {
+		c.quit = true
+		return false
+	}
+
+	if c.data[size] != '\r' || c.data[size+1] != '\n' {
+		c.error(memcachedErrChunk)
+		return false
+	}
+
+	c.data = c.data[:size]
+
+	return true
+}
+
+func memcachedExpiration(now time.Time, arg []byte) (time.Time, bool, error) {
+	exptime, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	var expiration time.Time
+
+	switch {
+	case exptime == 0:
+		return storages.NoExpiration, true, nil
+	case exptime < 0:
+		return time.Time{}, false, nil
+	case exptime <= memcachedRelativeExptime:
+		expiration = now.Add(time.Duration(exptime) * time.Second)
+	case exptime > maxSeconds:
+		// a unix time out of int64 nanoseconds would wrap around
+		return storages.NoExpiration, true, nil
+	default:
+		expiration = time.Unix(exptime, 0)
+	}
+
+	if expiration.After(storages.NoExpiration) {
+		expiration = storages.NoExpiration
+	}
+
+	return expiration, expiration.After(now), nil
+}
+
+func (o *DefaultServer) memcachedGet(c *memcachedConn, args [][]byte) {
+	o.memcachedRetrieve(c, args, false)
+}
+
+func (o *DefaultServer) memcachedGets(c *memcachedConn, args [][]byte) {
+	o.memcachedRetrieve(c, args, true)
+}
+
+func (o *DefaultServer) memcachedRetrieve(c *memcachedConn, keys [][]byte, cas bool) {
+	for _, key := range keys {
+		if len(key) > memcachedMaxKeySize {
+			c.error(memcachedErrFormat)
+			return
+		}
+	}
+
+	for _, key := range keys {
+		buf, meta, err := o.storages.GetMeta(key)
+		if err != nil {
+			continue
+		}
+
+		value := buf.Bytes()
+
+		_, _ = c.w.WriteString("VALUE ")
+		_, _ = c.w.Write(key)
+		_, _ = c.w.WriteString(" ")
+		_, _ = c.w.WriteString(strconv.FormatUint(uint64(meta.Flags), 10))
+		_, _ = c.w.WriteString(" ")
+		_, _ = c.w.WriteString(strconv.Itoa(len(value)))
+
+		if cas {
+			_, _ = c.w.WriteString(" ")
+			_, _ = c.w.WriteString(strconv.FormatUint(meta.Version, 10))
+		}
+
+		_, _ = c.w.WriteString("\r\n")
+		_, _ = c.w.Write(value)
+		_, _ = c.w.WriteString("\r\n")
+
+		buf.Free()
+	}
+
+	c.reply(memcachedEnd)
+}
+
+func (o *DefaultServer) memcachedSet(c *memcachedConn, args [][]byte) {
+	o.memcachedStore(c, args, storages.Always)
+}
+
+func (o *DefaultServer) memcachedAdd(c *memcachedConn, args [][]byte) {
+	o.memcachedStore(c, args, storages.IfNotExists)
+}
+
+func (o *DefaultServer) memcachedReplace(c *memcachedConn, args [][]byte) {
+	o.memcachedStore(c, args, storages.IfExists)
+}
+
+func (o *DefaultServer) memcachedStore(c *memcachedConn, args [][]byte, cond storages.Condition) {
+	key := args[0]
+
+	flags, expiration, alive, ok := o.memcachedStoreArgs(c, args)
+	if !ok {
+		return
+	}
+
+	if !alive {
+		o.memcachedStoreExpired(c, key, cond)
+		return
+	}
+
+	err := o.storages.AddUntilIf(key, c.data, flags, expiration, cond)
+
+	switch err {
+	case nil:
+		c.reply(memcachedStored)
+	case storages.ErrConditionFailed:
+		c.reply(memcachedNotStored)
+	default:
+		c.storageError(err)
+	}
+}
+
+func (o *DefaultServer) memcachedStoreArgs(c *memcachedConn, args [][]byte) (uint32, time.Time, bool, bool) {
+	flags, err := strconv.ParseUint(string(args[1]), 10, 32)
+	if err != nil || len(args[0]) > memcachedMaxKeySize {
+		c.error(memcachedErrFormat)
+		return 0, time.Time{}, false, false
+	}
+
+	expiration, alive, err := memcachedExpiration(o.conf.TimeSource().Now(), args[2])
+	if err != nil {
+		c.error(memcachedErrFormat)
+		return 0, time.Time{}, false, false
+	}
+
+	return uint32(flags), expiration, alive, true
+}
+
+func (o *DefaultServer) memcachedCas(c *memcachedConn, args [][]byte) {
+	key := args[0]
+
+	version, err := strconv.ParseUint(string(args[4]), 10, 64)
+	if err != nil {
+		c.error(memcachedErrFormat)
+		return
+	}
+
+	flags, expiration, alive, ok := o.memcachedStoreArgs(c, args)
+	if !ok {
+		return
+	}
+
+	if alive {
+		err = o.storages.AddUntilIf(key, c.data, flags, expiration, storages.IfVersion(version))
+	} else {
+		err = o.storages.DeleteIf(key, storages.IfVersion(version))
+	}
+
+	switch err {
+	case nil:
+		c.reply(memcachedStored)
+	case storages.ErrConditionFailed:
+		// the key is looked up again to choose a reply, it may be changed since the condition is checked
+		if _, err := o.storages.TTL(key); err != nil {
+			c.reply(memcachedNotFound)
+		} else {
+			c.reply(memcachedExists)
+		}
+	case storages.ErrKeyNotFound, storages.ErrKeyExpired:
+		c.reply(memcachedNotFound)
+	default:
+		c.storageError(err)
+	}
+}
+
+func (o *DefaultServer) memcachedStoreExpired(c *memcachedConn, key []byte, cond storages.Condition) {
+	// a condition of storing is checked against a stored key, which is deleted by the expired value
+	switch err := o.storages.DeleteIf(key, cond); err {
+	case nil, storages.ErrKeyNotFound, storages.ErrKeyExpired:
+		c.reply(memcachedStored)
+	case storages.ErrConditionFailed:
+		c.reply(memcachedNotStored)
+	default:
+		c.storageError(err)
+	}
+}
+
+func (o *DefaultServer) memcachedDelete(c *memcachedConn, args [][]byte) {
+	err := o.storages.Delete(args[0])
+
+	switch err {
+	case nil:
+		c.reply(memcachedDeleted)
+	case storages.ErrKeyNotFound, storages.ErrKeyExpired:
+		c.reply(memcachedNotFound)
+	default:
+		c.storageError(err)
+	}
+}
+
+func (o *DefaultServer) memcachedTouch(c *memcachedConn, args [][]byte) {
+	expiration, alive, err := memcachedExpiration(o.conf.TimeSource().Now(), args[1])
+	if err != nil {
+		c.error(memcachedErrFormat)
+		return
+	}
+
+	switch {
+	case !alive:
+		err = o.storages.Delete(args[0])
+	case expiration.Equal(storages.NoExpiration):
+		_, err = o.storages.Persist(args[0])
+	default:
+		err = o.storages.ExpireAt(args[0], expiration)
+	}
+
+	switch err {
+	case nil:
+		c.reply(memcachedTouched)
+	case storages.ErrKeyNotFound, storages.ErrKeyExpired:
+		c.reply(memcachedNotFound)
+	default:
+		c.storageError(err)
+	}
+}
+
+func (o *DefaultServer) memcachedIncr(c *memcachedConn, args [][]byte) {
+	o.memcachedDelta(c, args, true)
+}
+
+func (o *DefaultServer) memcachedDecr(c *memcachedConn, args [][]byte) {
+	o.memcachedDelta(c, args, false)
+}
+
+func (o *DefaultServer) memcachedDelta(c *memcachedConn, args [][]byte, incr bool) {
+	delta, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		c.error(memcachedErrDelta)
+		return
+	}
+
+	var result uint64
+
+	err = o.storages.Update(args[0], 0, func(value []byte, exists bool) ([]byte, error) {
+		if !exists {
+			return nil, storages.ErrKeyNotFound
+		}
+
+		n, err := strconv.ParseUint(string(bytes.TrimSpace(value)), 10, 64)
+		if err != nil {
+			return nil, ErrNonNumeric
+		}
+
+		switch {
+		case incr:
+			n += delta
+		case delta > n:
+			n = 0
+		default:
+			n -= delta
+		}
+
+		result = n
+
+		return strconv.AppendUint(nil, n, 10), nil
+	})
+
+	switch err {
+	case nil:
+		c.reply(strconv.FormatUint(result, 10))
+	case storages.ErrKeyNotFound:
+		c.reply(memcachedNotFound)
+	case ErrNonNumeric:
+		c.error(memcachedErrNonNumeric)
+	default:
+		c.storageError(err)
+	}
+}
+
+func (o *DefaultServer) memcachedFlushAll(c *memcachedConn, args [][]byte) {
+	var delay int64
+
+	if len(args) > 0 {
+		var err error
+
+		delay, err = strconv.ParseInt(string(args[0]), 10, 64)
+		if err != nil || delay < 0 {
+			c.error(memcachedErrFormat)
+			return
+		}
+	}
+
+	if delay > 0 {
+		if delay > maxSeconds {
+			delay = maxSeconds
+		}
+
+		go o.memcachedFlushAfter(time.Duration(delay) * time.Second)
+
+		c.reply(memcachedOK)
+
+		return
+	}
+
+	if err := o.memcachedFlush(); err != nil {
+		c.storageError(err)
+		return
+	}
+
+	c.reply(memcachedOK)
+}
+
+func (o *DefaultServer) memcachedFlushAfter(delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		_ = o.memcachedFlush()
+	case <-o.cancelCtx.Done():
+	}
+}
+
+func (o *DefaultServer) memcachedFlush() error {
+	deleted, err := o.storages.Flush(o.cancelCtx)
+	if err != nil {
+		o.logger.Error("memcached: failed to flush keys", zap.Int("deleted", deleted), zap.Error(err))
+
+		return err
+	}
+
+	o.logger.Info("memcached: keys are flushed", zap.Int("deleted", deleted))
+
+	return nil
+}
+
+func (o *DefaultServer) memcachedStats(c *memcachedConn, args [][]byte) {
+	if len(args) > 0 {
+		c.reply(memcachedEnd)
+		return
+	}
+
+	var (
+		stats         = o.storages.Stats()
+		conns, reject = o.listenerConnections(listenerMemcached)
+		stat          = func(name string, value interface{}) {
+			_, _ = fmt.Fprintf(c.w, "STAT %s %v\r\n", name, value)
+		}
+	)
+
+	stat("pid", os.Getpid())
+	stat("uptime", int64(time.Since(o.started).Seconds()))
+	stat("time", o.conf.TimeSource().Now().Unix())
+	stat("version", memcachedServerVersion)
+	stat("curr_connections", conns)
+	stat("rejected_connections", reject)
+	stat("curr_items", stats.Keys)
+	stat("bytes", stats.LiveBytes)
+	stat("get_hits", stats.Hits)
+	stat("get_misses", stats.Misses)
+	stat("get_expired", stats.Expired)
+	stat("evictions", stats.Evictions)
+	stat("limit_maxbytes", o.conf.MaxMemory())
+
+	c.reply(memcachedEnd)
+}
+
+func (o *DefaultServer) memcachedVersion(c *memcachedConn, _ [][]byte) {
+	c.reply("VERSION " + memcachedServerVersion)
+}
+
+func (o *DefaultServer) memcachedQuit(c *memcachedConn, _ [][]byte) {
+	c.quit = true
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemcachedExpiration(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	testSuites := []struct {
+		arg        string
+		expiration time.Time
+		alive      bool
+		err        bool
+	}{
+		{arg: "0", expiration: storages.NoExpiration, alive: true},
+		{arg: "1", expiration: now.Add(time.Second), alive: true},
+		{arg: "2592000", expiration: now.Add(30 * 24 * time.Hour), alive: true},
+		// longer than 30 days is a unix time
+		{arg: "2592001", expiration: time.Unix(2592001, 0)},
+		{arg: "1599999999", expiration: time.Unix(1599999999, 0)},
+		{arg: "1600000000", expiration: now},
+		{arg: "1600000001", expiration: time.Unix(1600000001, 0), alive: true},
+		{arg: "9223372036854775807", expiration: storages.NoExpiration, alive: true},
+		{arg: "-1"},
+		{arg: "-9223372036854775808"},
+		{arg: "abc", err: true},
+		{arg: "1.5", err: true},
+		{arg: "9223372036854775808", err: true},
+		{arg: "", err: true},
+	}
+
+	for _, test := range testSuites {
+		expiration, alive, err := memcachedExpiration(now, []byte(test.arg))
+		if test.err {
+			assert.Error(t, err, test.arg)
+			continue
+		}
+
+		require.NoError(t, err, test.arg)
+		assert.True(t, test.expiration.Equal(expiration), test.arg+": "+expiration.String())
+		assert.Equal(t, test.alive, alive, test.arg)
+	}
+}
+
+func TestServeMemcached(t *testing.T) {
+	longKey := strings.Repeat("k", memcachedMaxKeySize+1)
+
+	testSuites := []struct {
+		name     string
+		script   string
+		expected []string
+	}{
+		{
+			name:     "unknown command",
+			script:   "unknown key\r\n\r\n",
+			expected: []string{memcachedErrCommand, memcachedErrCommand},
+		},
+		{
+			name:     "wrong number of arguments",
+			script:   "get\r\ndelete k1 k2\r\nset k 0 0\r\nversion 1\r\n",
+			expected: []string{memcachedErrCommand, memcachedErrCommand, memcachedErrCommand, memcachedErrCommand},
+		},
+		{
+			name:     "spaces between arguments",
+			script:   "set  k   7 0 2 \r\nv1\r\n get   k \r\n",
+			expected: []string{memcachedStored, "VALUE k 7 2", "v1", memcachedEnd},
+		},
+		{
+			name:     "flags",
+			script:   "set k 4294967295 0 1\r\n1\r\nincr k 2\r\ntouch k 100\r\nget k missed\r\n",
+			expected: []string{memcachedStored, "3", memcachedTouched, "VALUE k 4294967295 1", "3", memcachedEnd},
+		},
+		{
+			name:     "bad flags",
+			script:   "set k 4294967296 0 1\r\n1\r\nset k -1 0 1\r\n1\r\nget k\r\n",
+			expected: []string{memcachedErrFormat, memcachedErrFormat, memcachedEnd},
+		},
+		{
+			name:     "bad exptime",
+			script:   "set k 0 1h 1\r\n1\r\ntouch k 1.5\r\n",
+			expected: []string{memcachedErrFormat, memcachedErrFormat},
+		},
+		{
+			name:     "bad size",
+			script:   "set k 0 0 -1\r\nset k 0 0 x\r\n",
+			expected: []string{memcachedErrFormat, memcachedErrFormat},
+		},
+		{
+			name:     "bad data chunk",
+			script:   "set k 0 0 1\r\n12\r\nget k\r\n",
+			expected: []string{memcachedErrChunk, memcachedErrCommand, memcachedEnd},
+		},
+		{
+			name:     "long key",
+			script:   "set " + longKey + " 0 0 1\r\n1\r\nget " + longKey + "\r\n",
+			expected: []string{memcachedErrFormat, memcachedErrFormat},
+		},
+		{
+			name:     "noreply",
+			script:   "set k 0 0 1 noreply\r\n1\r\nincr k 1 noreply\r\ndelete missed noreply\r\nget k\r\n",
+			expected: []string{"VALUE k 0 1", "2", memcachedEnd},
+		},
+		{
+			name:     "conditions",
+			script:   "add k 0 0 1\r\n1\r\nadd k 0 0 1\r\n2\r\nreplace missed 0 0 1\r\n1\r\nreplace k 0 0 1\r\n3\r\nget k\r\n",
+			expected: []string{memcachedStored, memcachedNotStored, memcachedNotStored, memcachedStored, "VALUE k 0 1", "3", memcachedEnd},
+		},
+		{
+			name:     "cas",
+			script:   "cas missed 0 0 1 1\r\n1\r\ncas k 0 0 1 x\r\n1\r\n",
+			expected: []string{memcachedNotFound, memcachedErrFormat},
+		},
+		{
+			name:     "counters",
+			script:   "set k 0 0 1\r\n5\r\ndecr k 9\r\nincr k x\r\nincr missed 1\r\nset s 0 0 1\r\na\r\nincr s 1\r\n",
+			expected: []string{memcachedStored, "0", memcachedErrDelta, memcachedNotFound, memcachedStored, memcachedErrNonNumeric},
+		},
+		{
+			name:     "past exptime deletes a key",
+			script:   "set k 0 0 1\r\n1\r\nset k 0 -1 1\r\n2\r\nget k\r\n",
+			expected: []string{memcachedStored, memcachedStored, memcachedEnd},
+		},
+		{
+			name:     "too large value",
+			script:   "set k 0 0 " + strconv.Itoa(memcachedMaxValueSize+1) + "\r\nget k\r\n",
+			expected: []string{memcachedErrTooLarge},
+		},
+		{
+			name:     "long line",
+			script:   "get " + strings.Repeat("k ", memcachedMaxLine) + "\r\nget k\r\n",
+			expected: []string{memcachedErrLine},
+		},
+		{
+			name:     "quit",
+			script:   "version\r\nquit\r\nget k\r\n",
+			expected: []string{"VERSION " + memcachedServerVersion},
+		},
+	}
+
+	for _, test := range testSuites {
+		srv := newTestServer(t, nil)
+		conn := newMockConn(test.script)
+
+		srv.serveMemcached(conn)
+
+		assert.Equal(t, test.expected, readTestLines(t, conn.w.Bytes()), test.name)
+	}
+}
+
+func TestServeMemcached_Gets(t *testing.T) {
+	srv := newTestServer(t, nil)
+	require.NoError(t, srv.storages.AddUntilIf([]byte("k"), []byte("v"), 3, storages.NoExpiration, storages.Always))
+
+	buf, meta, err := srv.storages.GetMeta([]byte("k"))
+	require.NoError(t, err)
+	buf.Free()
+
+	cas := strconv.FormatUint(meta.Version, 10)
+	conn := newMockConn("gets k\r\ncas k 5 0 2 " + cas + "\r\nv2\r\ncas k 5 0 2 " + cas + "\r\nv3\r\nget k\r\n")
+
+	srv.serveMemcached(conn)
+
+	assert.Equal(t, []string{
+		"VALUE k 3 1 " + cas, "v", memcachedEnd,
+		memcachedStored,
+		memcachedExists,
+		"VALUE k 5 2", "v2", memcachedEnd,
+	}, readTestLines(t, conn.w.Bytes()))
+}
+
+func TestServeMemcached_Loading(t *testing.T) {
+	srv := newTestServer(t, nil)
+	atomic.StoreInt32(&srv.state, stateStarting)
+
+	conn := newMockConn("get k\r\nset k 0 0 1\r\n1\r\nversion\r\n")
+
+	srv.serveMemcached(conn)
+
+	assert.Equal(t, []string{
+		memcachedErrLoading,
+		memcachedErrLoading,
+		"VERSION " + memcachedServerVersion,
+	}, readTestLines(t, conn.w.Bytes()))
+}
+
+func TestDefaultServer_MemcachedFlushAfter(t *testing.T) {
+	srv := newTestServer(t, nil)
+	require.NoError(t, srv.storages.Add([]byte("k"), []byte("v"), time.Minute))
+
+	srv.cancel()
+	srv.memcachedFlushAfter(time.Hour)
+
+	buf, err := srv.storages.Get([]byte("k"))
+	require.NoError(t, err, "stopped server doesn't flush")
+	buf.Free()
+
+	srv = newTestServer(t, nil)
+	require.NoError(t, srv.storages.Add([]byte("k"), []byte("v"), time.Minute))
+
+	srv.memcachedFlushAfter(time.Millisecond)
+
+	_, err = srv.storages.Get([]byte("k"))
+	assert.Equal(t, storages.ErrKeyNotFound, err)
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/7phs/kvs/internal/metrics"
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	pathMetrics = "/metrics"
+
+	methodOther = "OTHER"
+)
+
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	requests    *metrics.Counter
+	duration    *metrics.Histogram
+	maintenance *metrics.Histogram
+	commands    *metrics.Counter
+}
+
+func newServerMetrics(storages storages.Storages) *serverMetrics {
+	registry := metrics.NewRegistry()
+
+	o := &serverMetrics{
+		registry: registry,
+		requests: registry.Counter("kvs_http_requests_total",
+			"Number of HTTP requests.", "method", "status"),
+		duration: registry.Histogram("kvs_http_request_duration_seconds",
+			"Duration of HTTP requests in seconds.", metrics.DefaultBuckets, "method", "status"),
+		maintenance: registry.Histogram("kvs_maintenance_duration_seconds",
+			"Duration of maintenance tasks in seconds.", metrics.DefaultBuckets, "id", "result"),
+		commands: registry.Counter("kvs_tcp_commands_total",
+			"Number of commands of TCP protocols.", "listener", "command", "status"),
+	}
+
+	registry.CounterFunc("kvs_storage_reads_total", "Number of reads of keys by a result.",
+		func(sample metrics.SampleFunc) {
+			stats := storages.Stats()
+
+			sample(float64(stats.Hits), "hit")
+			sample(float64(stats.Misses), "miss")
+			sample(float64(stats.Expired), "expired")
+		}, "result")
+
+	registry.GaugeFunc("kvs_keys", "Number of stored keys by a partition, including expired ones not cleaned yet.",
+		func(sample metrics.SampleFunc) {
+			stats := storages.Stats()
+
+			if len(stats.Partitions) == 0 {
+				sample(float64(stats.Keys), "0")
+				return
+			}
+
+			for i, partition := range stats.Partitions {
+				sample(float64(partition.Keys), strconv.Itoa(i))
+			}
+		}, "partition")
+
+	registry.GaugeFunc("kvs_chunks", "Number of chunks held by storages by a state.",
+		func(sample metrics.SampleFunc) {
+			var (
+				stats = storages.Stats()
+				inUse uint64
+			)
+
+			// chunks and queued chunks are counted at different moments
+			if stats.Chunks > stats.QueuedChunks {
+				inUse = stats.Chunks - stats.QueuedChunks
+			}
+
+			sample(float64(inUse), "in_use")
+			sample(float64(stats.QueuedChunks), "queued")
+		}, "state")
+
+	registry.GaugeFunc("kvs_memory_allocated_bytes", "Size of chunks held by storages.",
+		func(sample metrics.SampleFunc) {
+			sample(float64(storages.Stats().AllocatedBytes))
+		})
+
+	registry.GaugeFunc("kvs_memory_live_bytes", "Size of keys and values of stored records.",
+		func(sample metrics.SampleFunc) {
+			sample(float64(storages.Stats().LiveBytes))
+		})
+
+	registry.CounterFunc("kvs_evictions_total", "Number of evicted keys.",
+		func(sample metrics.SampleFunc) {
+			sample(float64(storages.Stats().Evictions))
+		})
+
+	registry.CounterFunc("kvs_collisions_total", "Number of different keys stored with the same hash.",
+		func(sample metrics.SampleFunc) {
+			sample(float64(storages.Stats().Collisions))
+		})
+
+	return o
+}
+
+func (o *serverMetrics) handler(handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		begin := time.Now()
+
+		handler(ctx)
+
+		var (
+			method = requestMethod(ctx)
+			status = strconv.Itoa(ctx.Response.StatusCode())
+		)
+
+		o.requests.Inc(method, status)
+		o.duration.Observe(time.Since(begin).Seconds(), method, status)
+	}
+}
+
+func (o *serverMetrics) observeListeners(listeners []*tcpListener) {
+	o.registry.GaugeFunc("kvs_tcp_connections", "Number of served connections of TCP protocols.",
+		func(sample metrics.SampleFunc) {
+			for _, listener := range listeners {
+				conns, _ := listener.Connections()
+				sample(float64(conns), listener.name)
+			}
+		}, "listener")
+
+	o.registry.CounterFunc("kvs_tcp_rejected_connections_total",
+		"Number of connections of TCP protocols rejected over the limit.",
+		func(sample metrics.SampleFunc) {
+			for _, listener := range listeners {
+				_, rejected := listener.Connections()
+				sample(float64(rejected), listener.name)
+			}
+		}, "listener")
+}
+
+func (o *serverMetrics) observeMaintenance(id string, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	o.maintenance.Observe(duration.Seconds(), id, result)
+}
+
+func (o *DefaultServer) metricsHandler(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		ctx.Error("Unsupported method", fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType(metrics.ContentType)
+
+	err := o.metrics.registry.Write(ctx)
+	if err != nil {
+		o.handlerError(ctx, err)
+	}
+}
+
+func requestMethod(ctx *fasthttp.RequestCtx) string {
+	method := string(ctx.Method())
+
+	switch method {
+	case http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodOptions:
+		return method
+	default:
+		return methodOther
+	}
+}
@@ -12,11 +12,13 @@ func NewLoggerHandler(logger *zap.Logger, handler fasthttp.RequestHandler) fasth
 		method := string(ctx.Method())
 		begin := time.Now()
 
-		defer logger.Debug(method,
-			zap.String("url", string(ctx.RequestURI())),
-			zap.Int("status", ctx.Response.StatusCode()),
-			zap.Duration("elapse", time.Since(begin)),
-		)
+		defer func() {
+			logger.Debug(method,
+				zap.String("url", string(ctx.RequestURI())),
+				zap.Int("status", ctx.Response.StatusCode()),
+				zap.Duration("elapse", time.Since(begin)),
+			)
+		}()
 
 		handler(ctx)
 	}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+var (
+	_ config.Config = (*mockConfig)(nil)
+	_ net.Conn      = (*mockConn)(nil)
+)
+
+type constantTime time.Time
+
+func (o constantTime) Now() time.Time {
+	return time.Time(o)
+}
+
+type mockConfig struct {
+	Exp    time.Duration
+	MaxT   time.Duration
+	TimeS  config.TimeSource
+	Prefix string
+	Root   bool
+}
+
+func (o *mockConfig) LogLevel() config.LogLevel {
+	return config.LogLevelInfo
+}
+
+func (o *mockConfig) Port() int {
+	return 0
+}
+
+func (o *mockConfig) Expiration() time.Duration {
+	return o.Exp
+}
+
+func (o *mockConfig) MaxTTL() time.Duration {
+	return o.MaxT
+}
+
+func (o *mockConfig) Maintenance() time.Duration {
+	return time.Minute
+}
+
+func (o *mockConfig) Mode() config.StorageMode {
+	return config.StorageModeMap
+}
+
+func (o *mockConfig) PreAllocated() int {
+	return 1024
+}
+
+func (o *mockConfig) MaxMemory() int64 {
+	return 0
+}
+
+func (o *mockConfig) EvictionPolicy() config.EvictionPolicy {
+	return config.EvictionNone
+}
+
+func (o *mockConfig) CompactionRatio() float64 {
+	return 0
+}
+
+func (o *mockConfig) KeysPrefix() string {
+	return o.Prefix
+}
+
+func (o *mockConfig) RootKeys() bool {
+	return o.Root
+}
+
+func (o *mockConfig) BinaryPort() int {
+	return 0
+}
+
+func (o *mockConfig) BinaryMaxConns() int {
+	return 0
+}
+
+func (o *mockConfig) RedisPort() int {
+	return 0
+}
+
+func (o *mockConfig) RedisMaxConns() int {
+	return 0
+}
+
+func (o *mockConfig) MemcachedPort() int {
+	return 0
+}
+
+func (o *mockConfig) MemcachedMaxConns() int {
+	return 0
+}
+
+func (o *mockConfig) GRPCPort() int {
+	return 0
+}
+
+func (o *mockConfig) TimeSource() config.TimeSource {
+	return o.TimeS
+}
+
+func (o *mockConfig) HashSeed() [config.HashSeedSize]byte {
+	return [config.HashSeedSize]byte{}
+}
+
+func (o *mockConfig) SnapshotPath() string {
+	return ""
+}
+
+func (o *mockConfig) SnapshotInterval() time.Duration {
+	return 0
+}
+
+func (o *mockConfig) JournalPath() string {
+	return ""
+}
+
+func (o *mockConfig) JournalFsync() config.FsyncPolicy {
+	return config.FsyncNever
+}
+
+// newTestServer builds a ready server over storages in memory. Keys live a minute and are served at the root.
+func newTestServer(t *testing.T, conf *mockConfig) *DefaultServer {
+	if conf == nil {
+		conf = &mockConfig{Root: true}
+	}
+
+	if conf.Exp == 0 {
+		conf.Exp = time.Minute
+	}
+
+	if conf.TimeS == nil {
+		conf.TimeS = constantTime(time.Now())
+	}
+
+	memoryPool := storages.NewMemoryPool(conf.PreAllocated(), conf.MaxMemory())
+
+	dict, err := storages.NewDataDictionary(conf.Mode(), memoryPool, conf.TimeSource())
+	require.NoError(t, err)
+
+	storage, err := storages.NewInMemStorages(conf, dict, nil)
+	require.NoError(t, err)
+
+	srv := NewServer(zap.NewNop(), conf, storages.NewBuckets(conf, storage, dict, memoryPool)).(*DefaultServer)
+	srv.Ready()
+
+	return srv
+}
+
+// serveTestRequest passes a request to the router of the server and returns its context with a response.
+func serveTestRequest(srv *DefaultServer, method, uri string, body string, headers map[string]string) *fasthttp.RequestCtx {
+	ctx := newTestRequestCtx(uri, headers)
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetBodyString(body)
+
+	srv.router.route(ctx)
+
+	return ctx
+}
+
+// mockConn reads a script of a client and collects replies of a server.
+type mockConn struct {
+	net.Conn
+
+	r io.Reader
+	w bytes.Buffer
+}
+
+func newMockConn(script string) *mockConn {
+	return &mockConn{
+		r: strings.NewReader(script),
+	}
+}
+
+func (o *mockConn) Read(p []byte) (int, error) {
+	return o.r.Read(p)
+}
+
+func (o *mockConn) Write(p []byte) (int, error) {
+	return o.w.Write(p)
+}
+
+// readTestLines splits replies by lines without line ends.
+func readTestLines(t *testing.T, data []byte) []string {
+	var (
+		lines   []string
+		scanner = bufio.NewScanner(bytes.NewReader(data))
+	)
+
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+
+	require.NoError(t, scanner.Err())
+
+	return lines
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	headerTTL      = "X-TTL"
+	headerExpireAt = "X-Expire-At"
+
+	argTTL      = "ttl"
+	argExpireAt = "expire_at"
+
+	maxSeconds = math.MaxInt64 / int64(time.Second)
+)
+
+func headerOrArg(ctx *fasthttp.RequestCtx, header, arg string) []byte {
+	if v := ctx.Request.Header.Peek(header); len(v) > 0 {
+		return v
+	}
+
+	return ctx.QueryArgs().Peek(arg)
+}
+
+func parseTTL(ctx *fasthttp.RequestCtx) (time.Duration, error) {
+	v := string(headerOrArg(ctx, headerTTL, argTTL))
+	if v == "" {
+		return 0, nil
+	}
+
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if seconds <= 0 || seconds > maxSeconds {
+			return 0, ErrBadRequest
+		}
+
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		return 0, ErrBadRequest
+	}
+
+	return ttl, nil
+}
+
+func parseExpireAt(ctx *fasthttp.RequestCtx) (time.Time, bool, error) {
+	v := string(headerOrArg(ctx, headerExpireAt, argExpireAt))
+	if v == "" {
+		return time.Time{}, false, nil
+	}
+
+	expireAt, err := time.Parse(time.RFC3339, v)
+	if seconds, parseErr := strconv.ParseInt(v, 10, 64); parseErr == nil {
+		if seconds > maxSeconds {
+			return time.Time{}, false, ErrBadRequest
+		}
+
+		expireAt, err = time.Unix(seconds, 0), nil
+	}
+
+	if err != nil || expireAt.After(storages.NoExpiration) {
+		return time.Time{}, false, ErrBadRequest
+	}
+
+	return expireAt, true, nil
+}
+
+func formatETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+type entityTag struct {
+	version uint64
+	isValue bool
+	weak    bool
+}
+
+func parseETags(v []byte) ([]entityTag, bool, error) {
+	if strings.TrimSpace(string(v)) == "*" {
+		return nil, true, nil
+	}
+
+	var tags []entityTag
+
+	for _, s := range strings.Split(string(v), ",") {
+		var tag entityTag
+
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "W/") {
+			tag.weak = true
+			s = s[2:]
+		}
+
+		n := len(s)
+		if n < 2 || s[0] != '"' || s[n-1] != '"' || strings.Contains(s[1:n-1], `"`) {
+			return nil, false, ErrBadRequest
+		}
+
+		version, err := strconv.ParseUint(s[1:n-1], 10, 64)
+		tag.version, tag.isValue = version, err == nil
+
+		tags = append(tags, tag)
+	}
+
+	return tags, false, nil
+}
+
+func matchETags(tags []entityTag, version uint64, strong bool) bool {
+	for _, tag := range tags {
+		if tag.isValue && tag.version == version && !(strong && tag.weak) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseCondition(ctx *fasthttp.RequestCtx) (storages.Condition, error) {
+	var (
+		ifMatch     = ctx.Request.Header.Peek(fasthttp.HeaderIfMatch)
+		ifNoneMatch = ctx.Request.Header.Peek(fasthttp.HeaderIfNoneMatch)
+	)
+
+	switch {
+	case len(ifMatch) > 0 && len(ifNoneMatch) > 0:
+		return storages.Always, ErrBadRequest
+
+	case len(ifNoneMatch) > 0:
+		_, wildcard, err := parseETags(ifNoneMatch)
+		if err != nil || !wildcard {
+			return storages.Always, ErrBadRequest
+		}
+
+		return storages.IfNotExists, nil
+
+	case len(ifMatch) > 0:
+		tags, wildcard, err := parseETags(ifMatch)
+		switch {
+		case err != nil:
+			return storages.Always, err
+		case wildcard:
+			return storages.IfExists, nil
+		case len(tags) != 1:
+			return storages.Always, ErrBadRequest
+		case !tags[0].isValue || tags[0].weak:
+			return storages.Always, storages.ErrConditionFailed
+		}
+
+		return storages.IfVersion(tags[0].version), nil
+
+	default:
+		return storages.Always, nil
+	}
+}
+
+func notModified(ctx *fasthttp.RequestCtx, version uint64) (bool, error) {
+	if ifMatch := ctx.Request.Header.Peek(fasthttp.HeaderIfMatch); len(ifMatch) > 0 {
+		tags, wildcard, err := parseETags(ifMatch)
+		if err != nil {
+			return false, err
+		}
+
+		if !wildcard && !matchETags(tags, version, true) {
+			return false, storages.ErrConditionFailed
+		}
+	}
+
+	ifNoneMatch := ctx.Request.Header.Peek(fasthttp.HeaderIfNoneMatch)
+	if len(ifNoneMatch) == 0 {
+		return false, nil
+	}
+
+	tags, wildcard, err := parseETags(ifNoneMatch)
+	if err != nil {
+		return false, err
+	}
+
+	return wildcard || matchETags(tags, version, false), nil
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func newTestRequestCtx(uri string, headers map[string]string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(uri)
+
+	for header, value := range headers {
+		ctx.Request.Header.Set(header, value)
+	}
+
+	return ctx
+}
+
+func TestParseTTL(t *testing.T) {
+	testSuites := []struct {
+		uri     string
+		headers map[string]string
+		ttl     time.Duration
+		err     error
+	}{
+		{uri: "/key"},
+		{uri: "/key?ttl=30", ttl: 30 * time.Second},
+		{uri: "/key?ttl=1m30s", ttl: 90 * time.Second},
+		{uri: "/key?ttl=30", headers: map[string]string{headerTTL: "1h"}, ttl: time.Hour},
+		{uri: "/key?ttl=0", err: ErrBadRequest},
+		{uri: "/key?ttl=-1", err: ErrBadRequest},
+		{uri: "/key?ttl=-1s", err: ErrBadRequest},
+		{uri: "/key?ttl=abc", err: ErrBadRequest},
+		{uri: "/key?ttl=9223372036", ttl: 9223372036 * time.Second},
+		{uri: "/key?ttl=9223372037", err: ErrBadRequest},
+		{uri: "/key?ttl=9223372036854775807", err: ErrBadRequest},
+		{uri: "/key?ttl=3000000h", err: ErrBadRequest},
+	}
+
+	for _, test := range testSuites {
+		ttl, err := parseTTL(newTestRequestCtx(test.uri, test.headers))
+		assert.Equal(t, test.err, err, test.uri)
+		assert.Equal(t, test.ttl, ttl, test.uri)
+	}
+}
+
+func TestParseExpireAt(t *testing.T) {
+	testSuites := []struct {
+		uri      string
+		headers  map[string]string
+		expireAt time.Time
+		ok       bool
+		err      error
+	}{
+		{uri: "/key"},
+		{uri: "/key?expire_at=1700000000", expireAt: time.Unix(1700000000, 0), ok: true},
+		{
+			uri:      "/key",
+			headers:  map[string]string{headerExpireAt: "2030-01-02T03:04:05Z"},
+			expireAt: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+			ok:       true,
+		},
+		// a past expiration is rejected by storages
+		{uri: "/key?expire_at=1", expireAt: time.Unix(1, 0), ok: true},
+		{uri: "/key?expire_at=9223372036", expireAt: time.Unix(9223372036, 0), ok: true},
+		{uri: "/key?expire_at=9223372037", err: ErrBadRequest},
+		{uri: "/key?expire_at=9223372036854775807", err: ErrBadRequest},
+		{uri: "/key?expire_at=2300-01-01T00:00:00Z", err: ErrBadRequest},
+		{uri: "/key?expire_at=tomorrow", err: ErrBadRequest},
+	}
+
+	for _, test := range testSuites {
+		expireAt, ok, err := parseExpireAt(newTestRequestCtx(test.uri, test.headers))
+		assert.Equal(t, test.err, err, test.uri)
+		assert.Equal(t, test.ok, ok, test.uri)
+		assert.True(t, test.expireAt.Equal(expireAt), test.uri)
+		assert.False(t, expireAt.After(storages.NoExpiration), test.uri)
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	ifMatch := func(v string) map[string]string {
+		return map[string]string{fasthttp.HeaderIfMatch: v}
+	}
+
+	ifNoneMatch := func(v string) map[string]string {
+		return map[string]string{fasthttp.HeaderIfNoneMatch: v}
+	}
+
+	testSuites := []struct {
+		headers map[string]string
+		cond    storages.Condition
+		err     error
+	}{
+		{cond: storages.Always},
+		{headers: ifNoneMatch("*"), cond: storages.IfNotExists},
+		{headers: ifNoneMatch(` * `), cond: storages.IfNotExists},
+		{headers: ifNoneMatch(`"1"`), err: ErrBadRequest},
+		{headers: ifNoneMatch(`1`), err: ErrBadRequest},
+		{headers: ifMatch("*"), cond: storages.IfExists},
+		{headers: ifMatch(`"12"`), cond: storages.IfVersion(12)},
+		{headers: ifMatch(` "12" `), cond: storages.IfVersion(12)},
+		{headers: ifMatch(`W/"12"`), err: storages.ErrConditionFailed},
+		{headers: ifMatch(`"abc"`), err: storages.ErrConditionFailed},
+		{headers: ifMatch(`12`), err: ErrBadRequest},
+		{headers: ifMatch(`"12`), err: ErrBadRequest},
+		{headers: ifMatch(`"1"2"`), err: ErrBadRequest},
+		{headers: ifMatch(`"1", "2"`), err: ErrBadRequest},
+		{headers: ifMatch(`"1",`), err: ErrBadRequest},
+		{
+			headers: map[string]string{fasthttp.HeaderIfMatch: `"1"`, fasthttp.HeaderIfNoneMatch: "*"},
+			err:     ErrBadRequest,
+		},
+	}
+
+	for _, test := range testSuites {
+		cond, err := parseCondition(newTestRequestCtx("/key", test.headers))
+
+		name := fmt.Sprint(test.headers)
+		assert.Equal(t, test.err, err, name)
+
+		if test.err == nil {
+			assert.Equal(t, test.cond, cond, name)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	const version = 12
+
+	testSuites := []struct {
+		ifMatch     string
+		ifNoneMatch string
+		notModified bool
+		err         error
+	}{
+		{},
+		{ifNoneMatch: "*", notModified: true},
+		{ifNoneMatch: `"12"`, notModified: true},
+		{ifNoneMatch: `W/"12"`, notModified: true},
+		{ifNoneMatch: `"1", W/"12"`, notModified: true},
+		{ifNoneMatch: `"1","abc"`},
+		{ifNoneMatch: `12`, err: ErrBadRequest},
+		{ifNoneMatch: `"1", 12`, err: ErrBadRequest},
+		{ifMatch: "*"},
+		{ifMatch: `"12"`},
+		{ifMatch: `"1", "12"`},
+		{ifMatch: `"1"`, err: storages.ErrConditionFailed},
+		{ifMatch: `W/"12"`, err: storages.ErrConditionFailed},
+		{ifMatch: `12`, err: ErrBadRequest},
+		{ifMatch: `"12"`, ifNoneMatch: `"12"`, notModified: true},
+		{ifMatch: `"12"`, ifNoneMatch: `"1"`},
+		{ifMatch: `"1"`, ifNoneMatch: `"12"`, err: storages.ErrConditionFailed},
+	}
+
+	for _, test := range testSuites {
+		headers := map[string]string{}
+
+		if test.ifMatch != "" {
+			headers[fasthttp.HeaderIfMatch] = test.ifMatch
+		}
+
+		if test.ifNoneMatch != "" {
+			headers[fasthttp.HeaderIfNoneMatch] = test.ifNoneMatch
+		}
+
+		unchanged, err := notModified(newTestRequestCtx("/key", headers), version)
+
+		name := fmt.Sprint(headers)
+		assert.Equal(t, test.err, err, name)
+		assert.Equal(t, test.notModified, unchanged, name)
+	}
+}
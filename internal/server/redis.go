@@ -0,0 +1,615 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/7phs/kvs/internal/storages"
+	"go.uber.org/zap"
+)
+
+const (
+	listenerRedis = "redis"
+
+	redisVersion = "7.0.0"
+
+	maxDuration = time.Duration(1<<63 - 1)
+
+	redisErrSyntax     = "ERR syntax error"
+	redisErrInteger    = "ERR value is not an integer or out of range"
+	redisErrOverflow   = "ERR increment or decrement would overflow"
+	redisErrExpire     = "ERR invalid expire time in '%s' command"
+	redisErrArgs       = "ERR wrong number of arguments for '%s' command"
+	redisErrOOM        = "OOM command not allowed when used memory > 'maxmemory'."
+	redisErrLoading    = "LOADING kvs is loading the dataset in memory"
+	redisErrInternal   = "ERR internal error"
+	redisErrProtocol   = "ERR Protocol error: invalid request"
+	redisErrUnknown    = "ERR unknown command '%s'"
+	redisErrNoProto    = "NOPROTO unsupported protocol version"
+	redisErrDB         = "ERR DB index is out of range"
+	redisErrSubcommand = "ERR unknown subcommand '%s'"
+)
+
+type redisCommand struct {
+	handler func(o *DefaultServer, c *redisConn, args [][]byte)
+	minArgs int
+	maxArgs int
+	ready   bool
+}
+
+var redisCommands = map[string]redisCommand{
+	"get":     {handler: (*DefaultServer).redisGet, minArgs: 1, maxArgs: 1, ready: true},
+	"set":     {handler: (*DefaultServer).redisSet, minArgs: 2, maxArgs: -1, ready: true},
+	"setnx":   {handler: (*DefaultServer).redisSetNX, minArgs: 2, maxArgs: 2, ready: true},
+	"getdel":  {handler: (*DefaultServer).redisGetDel, minArgs: 1, maxArgs: 1, ready: true},
+	"getex":   {handler: (*DefaultServer).redisGetEx, minArgs: 1, maxArgs: 3, ready: true},
+	"del":     {handler: (*DefaultServer).redisDel, minArgs: 1, maxArgs: -1, ready: true},
+	"exists":  {handler: (*DefaultServer).redisExists, minArgs: 1, maxArgs: -1, ready: true},
+	"expire":  {handler: (*DefaultServer).redisExpire, minArgs: 2, maxArgs: 2, ready: true},
+	"ttl":     {handler: (*DefaultServer).redisTTL, minArgs: 1, maxArgs: 1, ready: true},
+	"pttl":    {handler: (*DefaultServer).redisPTTL, minArgs: 1, maxArgs: 1, ready: true},
+	"persist": {handler: (*DefaultServer).redisPersist, minArgs: 1, maxArgs: 1, ready: true},
+	"incr":    {handler: (*DefaultServer).redisIncr, minArgs: 1, maxArgs: 1, ready: true},
+	"decr":    {handler: (*DefaultServer).redisDecr, minArgs: 1, maxArgs: 1, ready: true},
+	"incrby":  {handler: (*DefaultServer).redisIncrBy, minArgs: 2, maxArgs: 2, ready: true},
+	"decrby":  {handler: (*DefaultServer).redisDecrBy, minArgs: 2, maxArgs: 2, ready: true},
+	"mget":    {handler: (*DefaultServer).redisMGet, minArgs: 1, maxArgs: -1, ready: true},
+	"mset":    {handler: (*DefaultServer).redisMSet, minArgs: 2, maxArgs: -1, ready: true},
+	"dbsize":  {handler: (*DefaultServer).redisDBSize},
+	"info":    {handler: (*DefaultServer).redisInfo, maxArgs: -1},
+	"ping":    {handler: (*DefaultServer).redisPing, maxArgs: 1},
+	"hello":   {handler: (*DefaultServer).redisHello, maxArgs: -1},
+	"select":  {handler: (*DefaultServer).redisSelect, minArgs: 1, maxArgs: 1},
+	"client":  {handler: (*DefaultServer).redisClient, minArgs: 1, maxArgs: -1},
+	"command": {handler: (*DefaultServer).redisCommand, maxArgs: -1},
+	"quit":    {handler: (*DefaultServer).redisQuit, maxArgs: -1},
+}
+
+type redisConn struct {
+	id      uint64
+	r       *respReader
+	w       *respWriter
+	quit    bool
+	command string
+}
+
+var redisConnID uint64
+
+func (o *DefaultServer) serveRedis(conn net.Conn) {
+	w := newRESPWriter(conn)
+
+	c := &redisConn{
+		id: atomic.AddUint64(&redisConnID, 1),
+		r:  newRESPReader(flushingReader{r: conn, w: w.Writer}),
+		w:  w,
+	}
+
+	for !c.quit {
+		args, err := c.r.readCommand()
+		if err == ErrProtocol {
+			c.w.error(redisErrProtocol)
+			_ = c.w.Flush()
+
+			return
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				o.logger.Debug("redis: connection is closed",
+					zap.String("remote", conn.RemoteAddr().String()),
+					zap.Error(err),
+				)
+			}
+
+			return
+		}
+
+		o.redisExec(c, args)
+	}
+
+	_ = c.w.Flush()
+}
+
+func (o *DefaultServer) redisExec(c *redisConn, args [][]byte) {
+	var (
+		name       = strings.ToLower(string(args[0]))
+		cmd, known = redisCommands[name]
+	)
+
+	c.w.failed = false
+	c.command = name
+
+	switch {
+	case !known:
+		name = "unknown"
+		c.w.error(fmt.Sprintf(redisErrUnknown, args[0]))
+	case len(args)-1 < cmd.minArgs,
+		cmd.maxArgs >= 0 && len(args)-1 > cmd.maxArgs:
+		c.w.error(fmt.Sprintf(redisErrArgs, name))
+	case cmd.ready && o.isStarting():
+		c.w.error(redisErrLoading)
+	default:
+		cmd.handler(o, c, args[1:])
+	}
+
+	status := "ok"
+	if c.w.failed {
+		status = "error"
+	}
+
+	o.metrics.commands.Inc(listenerRedis, name, status)
+}
+
+func (c *redisConn) redisError(err error) {
+	switch err {
+	case storages.ErrOutOfLimit:
+		c.w.error(redisErrOOM)
+	case storages.ErrInvalidTTL:
+		c.w.error(fmt.Sprintf(redisErrExpire, c.command))
+	case storages.ErrNotInteger:
+		c.w.error(redisErrInteger)
+	case storages.ErrIntegerOverflow:
+		c.w.error(redisErrOverflow)
+	default:
+		c.w.error(redisErrInternal)
+	}
+}
+
+func (c *redisConn) value(value storages.Buffer, err error) {
+	switch err {
+	case nil:
+		c.w.bulk(value.Bytes())
+		value.Free()
+	case storages.ErrKeyNotFound, storages.ErrKeyExpired:
+		c.w.null()
+	default:
+		c.redisError(err)
+	}
+}
+
+func (o *DefaultServer) redisGet(c *redisConn, args [][]byte) {
+	c.value(o.storages.Get(args[0]))
+}
+
+func (o *DefaultServer) redisGetDel(c *redisConn, args [][]byte) {
+	c.value(o.storages.GetDelete(args[0]))
+}
+
+func (o *DefaultServer) redisGetEx(c *redisConn, args [][]byte) {
+	key := args[0]
+	option := ""
+
+	if len(args) > 1 {
+		option = strings.ToUpper(string(args[1]))
+	}
+
+	switch {
+	case option == "" && len(args) == 1:
+		c.value(o.storages.Get(key))
+		return
+	case option == "PERSIST" && len(args) == 2:
+		c.value(o.storages.GetExpireAt(key, storages.NoExpiration))
+		return
+	case len(args) != 3:
+		c.w.error(redisErrSyntax)
+		return
+	}
+
+	v, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		c.w.error(redisErrInteger)
+		return
+	}
+
+	unit := time.Second
+	if option == "PX" || option == "PXAT" {
+		unit = time.Millisecond
+	}
+
+	if v <= 0 || v > int64(maxDuration/unit) {
+		c.w.error(fmt.Sprintf(redisErrExpire, "getex"))
+		return
+	}
+
+	switch option {
+	case "EX", "PX":
+		c.value(o.storages.GetExpire(key, time.Duration(v)*unit))
+	case "EXAT", "PXAT":
+		buf, err := o.storages.GetExpireAt(key, time.Unix(0, 0).Add(time.Duration(v)*unit))
+		if err == storages.ErrInvalidTTL {
+			buf, err = o.storages.GetDelete(key)
+		}
+
+		c.value(buf, err)
+	default:
+		c.w.error(redisErrSyntax)
+	}
+}
+
+func (o *DefaultServer) redisSet(c *redisConn, args [][]byte) {
+	var (
+		ttl  = storages.Persistent
+		cond = storages.Always
+	)
+
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+
+		switch {
+		case option == "NX" && cond == storages.Always:
+			cond = storages.IfNotExists
+		case option == "XX" && cond == storages.Always:
+			cond = storages.IfExists
+		case (option == "EX" || option == "PX") && ttl == storages.Persistent && i+1 < len(args):
+			i++
+
+			v, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				c.w.error(redisErrInteger)
+				return
+			}
+
+			unit := time.Second
+			if option == "PX" {
+				unit = time.Millisecond
+			}
+
+			if v <= 0 || v > int64(maxDuration/unit) {
+				c.w.error(fmt.Sprintf(redisErrExpire, "set"))
+				return
+			}
+
+			ttl = time.Duration(v) * unit
+		default:
+			c.w.error(redisErrSyntax)
+			return
+		}
+	}
+
+	err := o.storages.AddIf(args[0], args[1], ttl, cond)
+
+	switch err {
+	case nil:
+		c.w.simple("OK")
+	case storages.ErrConditionFailed:
+		c.w.null()
+	default:
+		c.redisError(err)
+	}
+}
+
+func (o *DefaultServer) redisSetNX(c *redisConn, args [][]byte) {
+	err := o.storages.AddIf(args[0], args[1], storages.Persistent, storages.IfNotExists)
+
+	switch err {
+	case nil:
+		c.w.integer(1)
+	case storages.ErrConditionFailed:
+		c.w.integer(0)
+	default:
+		c.redisError(err)
+	}
+}
+
+func (o *DefaultServer) redisIncr(c *redisConn, args [][]byte) {
+	o.redisIncrement(c, args[0], 1)
+}
+
+func (o *DefaultServer) redisDecr(c *redisConn, args [][]byte) {
+	o.redisIncrement(c, args[0], -1)
+}
+
+func (o *DefaultServer) redisIncrBy(c *redisConn, args [][]byte) {
+	delta, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.w.error(redisErrInteger)
+		return
+	}
+
+	o.redisIncrement(c, args[0], delta)
+}
+
+func (o *DefaultServer) redisDecrBy(c *redisConn, args [][]byte) {
+	delta, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || delta == math.MinInt64 {
+		c.w.error(redisErrInteger)
+		return
+	}
+
+	o.redisIncrement(c, args[0], -delta)
+}
+
+func (o *DefaultServer) redisIncrement(c *redisConn, key []byte, delta int64) {
+	n, err := o.storages.Incr(key, delta, storages.Persistent)
+	if err != nil {
+		c.redisError(err)
+		return
+	}
+
+	c.w.integer(n)
+}
+
+func (o *DefaultServer) redisDel(c *redisConn, args [][]byte) {
+	var deleted int64
+
+	for _, key := range args {
+		err := o.storages.Delete(key)
+
+		switch err {
+		case nil:
+			deleted++
+		case storages.ErrKeyNotFound, storages.ErrKeyExpired:
+		default:
+			c.redisError(err)
+			return
+		}
+	}
+
+	c.w.integer(deleted)
+}
+
+func (o *DefaultServer) redisExists(c *redisConn, args [][]byte) {
+	var exist int64
+
+	for _, key := range args {
+		if _, err := o.storages.TTL(key); err == nil {
+			exist++
+		}
+	}
+
+	c.w.integer(exist)
+}
+
+func (o *DefaultServer) redisExpire(c *redisConn, args [][]byte) {
+	seconds, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.w.error(redisErrInteger)
+		return
+	}
+
+	if seconds > int64(maxDuration/time.Second) {
+		c.w.error(fmt.Sprintf(redisErrExpire, "expire"))
+		return
+	}
+
+	if seconds <= 0 {
+		err = o.storages.Delete(args[0])
+	} else {
+		err = o.storages.Expire(args[0], time.Duration(seconds)*time.Second)
+	}
+
+	switch err {
+	case nil:
+		c.w.integer(1)
+	case storages.ErrKeyNotFound, storages.ErrKeyExpired:
+		c.w.integer(0)
+	default:
+		c.redisError(err)
+	}
+}
+
+func (o *DefaultServer) redisTTL(c *redisConn, args [][]byte) {
+	o.redisRemaining(c, args[0], time.Second)
+}
+
+func (o *DefaultServer) redisPTTL(c *redisConn, args [][]byte) {
+	o.redisRemaining(c, args[0], time.Millisecond)
+}
+
+func (o *DefaultServer) redisRemaining(c *redisConn, key []byte, unit time.Duration) {
+	ttl, err := o.storages.TTL(key)
+
+	switch {
+	case err == storages.ErrKeyNotFound || err == storages.ErrKeyExpired:
+		c.w.integer(-2)
+	case err != nil:
+		c.redisError(err)
+	case ttl == storages.Persistent:
+		c.w.integer(-1)
+	default:
+		c.w.integer(int64((ttl + unit/2) / unit))
+	}
+}
+
+func (o *DefaultServer) redisPersist(c *redisConn, args [][]byte) {
+	persisted, err := o.storages.Persist(args[0])
+
+	switch err {
+	case nil:
+		if persisted {
+			c.w.integer(1)
+		} else {
+			c.w.integer(0)
+		}
+	case storages.ErrKeyNotFound, storages.ErrKeyExpired:
+		c.w.integer(0)
+	default:
+		c.redisError(err)
+	}
+}
+
+func (o *DefaultServer) redisMGet(c *redisConn, args [][]byte) {
+	values, errs := o.storages.GetBatch(args)
+
+	c.w.array(len(values))
+
+	for i := range values {
+		if errs[i] != nil {
+			c.w.null()
+			continue
+		}
+
+		c.w.bulk(values[i].Bytes())
+		values[i].Free()
+	}
+}
+
+func (o *DefaultServer) redisMSet(c *redisConn, args [][]byte) {
+	if len(args)%2 != 0 {
+		c.w.error(fmt.Sprintf(redisErrArgs, "mset"))
+		return
+	}
+
+	items := make([]storages.BatchItem, 0, len(args)/2)
+
+	for i := 0; i < len(args); i += 2 {
+		items = append(items, storages.BatchItem{Key: args[i], Value: args[i+1], TTL: storages.Persistent})
+	}
+
+	if err := o.storages.AddAll(items); err != nil {
+		c.redisError(err)
+		return
+	}
+
+	c.w.simple("OK")
+}
+
+func (o *DefaultServer) redisDBSize(c *redisConn, _ [][]byte) {
+	c.w.integer(int64(o.storages.Stats().Keys))
+}
+
+func (o *DefaultServer) redisInfo(c *redisConn, args [][]byte) {
+	var (
+		stats    = o.storages.Stats()
+		sections = map[string]bool{}
+		all      = len(args) == 0
+		buf      bytes.Buffer
+	)
+
+	for _, arg := range args {
+		section := strings.ToLower(string(arg))
+		all = all || section == "all" || section == "default" || section == "everything"
+		sections[section] = true
+	}
+
+	section := func(name string) bool {
+		if !all && !sections[strings.ToLower(name)] {
+			return false
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString("\r\n")
+		}
+
+		buf.WriteString("# " + name + "\r\n")
+
+		return true
+	}
+
+	field := func(name string, value interface{}) {
+		fmt.Fprintf(&buf, "%s:%v\r\n", name, value)
+	}
+
+	if section("Server") {
+		field("redis_version", redisVersion)
+		field("kvs_version", Version)
+		field("redis_mode", "standalone")
+		field("tcp_port", o.conf.RedisPort())
+		field("uptime_in_seconds", int64(time.Since(o.started).Seconds()))
+	}
+
+	if section("Clients") {
+		conns, _ := o.listenerConnections(listenerRedis)
+
+		field("connected_clients", conns)
+	}
+
+	if section("Memory") {
+		field("used_memory", stats.AllocatedBytes)
+		field("used_memory_dataset", stats.LiveBytes)
+		field("maxmemory", o.conf.MaxMemory())
+		field("maxmemory_policy", o.conf.EvictionPolicy())
+	}
+
+	if section("Stats") {
+		field("keyspace_hits", stats.Hits)
+		field("keyspace_misses", stats.Misses+stats.Expired)
+		field("evicted_keys", stats.Evictions)
+	}
+
+	if section("Keyspace") && stats.Keys > 0 {
+		field("db0", fmt.Sprintf("keys=%d,expires=%d,avg_ttl=0", stats.Keys, stats.Volatile))
+	}
+
+	c.w.bulk(buf.Bytes())
+}
+
+func (o *DefaultServer) redisPing(c *redisConn, args [][]byte) {
+	if len(args) == 0 {
+		c.w.simple("PONG")
+		return
+	}
+
+	c.w.bulk(args[0])
+}
+
+func (o *DefaultServer) redisHello(c *redisConn, args [][]byte) {
+	if len(args) > 0 {
+		proto, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			c.w.error("ERR Protocol version is not an integer or out of range")
+			return
+		}
+
+		if proto != 2 && proto != 3 {
+			c.w.error(redisErrNoProto)
+			return
+		}
+
+		c.w.proto = proto
+	}
+
+	c.w.mapHeader(7)
+	c.w.bulkString("server")
+	c.w.bulkString("kvs")
+	c.w.bulkString("version")
+	c.w.bulkString(redisVersion)
+	c.w.bulkString("proto")
+	c.w.integer(int64(c.w.proto))
+	c.w.bulkString("id")
+	c.w.integer(int64(c.id))
+	c.w.bulkString("mode")
+	c.w.bulkString("standalone")
+	c.w.bulkString("role")
+	c.w.bulkString("master")
+	c.w.bulkString("modules")
+	c.w.array(0)
+}
+
+func (o *DefaultServer) redisSelect(c *redisConn, args [][]byte) {
+	if string(args[0]) != "0" {
+		c.w.error(redisErrDB)
+		return
+	}
+
+	c.w.simple("OK")
+}
+
+func (o *DefaultServer) redisClient(c *redisConn, args [][]byte) {
+	switch strings.ToUpper(string(args[0])) {
+	case "ID":
+		c.w.integer(int64(c.id))
+	case "SETNAME", "SETINFO":
+		c.w.simple("OK")
+	case "GETNAME":
+		c.w.null()
+	default:
+		c.w.error(fmt.Sprintf(redisErrSubcommand, args[0]))
+	}
+}
+
+func (o *DefaultServer) redisCommand(c *redisConn, _ [][]byte) {
+	c.w.array(0)
+}
+
+func (o *DefaultServer) redisQuit(c *redisConn, _ [][]byte) {
+	c.w.simple("OK")
+	c.quit = true
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// redisScript joins inline commands of a client.
+func redisScript(commands ...string) string {
+	return strings.Join(commands, "\r\n") + "\r\n"
+}
+
+func TestServeRedis(t *testing.T) {
+	testSuites := []struct {
+		name     string
+		script   string
+		expected []string
+	}{
+		{
+			name:     "set without an expiration",
+			script:   redisScript("SET k v", "TTL k", "PTTL k", "PERSIST k"),
+			expected: []string{"+OK", ":-1", ":-1", ":0"},
+		},
+		{
+			name:     "set with an expiration",
+			script:   redisScript("SET k v EX 100", "TTL k", "PERSIST k", "TTL k", "PERSIST k"),
+			expected: []string{"+OK", ":100", ":1", ":-1", ":0"},
+		},
+		{
+			name:     "set replaces an expiration",
+			script:   redisScript("SET k v PX 1500", "PTTL k", "SET k v", "TTL k"),
+			expected: []string{"+OK", ":1500", "+OK", ":-1"},
+		},
+		{
+			name:     "keys created without an expiration",
+			script:   redisScript("SETNX a 1", "INCR b", "MSET c 1 d 2", "TTL a", "TTL b", "TTL c", "TTL d"),
+			expected: []string{":1", ":1", "+OK", ":-1", ":-1", ":-1", ":-1"},
+		},
+		{
+			name:     "missed key",
+			script:   redisScript("TTL missed", "PERSIST missed", "GET missed"),
+			expected: []string{":-2", ":0", "$-1"},
+		},
+		{
+			name:     "conditions",
+			script:   redisScript("SET k 1 XX", "SET k 1 NX", "SET k 2 NX", "SET k 3 XX", "GET k"),
+			expected: []string{"$-1", "+OK", "$-1", "+OK", "$1", "3"},
+		},
+		{
+			name:   "bad options of set",
+			script: redisScript("SET k v EX", "SET k v EX 0", "SET k v EX x", "SET k v NX XX", "SET k v EX 1 PX 1"),
+			expected: []string{
+				"-" + redisErrSyntax,
+				"-" + fmt.Sprintf(redisErrExpire, "set"),
+				"-" + redisErrInteger,
+				"-" + redisErrSyntax,
+				"-" + redisErrSyntax,
+			},
+		},
+		{
+			name:     "past expiration of getex deletes a key",
+			script:   redisScript("SET k v", "GETEX k EXAT 1", "getex k PXAT 1000", "EXISTS k"),
+			expected: []string{"+OK", "$1", "v", "$-1", ":0"},
+		},
+		{
+			name:     "bad expiration is reported for the command",
+			script:   redisScript("SET k v", "GETEX k EXAT 0", "getex k PX -1"),
+			expected: []string{"+OK", "-" + fmt.Sprintf(redisErrExpire, "getex"), "-" + fmt.Sprintf(redisErrExpire, "getex")},
+		},
+	}
+
+	for _, test := range testSuites {
+		srv := newTestServer(t, nil)
+		conn := newMockConn(test.script)
+
+		srv.serveRedis(conn)
+
+		assert.Equal(t, test.expected, readTestLines(t, conn.w.Bytes()), test.name)
+	}
+}
+
+func TestServeRedis_InfoKeyspace(t *testing.T) {
+	srv := newTestServer(t, nil)
+	conn := newMockConn(redisScript("SET a 1", "SET b 2 EX 100", "SET c 3", "INFO keyspace"))
+
+	srv.serveRedis(conn)
+
+	assert.Equal(t, []string{
+		"+OK", "+OK", "+OK",
+		"$44", "# Keyspace", "db0:keys=3,expires=1,avg_ttl=0", "",
+	}, readTestLines(t, conn.w.Bytes()))
+}
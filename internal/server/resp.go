@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+)
+
+const (
+	respMaxArgs       = 1024 * 1024
+	respMaxBulkSize   = 512 * 1024 * 1024
+	respMaxInlineSize = 64 * 1024
+	respMaxRetained   = 1 << 20
+
+	ErrProtocol Error = "protocol_error"
+)
+
+type respReader struct {
+	r *bufio.Reader
+
+	buf     []byte
+	offsets []int
+	args    [][]byte
+}
+
+func newRESPReader(r io.Reader) *respReader {
+	return &respReader{
+		r: bufio.NewReaderSize(r, respMaxInlineSize),
+	}
+}
+
+func (o *respReader) readCommand() ([][]byte, error) {
+	if cap(o.buf) > respMaxRetained {
+		o.buf = nil
+	}
+
+	for {
+		line, err := o.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] != '*' {
+			o.args = append(o.args[:0], bytes.Fields(line)...)
+			if len(o.args) == 0 {
+				continue
+			}
+
+			return o.args, nil
+		}
+
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n > respMaxArgs {
+			return nil, ErrProtocol
+		}
+
+		if n <= 0 {
+			continue
+		}
+
+		return o.readArgs(n)
+	}
+}
+
+func (o *respReader) readArgs(n int) ([][]byte, error) {
+	o.buf = o.buf[:0]
+	o.offsets = o.offsets[:0]
+
+	for i := 0; i < n; i++ {
+		line, err := o.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 || line[0] != '$' {
+			return nil, ErrProtocol
+		}
+
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > respMaxBulkSize {
+			return nil, ErrProtocol
+		}
+
+		start := len(o.buf)
+		end := start + size + 2
+
+		// the buffer grows by received data, not by a declared length
+		for read := start; read < end; read = len(o.buf) {
+			step := end - read
+			if step > respMaxInlineSize {
+				step = respMaxInlineSize
+			}
+
+			if cap(o.buf) < read+step {
+				buf := make([]byte, read, 2*cap(o.buf)+step)
+				copy(buf, o.buf)
+				o.buf = buf
+			}
+
+			o.buf = o.buf[:read+step]
+
+			_, err = io.ReadFull(o.r, o.buf[read:])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if o.buf[end-2] != '\r' || o.buf[end-1] != '\n' {
+			return nil, ErrProtocol
+		}
+
+		o.buf = o.buf[:end-2]
+		o.offsets = append(o.offsets, start)
+	}
+
+	o.offsets = append(o.offsets, len(o.buf))
+	o.args = o.args[:0]
+
+	for i := 0; i < n; i++ {
+		o.args = append(o.args, o.buf[o.offsets[i]:o.offsets[i+1]:o.offsets[i+1]])
+	}
+
+	return o.args, nil
+}
+
+func (o *respReader) readLine() ([]byte, error) {
+	return readLine(o.r)
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, ErrProtocol
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+
+	return line, nil
+}
+
+type respWriter struct {
+	*bufio.Writer
+
+	proto  int
+	failed bool
+	num    []byte
+}
+
+func newRESPWriter(w io.Writer) *respWriter {
+	return &respWriter{
+		Writer: bufio.NewWriter(w),
+		proto:  2,
+	}
+}
+
+func (o *respWriter) simple(s string) {
+	_ = o.WriteByte('+')
+	_, _ = o.WriteString(s)
+	_, _ = o.WriteString("\r\n")
+}
+
+func (o *respWriter) error(s string) {
+	o.failed = true
+
+	_ = o.WriteByte('-')
+	_, _ = o.WriteString(s)
+	_, _ = o.WriteString("\r\n")
+}
+
+func (o *respWriter) integer(v int64) {
+	o.header(':', v)
+}
+
+func (o *respWriter) bulk(b []byte) {
+	o.header('$', int64(len(b)))
+	_, _ = o.Write(b)
+	_, _ = o.WriteString("\r\n")
+}
+
+func (o *respWriter) bulkString(s string) {
+	o.header('$', int64(len(s)))
+	_, _ = o.WriteString(s)
+	_, _ = o.WriteString("\r\n")
+}
+
+func (o *respWriter) null() {
+	if o.proto == 3 {
+		_, _ = o.WriteString("_\r\n")
+		return
+	}
+
+	_, _ = o.WriteString("$-1\r\n")
+}
+
+func (o *respWriter) array(n int) {
+	o.header('*', int64(n))
+}
+
+func (o *respWriter) mapHeader(n int) {
+	if o.proto == 3 {
+		o.header('%', int64(n))
+		return
+	}
+
+	o.array(2 * n)
+}
+
+func (o *respWriter) header(kind byte, v int64) {
+	o.num = strconv.AppendInt(append(o.num[:0], kind), v, 10)
+	o.num = append(o.num, '\r', '\n')
+
+	_, _ = o.Write(o.num)
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESPReader_ReadCommand(t *testing.T) {
+	testSuites := []struct {
+		name     string
+		script   string
+		expected [][]string
+		err      error
+	}{
+		{
+			name:     "inline",
+			script:   "PING\r\nset  k\tv \r\nget k\n",
+			expected: [][]string{{"PING"}, {"set", "k", "v"}, {"get", "k"}},
+			err:      io.EOF,
+		},
+		{
+			name:     "empty lines and arrays are skipped",
+			script:   "\r\n  \r\n*0\r\n*-1\r\nPING\r\n",
+			expected: [][]string{{"PING"}},
+			err:      io.EOF,
+		},
+		{
+			name:     "multibulk",
+			script:   "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$4\r\nv\r\n1\r\n*1\r\n$4\r\nPING\r\n",
+			expected: [][]string{{"SET", "k", "v\r\n1"}, {"PING"}},
+			err:      io.EOF,
+		},
+		{
+			name:     "empty bulk string",
+			script:   "*2\r\n$3\r\nGET\r\n$0\r\n\r\n",
+			expected: [][]string{{"GET", ""}},
+			err:      io.EOF,
+		},
+		{
+			name:   "bad count",
+			script: "*x\r\n",
+			err:    ErrProtocol,
+		},
+		{
+			name:   "too many arguments",
+			script: "*1048577\r\n",
+			err:    ErrProtocol,
+		},
+		{
+			name:   "not a bulk string",
+			script: "*1\r\n:1\r\n",
+			err:    ErrProtocol,
+		},
+		{
+			name:   "bad length",
+			script: "*1\r\n$x\r\nPING\r\n",
+			err:    ErrProtocol,
+		},
+		{
+			name:   "negative length",
+			script: "*1\r\n$-1\r\n",
+			err:    ErrProtocol,
+		},
+		{
+			name:   "too large bulk string",
+			script: "*1\r\n$536870913\r\n",
+			err:    ErrProtocol,
+		},
+		{
+			name:   "bulk string longer than length",
+			script: "*1\r\n$2\r\nPING\r\n",
+			err:    ErrProtocol,
+		},
+		{
+			name:   "truncated bulk string",
+			script: "*2\r\n$4\r\nPING\r\n$4\r\nPI",
+			err:    io.ErrUnexpectedEOF,
+		},
+		{
+			name:   "truncated array",
+			script: "*2\r\n$4\r\nPING\r\n",
+			err:    io.EOF,
+		},
+		{
+			name:   "long line",
+			script: strings.Repeat("k", respMaxInlineSize) + "\r\n",
+			err:    ErrProtocol,
+		},
+	}
+
+	for _, test := range testSuites {
+		readers := map[string]io.Reader{
+			"whole":    strings.NewReader(test.script),
+			"one byte": iotest.OneByteReader(strings.NewReader(test.script)),
+		}
+
+		for kind, r := range readers {
+			var (
+				reader   = newRESPReader(r)
+				commands [][]string
+				err      error
+			)
+
+			for {
+				var args [][]byte
+
+				args, err = reader.readCommand()
+				if err != nil {
+					break
+				}
+
+				command := make([]string, 0, len(args))
+				for _, arg := range args {
+					command = append(command, string(arg))
+				}
+
+				commands = append(commands, command)
+			}
+
+			assert.Equal(t, test.expected, commands, test.name+": "+kind)
+			assert.Equal(t, test.err, err, test.name+": "+kind)
+		}
+	}
+}
+
+func TestRESPReader_ReadCommandReusesBuffer(t *testing.T) {
+	reader := newRESPReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$2\r\nk1\r\n*2\r\n$3\r\nGET\r\n$2\r\nk2\r\n"))
+
+	args, err := reader.readCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("GET"), []byte("k1")}, args)
+
+	// an argument doesn't overwrite the next one by appending
+	_ = append(args[0], 'X')
+	assert.Equal(t, []byte("k1"), args[1])
+
+	args, err = reader.readCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("GET"), []byte("k2")}, args)
+}
+
+func TestRESPReader_ReadCommandGrowsByData(t *testing.T) {
+	reader := newRESPReader(strings.NewReader("*1\r\n$536870912\r\nPING"))
+
+	_, err := reader.readCommand()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.True(t, cap(reader.buf) <= 2*respMaxInlineSize, cap(reader.buf))
+
+	value := strings.Repeat("v", 3*respMaxInlineSize+1)
+	reader = newRESPReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"))
+
+	args, err := reader.readCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("GET"), []byte(value)}, args)
+}
+
+func TestRESPWriter(t *testing.T) {
+	testSuites := []struct {
+		proto    int
+		expected string
+	}{
+		{proto: 2, expected: "+OK\r\n-ERR x\r\n:-5\r\n$1\r\nv\r\n$0\r\n\r\n$-1\r\n*2\r\n*2\r\n"},
+		{proto: 3, expected: "+OK\r\n-ERR x\r\n:-5\r\n$1\r\nv\r\n$0\r\n\r\n_\r\n*2\r\n%1\r\n"},
+	}
+
+	for _, test := range testSuites {
+		var buf bytes.Buffer
+
+		w := newRESPWriter(&buf)
+		w.proto = test.proto
+
+		w.simple("OK")
+		w.error("ERR x")
+		w.integer(-5)
+		w.bulk([]byte("v"))
+		w.bulkString("")
+		w.null()
+		w.array(2)
+		w.mapHeader(1)
+
+		assert.NoError(t, w.Flush())
+		assert.Equal(t, test.expected, buf.String(), test.proto)
+		assert.True(t, w.failed)
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+
+	"github.com/valyala/fasthttp"
+)
+
+type KeyHandler func(ctx *fasthttp.RequestCtx, key []byte)
+
+type router struct {
+	routes   map[string]fasthttp.RequestHandler
+	prefixes []prefixRoute
+	prefix   []byte
+	rootKeys bool
+	keys     KeyHandler
+}
+
+type prefixRoute struct {
+	prefix  []byte
+	handler KeyHandler
+}
+
+func newRouter(prefix string, rootKeys bool, keys KeyHandler) *router {
+	return &router{
+		routes:   make(map[string]fasthttp.RequestHandler),
+		prefix:   []byte(prefix),
+		rootKeys: rootKeys || prefix == "",
+		keys:     keys,
+	}
+}
+
+func (o *router) handle(path string, handler fasthttp.RequestHandler) {
+	o.routes[path] = handler
+}
+
+func (o *router) handlePrefix(prefix string, handler KeyHandler) {
+	o.prefixes = append(o.prefixes, prefixRoute{
+		prefix:  []byte(prefix),
+		handler: handler,
+	})
+}
+
+func (o *router) route(ctx *fasthttp.RequestCtx) {
+	path := ctx.Path()
+
+	if handler, ok := o.routes[string(path)]; ok {
+		handler(ctx)
+		return
+	}
+
+	for _, route := range o.prefixes {
+		if underPrefix(path, route.prefix) {
+			route.handler(ctx, path[len(route.prefix):])
+			return
+		}
+	}
+
+	if key, ok := o.key(path); ok {
+		o.keys(ctx, key)
+		return
+	}
+
+	ctx.Error("Not found", fasthttp.StatusNotFound)
+}
+
+func (o *router) key(path []byte) ([]byte, bool) {
+	if len(o.prefix) > 0 && bytes.HasPrefix(path, o.prefix) {
+		if key := path[len(o.prefix):]; len(key) > 0 && key[0] == '/' {
+			return key, true
+		}
+	}
+
+	if o.rootKeys {
+		return path, true
+	}
+
+	return nil, false
+}
+
+func underPrefix(path, prefix []byte) bool {
+	if !bytes.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || prefix[len(prefix)-1] == '/' || path[len(prefix)] == '/'
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouter(t *testing.T) {
+	testSuites := []struct {
+		prefix   string
+		rootKeys bool
+		path     string
+		expected string
+	}{
+		{prefix: "/v1/kv", path: "/v1/kv/a/b", expected: "key /a/b"},
+		{prefix: "/v1/kv", path: "/v1/kv/_touch/a", expected: "key /_touch/a"},
+		{prefix: "/v1/kv", path: "/v1/kv/_health", expected: "key /_health"},
+		{prefix: "/v1/kv", path: "/v1/kv/", expected: "key /"},
+		{prefix: "/v1/kv", path: "/v1/kv", expected: "not found"},
+		{prefix: "/v1/kv", path: "/v1/kvx/a", expected: "not found"},
+		{prefix: "/v1/kv", path: "/a", expected: "not found"},
+		{prefix: "/v1/kv", path: "/_health", expected: "route /_health"},
+		{prefix: "/v1/kv", path: "/_touch/a", expected: "touch /a"},
+		{prefix: "/v1/kv", path: "/_touchy", expected: "not found"},
+		{prefix: "/v1/kv", rootKeys: true, path: "/v1/kv/a", expected: "key /a"},
+		{prefix: "/v1/kv", rootKeys: true, path: "/a", expected: "key /a"},
+		{prefix: "/v1/kv", rootKeys: true, path: "/v1/kv", expected: "key /v1/kv"},
+		{prefix: "/v1/kv", rootKeys: true, path: "/v1/kvx/a", expected: "key /v1/kvx/a"},
+		// paths of admin APIs shadow root keys
+		{prefix: "/v1/kv", rootKeys: true, path: "/_health", expected: "route /_health"},
+		{prefix: "/v1/kv", rootKeys: true, path: "/_touch/a", expected: "touch /a"},
+		{prefix: "/v1/kv", rootKeys: true, path: "/_touch", expected: "touch "},
+		{prefix: "/v1/kv", rootKeys: true, path: "/_buckets/b/a", expected: "buckets b/a"},
+		// a path continuing a segment of an operation is a root key
+		{prefix: "/v1/kv", rootKeys: true, path: "/_touchy", expected: "key /_touchy"},
+		{prefix: "/v1/kv", rootKeys: true, path: "/_healthz", expected: "key /_healthz"},
+		{prefix: "/v1/kv", rootKeys: true, path: "/_bucketsx/a", expected: "key /_bucketsx/a"},
+		// an empty prefix serves keys at the root
+		{prefix: "", path: "/a", expected: "key /a"},
+		{prefix: "", path: "/_health", expected: "route /_health"},
+	}
+
+	for _, test := range testSuites {
+		var served string
+
+		r := newRouter(test.prefix, test.rootKeys, func(_ *fasthttp.RequestCtx, key []byte) {
+			served = "key " + string(key)
+		})
+		r.handle(pathHealth, func(*fasthttp.RequestCtx) {
+			served = "route " + pathHealth
+		})
+		r.handlePrefix(pathTouchPrefix, func(_ *fasthttp.RequestCtx, key []byte) {
+			served = "touch " + string(key)
+		})
+		r.handlePrefix(pathBucketsPrefix, func(_ *fasthttp.RequestCtx, key []byte) {
+			served = "buckets " + string(key)
+		})
+
+		ctx := newTestRequestCtx(test.path, nil)
+		r.route(ctx)
+
+		if ctx.Response.StatusCode() == fasthttp.StatusNotFound {
+			served = "not found"
+		}
+
+		assert.Equal(t, test.expected, served, test.prefix+" "+test.path)
+	}
+}
+
+func TestServer_RootKeys(t *testing.T) {
+	srv := newTestServer(t, &mockConfig{Prefix: "/v1/kv", Root: true})
+
+	ctx := serveTestRequest(srv, http.MethodPost, "/_touchy", "root", nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodGet, "/v1/kv/_touchy", "", nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "root", string(ctx.Response.Body()))
+
+	ctx = serveTestRequest(srv, http.MethodPost, "/v1/kv/_ttl/a", "prefixed", nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	// an operation path isn't a root key
+	ctx = serveTestRequest(srv, http.MethodGet, "/_ttl/a", "", nil)
+	assert.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodGet, "/_ttl/_ttl/a", "", nil)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	srv = newTestServer(t, &mockConfig{Prefix: "/v1/kv"})
+
+	ctx = serveTestRequest(srv, http.MethodPost, "/a", "root", nil)
+	assert.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+}
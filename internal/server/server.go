@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/7phs/kvs/internal/config"
@@ -11,10 +13,12 @@ import (
 	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 )
 
 type Server interface {
 	Start() error
+	Ready()
 	Stop()
 }
 
@@ -29,41 +33,148 @@ type DefaultServer struct {
 	cancel    func()
 
 	storages storages.Storages
+	buckets  *storages.Buckets
+	metrics  *serverMetrics
+	router   *router
+
+	listeners []*tcpListener
+	grpc      *grpc.Server
+
+	conf      config.Config
+	started   time.Time
+	state     int32
+	readyCh   chan struct{}
+	readyOnce sync.Once
 }
 
 func NewServer(
 	logger *zap.Logger,
 
 	conf config.Config,
-	storages storages.Storages,
+	buckets *storages.Buckets,
+	maintenance ...Maintenance,
 ) Server {
 	cancelCtx, cancel := context.WithCancel(context.Background())
+	defaultStorages := buckets.Default().Storages()
+	serverMetrics := newServerMetrics(defaultStorages)
 
 	srv := &DefaultServer{
 		logger:              logger,
-		storages:            storages,
+		storages:            defaultStorages,
+		buckets:             buckets,
 		port:                conf.Port(),
 		maintenanceInterval: conf.Maintenance(),
 
 		cancelCtx: cancelCtx,
 		cancel:    cancel,
 
-		maintenance: NewGroupMaintenance(logger, storages),
+		metrics:     serverMetrics,
+		conf:        conf,
+		started:     time.Now(),
+		readyCh:     make(chan struct{}),
+		maintenance: NewGroupMaintenance(logger, append([]Maintenance{buckets}, maintenance...)...),
+	}
+
+	srv.maintenance.observe = serverMetrics.observeMaintenance
+
+	srv.router = newRouter(conf.KeysPrefix(), conf.RootKeys(), srv.keyHandler)
+	srv.router.handle(pathKeys, srv.keysHandler)
+	srv.router.handle(pathBatchGet, srv.batchGetHandler)
+	srv.router.handle(pathBatchSet, srv.batchSetHandler)
+	srv.router.handle(pathMetrics, srv.metricsHandler)
+	srv.router.handle(pathHealth, srv.healthHandler)
+	srv.router.handle(pathReady, srv.readyHandler)
+	srv.router.handle(pathInfo, srv.infoHandler)
+	srv.router.handle(pathAdminBuckets, srv.adminBucketsHandler)
+	srv.router.handlePrefix(pathAdminBucketsPrefix, srv.adminBucketHandler)
+	srv.router.handlePrefix(pathBucketsPrefix, srv.bucketKeyHandler)
+	srv.router.handlePrefix(pathIncrPrefix, srv.operationHandler(http.MethodPost, srv.incrHandler))
+	srv.router.handlePrefix(pathDecrPrefix, srv.operationHandler(http.MethodPost, srv.decrHandler))
+	srv.router.handlePrefix(pathTouchPrefix, srv.operationHandler(http.MethodPost, srv.touchHandler))
+	srv.router.handlePrefix(pathPersistPrefix, srv.operationHandler(http.MethodPost, srv.persistHandler))
+	srv.router.handlePrefix(pathTTLPrefix, srv.operationHandler(http.MethodGet, srv.ttlHandler))
+	srv.router.handlePrefix(pathGetDelPrefix, srv.operationHandler(http.MethodPost, srv.getDeleteHandler))
+	srv.router.handlePrefix(pathGetExPrefix, srv.operationHandler(http.MethodPost, srv.getExpireHandler))
+
+	if conf.BinaryPort() > 0 {
+		srv.listeners = append(srv.listeners,
+			newTCPListener(logger, listenerBinary, conf.BinaryPort(), conf.BinaryMaxConns(), srv.serveBinary))
+	}
+
+	if conf.RedisPort() > 0 {
+		srv.listeners = append(srv.listeners,
+			newTCPListener(logger, listenerRedis, conf.RedisPort(), conf.RedisMaxConns(), srv.serveRedis))
+	}
+
+	if conf.MemcachedPort() > 0 {
+		srv.listeners = append(srv.listeners,
+			newTCPListener(logger, listenerMemcached, conf.MemcachedPort(), conf.MemcachedMaxConns(), srv.serveMemcached))
+	}
+
+	serverMetrics.observeListeners(srv.listeners)
+
+	if conf.GRPCPort() > 0 {
+		srv.grpc = newGRPCServer(srv)
 	}
 
 	if conf.LogLevel() == config.LogLevelDebug {
-		srv.server.Handler = NewLoggerHandler(logger, srv.handler)
+		srv.server.Handler = serverMetrics.handler(NewLoggerHandler(logger, srv.router.route))
 	} else {
-		srv.server.Handler = srv.handler
+		srv.server.Handler = serverMetrics.handler(srv.router.route)
 	}
 
 	return srv
 }
 
-func (o *DefaultServer) handler(ctx *fasthttp.RequestCtx) {
+func (o *DefaultServer) keyHandler(ctx *fasthttp.RequestCtx, key []byte) {
+	bucket, err := o.bucket(ctx)
+	if err != nil {
+		o.handlerError(ctx, err)
+		return
+	}
+
+	o.serveKey(ctx, bucket.Storages(), key)
+}
+
+func (o *DefaultServer) operationHandler(
+	method string,
+	handler func(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte),
+) KeyHandler {
+	return func(ctx *fasthttp.RequestCtx, key []byte) {
+		if len(key) < 2 || key[0] != '/' {
+			ctx.Error("Not found", fasthttp.StatusNotFound)
+			return
+		}
+
+		if string(ctx.Method()) != method {
+			ctx.Error("Unsupported method", fasthttp.StatusMethodNotAllowed)
+			return
+		}
+
+		if o.isStarting() {
+			ctx.Error("Not ready", fasthttp.StatusServiceUnavailable)
+			return
+		}
+
+		bucket, err := o.bucket(ctx)
+		if err != nil {
+			o.handlerError(ctx, err)
+			return
+		}
+
+		handler(ctx, bucket.Storages(), key)
+	}
+}
+
+func (o *DefaultServer) serveKey(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) {
+	if o.isStarting() {
+		ctx.Error("Not ready", fasthttp.StatusServiceUnavailable)
+		return
+	}
+
 	switch string(ctx.Method()) {
 	case http.MethodGet:
-		body, err := o.storages.Get(ctx.Path())
+		body, meta, err := storage.GetMeta(key)
 		if err != nil {
 			o.handlerError(ctx, err)
 			return
@@ -71,11 +182,33 @@ func (o *DefaultServer) handler(ctx *fasthttp.RequestCtx) {
 
 		defer body.Free()
 
+		ctx.Response.Header.Set(fasthttp.HeaderETag, formatETag(meta.Version))
+
+		unchanged, err := notModified(ctx, meta.Version)
+		if err != nil {
+			o.handlerError(ctx, err)
+			return
+		}
+
+		if unchanged {
+			ctx.SetStatusCode(fasthttp.StatusNotModified)
+			return
+		}
+
 		ctx.SetStatusCode(fasthttp.StatusOK)
 		ctx.SetBody(body.Bytes())
 
 	case http.MethodPost:
-		err := o.storages.Add(ctx.Path(), ctx.Request.Body())
+		err := o.add(ctx, storage, key)
+		if err != nil {
+			o.handlerError(ctx, err)
+			return
+		}
+
+		ctx.SetStatusCode(fasthttp.StatusOK)
+
+	case http.MethodDelete:
+		err := storage.Delete(key)
 		if err != nil {
 			o.handlerError(ctx, err)
 			return
@@ -88,11 +221,49 @@ func (o *DefaultServer) handler(ctx *fasthttp.RequestCtx) {
 	}
 }
 
+func (o *DefaultServer) add(ctx *fasthttp.RequestCtx, storage storages.Storages, key []byte) error {
+	cond, err := parseCondition(ctx)
+	if err != nil {
+		return err
+	}
+
+	expireAt, ok, err := parseExpireAt(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return storage.AddUntilIf(key, ctx.Request.Body(), 0, expireAt, cond)
+	}
+
+	ttl, err := parseTTL(ctx)
+	if err != nil {
+		return err
+	}
+
+	return storage.AddIf(key, ctx.Request.Body(), ttl, cond)
+}
+
 func (o *DefaultServer) handlerError(ctx *fasthttp.RequestCtx, err error) {
 	switch err {
 	case storages.ErrKeyNotFound,
-		storages.ErrKeyExpired:
+		storages.ErrKeyExpired,
+		storages.ErrBucketNotFound:
 		ctx.Error("Not found", fasthttp.StatusNotFound)
+	case ErrBadRequest,
+		storages.ErrInvalidTTL,
+		storages.ErrInvalidBucket,
+		storages.ErrMemoryFloor,
+		storages.ErrUnsupportedMode,
+		storages.ErrDefaultBucketDrop:
+		ctx.Error("Bad request", fasthttp.StatusBadRequest)
+	case storages.ErrBucketExists:
+		ctx.Error("Conflict", fasthttp.StatusConflict)
+	case storages.ErrConditionFailed:
+		ctx.Error("Precondition failed", fasthttp.StatusPreconditionFailed)
+	case storages.ErrNotInteger,
+		storages.ErrIntegerOverflow:
+		ctx.Error("Conflict", fasthttp.StatusConflict)
 	case storages.ErrOutOfLimit:
 		ctx.Error("Out of limit", fasthttp.StatusInsufficientStorage)
 	default:
@@ -104,6 +275,13 @@ func (o *DefaultServer) Start() error {
 	wg, ctx := errgroup.WithContext(o.cancelCtx)
 
 	wg.Go(func() error {
+		// a maintenance could save a snapshot of partly restored storages
+		select {
+		case <-o.readyCh:
+		case <-ctx.Done():
+			return nil
+		}
+
 		o.logger.Info("maintenance: start")
 
 		o.maintenance.Start(ctx, o.maintenanceInterval)
@@ -121,12 +299,42 @@ func (o *DefaultServer) Start() error {
 		return o.server.ListenAndServe(port)
 	})
 
+	if o.grpc != nil {
+		wg.Go(func() error {
+			err := o.grpcListenAndServe()
+			if err != nil {
+				o.logger.Error("grpc: failed to listen",
+					zap.Error(err),
+				)
+			}
+
+			return err
+		})
+	}
+
+	for _, listener := range o.listeners {
+		listener := listener
+
+		wg.Go(func() error {
+			err := listener.ListenAndServe()
+			if err != nil {
+				o.logger.Error(listener.name+": failed to listen",
+					zap.Error(err),
+				)
+			}
+
+			return err
+		})
+	}
+
 	return wg.Wait()
 }
 
 func (o *DefaultServer) Stop() {
 	var wg errgroup.Group
 
+	atomic.StoreInt32(&o.state, stateStopping)
+
 	wg.Go(func() error {
 		o.logger.Info("http: shutdown")
 
@@ -135,6 +343,29 @@ func (o *DefaultServer) Stop() {
 		return err
 	})
 
+	for _, listener := range o.listeners {
+		listener := listener
+
+		wg.Go(func() error {
+			o.logger.Info(listener.name + ": shutdown")
+
+			listener.Shutdown()
+
+			return nil
+		})
+	}
+
+	if o.grpc != nil {
+		wg.Go(func() error {
+			o.logger.Info("grpc: shutdown")
+
+			// watching streams are finished by cancelling below
+			o.grpc.GracefulStop()
+
+			return nil
+		})
+	}
+
 	o.logger.Info("maintenance: shutdown")
 
 	o.cancel()
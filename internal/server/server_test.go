@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestServeKey_Preconditions(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	ctx := serveTestRequest(srv, http.MethodPost, "/key", "v1", map[string]string{fasthttp.HeaderIfMatch: "*"})
+	assert.Equal(t, fasthttp.StatusPreconditionFailed, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodPost, "/key", "v1", map[string]string{fasthttp.HeaderIfNoneMatch: "*"})
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodGet, "/key", "", nil)
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	etag := string(ctx.Response.Header.Peek(fasthttp.HeaderETag))
+	require.NotEmpty(t, etag)
+
+	testSuites := []struct {
+		name        string
+		ifMatch     string
+		ifNoneMatch string
+		status      int
+		body        string
+	}{
+		{name: "if-none-match", ifNoneMatch: etag, status: fasthttp.StatusNotModified},
+		{name: "if-none-match weak", ifNoneMatch: "W/" + etag, status: fasthttp.StatusNotModified},
+		{name: "if-none-match any", ifNoneMatch: "*", status: fasthttp.StatusNotModified},
+		{name: "if-none-match other", ifNoneMatch: `"0"`, status: fasthttp.StatusOK, body: "v1"},
+		{name: "if-match", ifMatch: etag, status: fasthttp.StatusOK, body: "v1"},
+		{name: "if-match other", ifMatch: `"0"`, status: fasthttp.StatusPreconditionFailed},
+		{name: "if-match weak", ifMatch: "W/" + etag, status: fasthttp.StatusPreconditionFailed},
+		{name: "if-match and if-none-match", ifMatch: etag, ifNoneMatch: etag, status: fasthttp.StatusNotModified},
+		{name: "if-match first", ifMatch: `"0"`, ifNoneMatch: etag, status: fasthttp.StatusPreconditionFailed},
+		{name: "unquoted", ifNoneMatch: etag[1 : len(etag)-1], status: fasthttp.StatusBadRequest},
+	}
+
+	for _, test := range testSuites {
+		headers := map[string]string{}
+
+		if test.ifMatch != "" {
+			headers[fasthttp.HeaderIfMatch] = test.ifMatch
+		}
+
+		if test.ifNoneMatch != "" {
+			headers[fasthttp.HeaderIfNoneMatch] = test.ifNoneMatch
+		}
+
+		ctx = serveTestRequest(srv, http.MethodGet, "/key", "", headers)
+		assert.Equal(t, test.status, ctx.Response.StatusCode(), test.name)
+
+		if test.body != "" {
+			assert.Equal(t, test.body, string(ctx.Response.Body()), test.name)
+		}
+	}
+
+	// compare-and-swap by the tag of a read
+	ctx = serveTestRequest(srv, http.MethodPost, "/key", "v2", map[string]string{fasthttp.HeaderIfMatch: "W/" + etag})
+	assert.Equal(t, fasthttp.StatusPreconditionFailed, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodPost, "/key", "v2", map[string]string{fasthttp.HeaderIfMatch: etag})
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodPost, "/key", "v3", map[string]string{fasthttp.HeaderIfMatch: etag})
+	assert.Equal(t, fasthttp.StatusPreconditionFailed, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodPost, "/key", "v3", map[string]string{fasthttp.HeaderIfNoneMatch: "*"})
+	assert.Equal(t, fasthttp.StatusPreconditionFailed, ctx.Response.StatusCode())
+
+	ctx = serveTestRequest(srv, http.MethodGet, "/key", "", map[string]string{fasthttp.HeaderIfNoneMatch: etag})
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "v2", string(ctx.Response.Body()))
+	assert.NotEqual(t, etag, string(ctx.Response.Header.Peek(fasthttp.HeaderETag)))
+}
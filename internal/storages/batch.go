@@ -0,0 +1,82 @@
+package storages
+
+import (
+	"sync"
+	"time"
+)
+
+type BatchItem struct {
+	Key   []byte
+	Value []byte
+	TTL   time.Duration
+}
+
+type batchRecord struct {
+	hash       uint64
+	key        []byte
+	value      []byte
+	expiration time.Time
+}
+
+type previousRecord struct {
+	value      []byte
+	flags      uint32
+	expiration time.Time
+	exists     bool
+}
+
+type batchDictionary interface {
+	GetBatch(hashes []uint64, keys [][]byte, values []Buffer, errs []error)
+	AddBatch(records []batchRecord, errs []error)
+}
+
+var (
+	_ batchDictionary = (*PartitionedDictionary)(nil)
+)
+
+func (o *PartitionedDictionary) GetBatch(hashes []uint64, keys [][]byte, values []Buffer, errs []error) {
+	o.fanOut(hashes, func(partition DataDictionary, i int) {
+		values[i], errs[i] = partition.Get(hashes[i], keys[i])
+	})
+}
+
+func (o *PartitionedDictionary) AddBatch(records []batchRecord, errs []error) {
+	hashes := make([]uint64, len(records))
+	for i := range records {
+		hashes[i] = records[i].hash
+	}
+
+	o.fanOut(hashes, func(partition DataDictionary, i int) {
+		rec := &records[i]
+		errs[i] = partition.Add(rec.hash, rec.key, rec.value, rec.expiration)
+	})
+}
+
+func (o *PartitionedDictionary) fanOut(hashes []uint64, fn func(partition DataDictionary, i int)) {
+	groups := make([][]int, len(o.partitions))
+
+	for i, hash := range hashes {
+		index := o.chunkKey(hash)
+		groups[index] = append(groups[index], i)
+	}
+
+	var wg sync.WaitGroup
+
+	for index, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(partition DataDictionary, group []int) {
+			defer wg.Done()
+
+			for _, i := range group {
+				fn(partition, i)
+			}
+		}(o.partitions[index], group)
+	}
+
+	wg.Wait()
+}
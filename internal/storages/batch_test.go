@@ -0,0 +1,129 @@
+package storages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemStorages_Batch(t *testing.T) {
+	for _, mode := range []config.StorageMode{config.StorageModeMap, config.StorageModePartitionedMap} {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			var (
+				items = make([]BatchItem, 0, 64)
+				keys  = make([][]byte, 0, 65)
+			)
+
+			for i := uint64(0); i < 64; i++ {
+				items = append(items, BatchItem{Key: toBytes(i), Value: toBytes(i * 10)})
+				keys = append(keys, toBytes(i))
+			}
+
+			items = append(items, BatchItem{Key: []byte("invalid"), Value: []byte("value"), TTL: -time.Second})
+			keys = append(keys, []byte("invalid"))
+
+			errs := storage.AddBatch(items)
+			require.Len(t, errs, len(items))
+
+			for i := 0; i < 64; i++ {
+				assert.NoError(t, errs[i])
+			}
+
+			assert.Equal(t, ErrInvalidTTL, errs[64])
+
+			values, errs := storage.GetBatch(keys)
+			require.Len(t, values, len(keys))
+			require.Len(t, errs, len(keys))
+
+			for i := uint64(0); i < 64; i++ {
+				require.NoError(t, errs[i])
+				assert.Equal(t, toBytes(i*10), values[i].Bytes())
+
+				values[i].Free()
+			}
+
+			assert.Equal(t, ErrKeyNotFound, errs[64])
+
+			stats := storage.Stats()
+			assert.Equal(t, uint64(64), stats.Keys)
+			assert.Equal(t, uint64(64), stats.Hits)
+			assert.Equal(t, uint64(1), stats.Misses)
+		})
+	}
+}
+
+func TestInMemStorages_AddBatchTTL(t *testing.T) {
+	now := time.Now()
+
+	conf := &mockConfig{
+		Exp:   1 * time.Second,
+		MaxT:  1 * time.Hour,
+		TimeS: constantTime(now),
+	}
+
+	key := []byte("0123456789")
+	hashedKey := uint64(0x8208d73d0fcfef26)
+	value := []byte("test-value")
+
+	mockDict := &mockDataDictionary{}
+	mockDict.On("Add", hashedKey, key, value, now.Add(conf.Exp)).Return(nil).Once()
+	mockDict.On("Add", hashedKey, key, value, now.Add(time.Minute)).Return(nil).Once()
+	mockDict.On("Add", hashedKey, key, value, now.Add(conf.MaxT)).Return(ErrOutOfLimit).Twice()
+
+	storage, err := NewInMemStorages(conf, mockDict, nil)
+	require.NoError(t, err)
+
+	errs := storage.AddBatch([]BatchItem{
+		{Key: key, Value: value},
+		{Key: key, Value: value, TTL: time.Minute},
+		{Key: key, Value: value, TTL: 2 * time.Hour},
+	})
+	assert.Equal(t, []error{nil, nil, ErrOutOfLimit}, errs)
+
+	mockDict.AssertExpectations(t)
+}
+
+func TestInMemStorages_AddAll(t *testing.T) {
+	var (
+		storage = newEvictedStorages(t, config.EvictionNone, NewMapDictionary, newFakeClock(time.Now()))
+		large   = make([]byte, 40)
+	)
+
+	require.NoError(t, storage.Add([]byte("k0"), []byte("old"), Persistent))
+
+	err := storage.AddAll([]BatchItem{
+		{Key: []byte("k0"), Value: []byte("new"), TTL: Persistent},
+		{Key: []byte("k1"), Value: large, TTL: Persistent},
+		{Key: []byte("k2"), Value: large, TTL: Persistent},
+		{Key: []byte("k3"), Value: large, TTL: Persistent},
+		{Key: []byte("k4"), Value: large, TTL: Persistent},
+	})
+	assert.Equal(t, ErrOutOfLimit, err)
+
+	assertValue(t, storage, []byte("k0"), []byte("old"))
+
+	ttl, err := storage.TTL([]byte("k0"))
+	require.NoError(t, err)
+	assert.Equal(t, Persistent, ttl)
+
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		_, err := storage.Get([]byte(key))
+		assert.Equal(t, ErrKeyNotFound, err, key)
+	}
+
+	require.NoError(t, storage.AddAll([]BatchItem{
+		{Key: []byte("k0"), Value: []byte("new"), TTL: Persistent},
+		{Key: []byte("k1"), Value: []byte("v1"), TTL: Persistent},
+	}))
+
+	assertValue(t, storage, []byte("k0"), []byte("new"))
+	assertValue(t, storage, []byte("k1"), []byte("v1"))
+}
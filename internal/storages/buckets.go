@@ -0,0 +1,549 @@
+package storages
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+)
+
+const (
+	DefaultBucket = "default"
+
+	maxBucketNameLen = 64
+
+	bucketsSuffix    = ".buckets"
+	bucketFileSuffix = ".bucket-"
+)
+
+type BucketSettings struct {
+	Mode       config.StorageMode
+	Expiration time.Duration
+	MaxMemory  int64
+	Eviction   config.EvictionPolicy
+}
+
+type bucketConfig struct {
+	config.Config
+
+	name     string
+	settings BucketSettings
+}
+
+func (o bucketConfig) SnapshotPath() string {
+	return bucketPath(o.Config.SnapshotPath(), o.name)
+}
+
+func (o bucketConfig) JournalPath() string {
+	return bucketPath(o.Config.JournalPath(), o.name)
+}
+
+func (o bucketConfig) Mode() config.StorageMode {
+	return o.settings.Mode
+}
+
+func (o bucketConfig) Expiration() time.Duration {
+	return o.settings.Expiration
+}
+
+func (o bucketConfig) MaxMemory() int64 {
+	return o.settings.MaxMemory
+}
+
+func (o bucketConfig) EvictionPolicy() config.EvictionPolicy {
+	return o.settings.Eviction
+}
+
+type Bucket struct {
+	name       string
+	settings   BucketSettings
+	storages   Storages
+	compactor  *Compactor
+	memoryPool *bucketMemoryPool
+	snapshot   *Snapshot
+	journal    *AppendOnlyLog
+}
+
+// persistedBucket is an entry of a list of buckets, which is saved next to a snapshot or a journal.
+type persistedBucket struct {
+	Name       string                `json:"name"`
+	Mode       config.StorageMode    `json:"mode"`
+	Expiration time.Duration         `json:"expiration"`
+	MaxMemory  int64                 `json:"max_memory"`
+	Eviction   config.EvictionPolicy `json:"eviction"`
+}
+
+func (o *Bucket) Name() string {
+	return o.name
+}
+
+func (o *Bucket) Settings() BucketSettings {
+	return o.settings
+}
+
+func (o *Bucket) Storages() Storages {
+	return o.storages
+}
+
+type Buckets struct {
+	sync.RWMutex
+
+	conf       config.Config
+	memoryPool MemoryPool
+	buckets    map[string]*Bucket
+}
+
+func NewBuckets(conf config.Config, storages Storages, dict DataDictionary, memoryPool MemoryPool) *Buckets {
+	return &Buckets{
+		conf:       conf,
+		memoryPool: memoryPool,
+		buckets: map[string]*Bucket{
+			DefaultBucket: {
+				name: DefaultBucket,
+				settings: BucketSettings{
+					Mode:       conf.Mode(),
+					Expiration: conf.Expiration(),
+					MaxMemory:  conf.MaxMemory(),
+					Eviction:   conf.EvictionPolicy(),
+				},
+				storages:  storages,
+				compactor: NewCompactor(conf, dict),
+			},
+		},
+	}
+}
+
+func (o *Buckets) ID() string {
+	return "buckets"
+}
+
+func (o *Buckets) Default() *Bucket {
+	bucket, _ := o.Get(DefaultBucket)
+
+	return bucket
+}
+
+func (o *Buckets) Get(name string) (*Bucket, error) {
+	o.RLock()
+	defer o.RUnlock()
+
+	bucket, ok := o.buckets[name]
+	if !ok {
+		return nil, ErrBucketNotFound
+	}
+
+	return bucket, nil
+}
+
+func (o *Buckets) List() []*Bucket {
+	o.RLock()
+	list := make([]*Bucket, 0, len(o.buckets))
+	for _, bucket := range o.buckets {
+		list = append(list, bucket)
+	}
+	o.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+
+	return list
+}
+
+func (o *Buckets) Create(name string, settings BucketSettings) (*Bucket, error) {
+	if !validBucketName(name) {
+		return nil, ErrInvalidBucket
+	}
+
+	settings, err := o.resolve(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := o.Get(name); err == nil {
+		return nil, ErrBucketExists
+	}
+
+	bucket, err := o.open(name, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// files of a dropped bucket of the same name could be left by a failed drop
+	bucket.removeFiles()
+
+	if bucket.journal != nil {
+		err = bucket.journal.Open(bucket.storages)
+		if err != nil {
+			bucket.memoryPool.drop()
+
+			return nil, err
+		}
+	}
+
+	o.Lock()
+	defer o.Unlock()
+
+	if _, ok := o.buckets[name]; ok {
+		bucket.close()
+
+		return nil, ErrBucketExists
+	}
+
+	o.buckets[name] = bucket
+
+	err = o.save()
+	if err != nil {
+		delete(o.buckets, name)
+		bucket.close()
+		bucket.removeFiles()
+
+		return nil, err
+	}
+
+	return bucket, nil
+}
+
+func (o *Buckets) Drop(name string) error {
+	if name == DefaultBucket {
+		return ErrDefaultBucketDrop
+	}
+
+	o.Lock()
+	defer o.Unlock()
+
+	bucket, ok := o.buckets[name]
+	if !ok {
+		return ErrBucketNotFound
+	}
+
+	delete(o.buckets, name)
+
+	err := o.save()
+
+	bucket.close()
+	bucket.removeFiles()
+
+	return err
+}
+
+func (o *Buckets) Restore() (int, error) {
+	if o.path() == "" {
+		return 0, nil
+	}
+
+	data, err := ioutil.ReadFile(o.path())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	var list []persistedBucket
+
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+
+	for _, item := range list {
+		settings := BucketSettings{
+			Mode:       item.Mode,
+			Expiration: item.Expiration,
+			MaxMemory:  item.MaxMemory,
+			Eviction:   item.Eviction,
+		}
+
+		bucket, err := o.open(item.Name, settings)
+		if err != nil {
+			return restored, err
+		}
+
+		o.Lock()
+		o.buckets[item.Name] = bucket
+		o.Unlock()
+
+		count, err := bucket.restore()
+		restored += count
+
+		if err != nil {
+			return restored, err
+		}
+	}
+
+	return restored, nil
+}
+
+func (o *Buckets) Close() error {
+	var err error
+
+	for _, bucket := range o.List() {
+		if bucket.name == DefaultBucket {
+			continue
+		}
+
+		if bucket.snapshot != nil {
+			if saveErr := bucket.snapshot.Save(context.Background()); err == nil {
+				err = saveErr
+			}
+		}
+
+		if bucket.journal != nil {
+			if closeErr := bucket.journal.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+
+	return err
+}
+
+func (o *Buckets) open(name string, settings BucketSettings) (*Bucket, error) {
+	conf := bucketConfig{
+		Config:   o.conf,
+		name:     name,
+		settings: settings,
+	}
+
+	memoryPool, err := o.memoryPool.reserve(settings.MaxMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	dict, err := NewDataDictionary(settings.Mode, memoryPool, conf.TimeSource())
+	if err != nil {
+		memoryPool.drop()
+
+		return nil, err
+	}
+
+	var (
+		journal Journal
+		bucket  = &Bucket{
+			name:       name,
+			settings:   settings,
+			compactor:  NewCompactor(conf, dict),
+			memoryPool: memoryPool,
+		}
+	)
+
+	if conf.JournalPath() != "" {
+		bucket.journal = NewAppendOnlyLog(conf)
+		journal = bucket.journal
+	}
+
+	bucket.storages, err = NewInMemStorages(conf, dict, journal)
+	if err != nil {
+		memoryPool.drop()
+
+		return nil, err
+	}
+
+	if conf.SnapshotPath() != "" {
+		bucket.snapshot = NewSnapshot(conf, bucket.storages)
+	}
+
+	return bucket, nil
+}
+
+// save writes a list of buckets under a lock of buckets, so a list of a concurrent change isn't overwritten.
+func (o *Buckets) save() error {
+	if o.path() == "" {
+		return nil
+	}
+
+	list := make([]persistedBucket, 0, len(o.buckets))
+
+	for _, bucket := range o.buckets {
+		if bucket.name == DefaultBucket {
+			continue
+		}
+
+		list = append(list, persistedBucket{
+			Name:       bucket.name,
+			Mode:       bucket.settings.Mode,
+			Expiration: bucket.settings.Expiration,
+			MaxMemory:  bucket.settings.MaxMemory,
+			Eviction:   bucket.settings.Eviction,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := o.path() + ".tmp"
+
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, o.path())
+}
+
+func (o *Buckets) path() string {
+	if o.conf.JournalPath() != "" {
+		return o.conf.JournalPath() + bucketsSuffix
+	}
+
+	if o.conf.SnapshotPath() != "" {
+		return o.conf.SnapshotPath() + bucketsSuffix
+	}
+
+	return ""
+}
+
+func (o *Bucket) restore() (int, error) {
+	restored := 0
+
+	if o.snapshot != nil {
+		count, err := o.snapshot.Load()
+		restored += count
+
+		if err != nil {
+			return restored, err
+		}
+	}
+
+	if o.journal != nil {
+		count, err := o.journal.Replay(o.storages)
+		restored += count
+
+		if err != nil {
+			return restored, err
+		}
+
+		err = o.journal.Open(o.storages)
+		if err != nil {
+			return restored, err
+		}
+	}
+
+	return restored, nil
+}
+
+func (o *Bucket) close() {
+	if o.journal != nil {
+		_ = o.journal.Close()
+	}
+
+	o.memoryPool.drop()
+}
+
+func (o *Bucket) removeFiles() {
+	if o.snapshot != nil {
+		_ = os.Remove(o.snapshot.path)
+	}
+
+	if o.journal != nil {
+		_ = os.Remove(o.journal.path)
+	}
+}
+
+func (o *Buckets) Clean(ctx context.Context) error {
+	for _, bucket := range o.List() {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		err := bucket.storages.Clean(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.compactor.Clean(ctx)
+		if err != nil {
+			return err
+		}
+
+		if bucket.snapshot != nil {
+			err = bucket.snapshot.Clean(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if bucket.journal != nil {
+			err = bucket.journal.Clean(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (o *Buckets) resolve(settings BucketSettings) (BucketSettings, error) {
+	if settings.Mode == "" {
+		settings.Mode = o.conf.Mode()
+	}
+
+	if settings.Eviction == "" {
+		settings.Eviction = o.conf.EvictionPolicy()
+	}
+
+	if settings.Expiration == 0 {
+		settings.Expiration = o.conf.Expiration()
+	}
+
+	switch {
+	case !settings.Mode.Valid():
+		return settings, ErrUnsupportedMode
+	case !settings.Eviction.Valid(),
+		settings.MaxMemory < 0,
+		settings.MaxMemory == 0 && o.conf.MaxMemory() > 0:
+		return settings, ErrInvalidBucket
+	case settings.MaxMemory > 0 && settings.MaxMemory < MinMemory(settings.Mode, o.conf.PreAllocated()):
+		return settings, ErrMemoryFloor
+	case settings.Expiration < 0:
+		return settings, ErrInvalidTTL
+	}
+
+	return settings, nil
+}
+
+func bucketPath(path, name string) string {
+	if path == "" {
+		return ""
+	}
+
+	return path + bucketFileSuffix + name
+}
+
+func validBucketName(name string) bool {
+	if name == "" || len(name) > maxBucketNameLen {
+		return false
+	}
+
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z',
+			c >= 'A' && c <= 'Z',
+			c >= '0' && c <= '9',
+			c == '-',
+			c == '_':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,218 @@
+package storages
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBuckets(t *testing.T, maxMemory int64) *Buckets {
+	conf := &mockConfig{
+		Exp:      time.Minute,
+		TimeS:    constantTime(time.Now()),
+		PreAlloc: 64,
+		MaxMem:   maxMemory,
+		Evict:    config.EvictionNone,
+	}
+
+	memoryPool := NewMemoryPool(conf.PreAlloc, conf.MaxMem)
+
+	dict, err := NewDataDictionary(config.StorageModeMap, memoryPool, conf.TimeS)
+	require.NoError(t, err)
+
+	storage, err := NewInMemStorages(conf, dict, nil)
+	require.NoError(t, err)
+
+	return NewBuckets(conf, storage, dict, memoryPool)
+}
+
+func TestBuckets_Create(t *testing.T) {
+	buckets := newTestBuckets(t, 0)
+
+	bucket, err := buckets.Create("team-a", BucketSettings{Expiration: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, "team-a", bucket.Name())
+	assert.Equal(t, BucketSettings{
+		Mode:       config.StorageModeMap,
+		Expiration: time.Hour,
+		Eviction:   config.EvictionNone,
+	}, bucket.Settings())
+
+	require.NoError(t, bucket.Storages().Add([]byte("/key"), []byte("value"), 0))
+
+	_, err = buckets.Default().Storages().Get([]byte("/key"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	got, err := buckets.Get("team-a")
+	require.NoError(t, err)
+
+	value, err := got.Storages().Get([]byte("/key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value.Bytes())
+	value.Free()
+
+	_, err = buckets.Create("team-a", BucketSettings{})
+	assert.Equal(t, ErrBucketExists, err)
+
+	_, err = buckets.Create("team/a", BucketSettings{})
+	assert.Equal(t, ErrInvalidBucket, err)
+
+	_, err = buckets.Create("team-b", BucketSettings{Mode: "tree"})
+	assert.Equal(t, ErrUnsupportedMode, err)
+
+	names := make([]string, 0)
+	for _, bucket := range buckets.List() {
+		names = append(names, bucket.Name())
+	}
+
+	assert.Equal(t, []string{DefaultBucket, "team-a"}, names)
+}
+
+func TestBuckets_Quota(t *testing.T) {
+	buckets := newTestBuckets(t, 0)
+
+	bucket, err := buckets.Create("small", BucketSettings{MaxMemory: 64})
+	require.NoError(t, err)
+
+	value := make([]byte, 40)
+
+	require.NoError(t, bucket.Storages().Add([]byte("k0"), value, 0))
+	assert.Equal(t, ErrOutOfLimit, bucket.Storages().Add([]byte("k1"), value, 0))
+
+	// the quota of a bucket doesn't limit other buckets
+	require.NoError(t, buckets.Default().Storages().Add([]byte("k1"), value, 0))
+	require.NoError(t, buckets.Default().Storages().Add([]byte("k2"), value, 0))
+
+	require.NoError(t, buckets.Clean(context.Background()))
+}
+
+func TestBuckets_QuotaFloor(t *testing.T) {
+	buckets := newTestBuckets(t, 0)
+
+	// each partition needs a chunk of its own
+	_, err := buckets.Create("small", BucketSettings{Mode: config.StorageModePartitionedMap, MaxMemory: 15 * 64})
+	assert.Equal(t, ErrMemoryFloor, err)
+
+	_, err = buckets.Create("tiny", BucketSettings{MaxMemory: 63})
+	assert.Equal(t, ErrMemoryFloor, err)
+
+	_, err = buckets.Create("partitioned", BucketSettings{Mode: config.StorageModePartitionedMap, MaxMemory: 16 * 64})
+	require.NoError(t, err)
+}
+
+func TestBuckets_Drop(t *testing.T) {
+	buckets := newTestBuckets(t, 0)
+
+	_, err := buckets.Create("team-a", BucketSettings{})
+	require.NoError(t, err)
+
+	require.NoError(t, buckets.Drop("team-a"))
+
+	_, err = buckets.Get("team-a")
+	assert.Equal(t, ErrBucketNotFound, err)
+
+	assert.Equal(t, ErrBucketNotFound, buckets.Drop("team-a"))
+	assert.Equal(t, ErrDefaultBucketDrop, buckets.Drop(DefaultBucket))
+}
+
+func TestBuckets_ReservedMemory(t *testing.T) {
+	// four chunks of one record for all buckets
+	buckets := newTestBuckets(t, 4*64)
+
+	_, err := buckets.Create("unlimited", BucketSettings{})
+	assert.Equal(t, ErrInvalidBucket, err)
+
+	_, err = buckets.Create("large", BucketSettings{MaxMemory: 4 * 64})
+	assert.Equal(t, ErrOutOfLimit, err)
+
+	bucket, err := buckets.Create("team-a", BucketSettings{MaxMemory: 2 * 64})
+	require.NoError(t, err)
+
+	value := make([]byte, 40)
+
+	// the default bucket is limited by memory left by other buckets
+	require.NoError(t, buckets.Default().Storages().Add([]byte("k0"), value, 0))
+	require.NoError(t, buckets.Default().Storages().Add([]byte("k1"), value, 0))
+	assert.Equal(t, ErrOutOfLimit, buckets.Default().Storages().Add([]byte("k2"), value, 0))
+
+	// the quota of the bucket is reserved for it
+	require.NoError(t, bucket.Storages().Add([]byte("k0"), value, 0))
+	require.NoError(t, bucket.Storages().Add([]byte("k1"), value, 0))
+	assert.Equal(t, ErrOutOfLimit, bucket.Storages().Add([]byte("k2"), value, 0))
+
+	// memory of a dropped bucket is returned to other buckets
+	require.NoError(t, buckets.Drop("team-a"))
+	require.NoError(t, buckets.Default().Storages().Add([]byte("k2"), value, 0))
+}
+
+func TestBuckets_Persisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvs-buckets")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	for _, conf := range []*mockConfig{
+		{Snapshot: filepath.Join(dir, "kvs.snapshot")},
+		{Journal: filepath.Join(dir, "kvs.journal"), Fsync: config.FsyncAlways},
+	} {
+		conf.Exp = time.Minute
+		conf.TimeS = constantTime(time.Now())
+		conf.PreAlloc = 64
+		conf.Evict = config.EvictionNone
+
+		newBuckets := func() *Buckets {
+			memoryPool := NewMemoryPool(conf.PreAlloc, conf.MaxMem)
+
+			dict, err := NewDataDictionary(config.StorageModeMap, memoryPool, conf.TimeS)
+			require.NoError(t, err)
+
+			storage, err := NewInMemStorages(conf, dict, nil)
+			require.NoError(t, err)
+
+			return NewBuckets(conf, storage, dict, memoryPool)
+		}
+
+		buckets := newBuckets()
+
+		bucket, err := buckets.Create("team-a", BucketSettings{Expiration: time.Hour})
+		require.NoError(t, err)
+		require.NoError(t, bucket.Storages().Add([]byte("/key"), []byte("value"), 0))
+
+		_, err = buckets.Create("team-b", BucketSettings{})
+		require.NoError(t, err)
+		require.NoError(t, buckets.Drop("team-b"))
+
+		dropped, err := filepath.Glob(filepath.Join(dir, "*team-b*"))
+		require.NoError(t, err)
+		assert.Empty(t, dropped)
+
+		require.NoError(t, buckets.Close())
+
+		restoredBuckets := newBuckets()
+
+		restored, err := restoredBuckets.Restore()
+		require.NoError(t, err)
+		assert.Equal(t, 1, restored)
+
+		_, err = restoredBuckets.Get("team-b")
+		assert.Equal(t, ErrBucketNotFound, err)
+
+		bucket, err = restoredBuckets.Get("team-a")
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour, bucket.Settings().Expiration)
+
+		value, err := bucket.Storages().Get([]byte("/key"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), value.Bytes())
+		value.Free()
+
+		require.NoError(t, restoredBuckets.Close())
+	}
+}
@@ -1,8 +1,9 @@
 package storages
 
 type refCounter interface {
-	BufferInUse()
+	BufferInUse() bool
 	BufferFree()
+	BufferRelease()
 }
 
 type Buffer struct {
@@ -17,8 +18,8 @@ func newBuffer(counter refCounter, buf []byte) Buffer {
 	}
 }
 
-func (o *Buffer) inUse() {
-	o.refCounter.BufferInUse()
+func (o *Buffer) inUse() bool {
+	return o.refCounter.BufferInUse()
 }
 
 func (o *Buffer) Free() {
@@ -27,8 +28,18 @@ func (o *Buffer) Free() {
 	o.Reset()
 }
 
-func (o *Buffer) Copy(data []byte) {
-	copy(o.buf, data)
+func (o *Buffer) release() {
+	o.refCounter.BufferRelease()
+
+	o.Reset()
+}
+
+func (o *Buffer) Copy(data ...[]byte) {
+	index := 0
+
+	for _, d := range data {
+		index += copy(o.buf[index:], d)
+	}
 }
 
 func (o *Buffer) Bytes() []byte {
@@ -0,0 +1,153 @@
+package storages
+
+import (
+	"context"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+)
+
+type Compactor struct {
+	dict  DataDictionary
+	ratio float64
+}
+
+func NewCompactor(conf config.Config, dict DataDictionary) *Compactor {
+	return &Compactor{
+		dict:  dict,
+		ratio: conf.CompactionRatio(),
+	}
+}
+
+func (o *Compactor) ID() string {
+	return "compactor"
+}
+
+func (o *Compactor) Clean(ctx context.Context) error {
+	if o.ratio <= 0 {
+		return nil
+	}
+
+	return o.dict.Compact(ctx, o.ratio)
+}
+
+type relocation struct {
+	hash  uint64
+	rec   *record
+	value Buffer
+}
+
+type chunkUsage struct {
+	pool DataPool
+	now  time.Time
+	live map[*preAllocatedBuffer]int
+}
+
+func newChunkUsage(pool DataPool, now time.Time) chunkUsage {
+	return chunkUsage{
+		pool: pool,
+		now:  now,
+		live: make(map[*preAllocatedBuffer]int),
+	}
+}
+
+func (o *chunkUsage) add(chain *record) {
+	for cursor := chain; cursor != nil; cursor = cursor.next {
+		if chunk := o.retired(cursor); chunk != nil && !cursor.isExpired(o.now) {
+			o.live[chunk] += len(cursor.key) + len(cursor.value.buf)
+		}
+	}
+}
+
+func (o *chunkUsage) sparse(ratio float64) {
+	for chunk, live := range o.live {
+		if float64(live) >= ratio*float64(len(chunk.buf)) {
+			delete(o.live, chunk)
+		}
+	}
+}
+
+func (o *chunkUsage) collect(relocations []relocation, hash uint64, chain *record) []relocation {
+	for cursor := chain; cursor != nil; cursor = cursor.next {
+		chunk := o.retired(cursor)
+		if chunk == nil {
+			continue
+		}
+
+		if _, ok := o.live[chunk]; !ok {
+			continue
+		}
+
+		if buf, ok := cursor.acquire(o.now); ok {
+			relocations = append(relocations, relocation{
+				hash:  hash,
+				rec:   cursor,
+				value: buf,
+			})
+		}
+	}
+
+	return relocations
+}
+
+func (o *chunkUsage) retired(rec *record) *preAllocatedBuffer {
+	chunk, ok := rec.value.refCounter.(*preAllocatedBuffer)
+	if !ok || o.pool.isCurrent(chunk) {
+		return nil
+	}
+
+	return chunk
+}
+
+func relocateRecords(
+	ctx context.Context,
+	pool DataPool,
+	relocations []relocation,
+	swap func(hash uint64, old, moved *record) bool,
+) uint64 {
+	var (
+		relocated uint64
+		stopped   bool
+	)
+
+	for _, item := range relocations {
+		select {
+		case <-ctx.Done():
+			stopped = true
+		default:
+		}
+
+		if !stopped {
+			moved, err := relocateRecord(pool, item.hash, item.rec, item.value)
+
+			switch {
+			case err != nil:
+				stopped = true
+			case swap(item.hash, item.rec, moved):
+				item.rec.release()
+				relocated++
+			default:
+				// the record is changed concurrently
+				moved.release()
+			}
+		}
+
+		item.value.Free()
+	}
+
+	return relocated
+}
+
+func relocateRecord(pool DataPool, hash uint64, rec *record, value Buffer) (*record, error) {
+	buf, err := pool.Copy(rec.key, value.Bytes(), rec.expiration)
+	if err != nil {
+		return nil, err
+	}
+
+	moved := newRecord(hash, len(rec.key), buf, rec.expiration, time.Unix(0, rec.lastAccess()))
+	moved.version = rec.version
+	moved.flags = rec.flags
+	moved.hits = rec.hitCount()
+
+	return moved, nil
+}
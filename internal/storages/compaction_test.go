@@ -0,0 +1,72 @@
+package storages
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompaction(t *testing.T) {
+	ctx := context.Background()
+	expiration := time.Now().Add(time.Minute)
+
+	fabrics := map[string]dictionaryFabric{
+		"map":     NewMapDictionary,
+		"syncmap": NewSyncMapDictionary,
+	}
+
+	for name, fabric := range fabrics {
+		// five records of 12 bytes per chunk
+		memPool := NewMemoryPool(64, 0)
+		pool, err := NewDataPool(memPool, systemTime{})
+		require.NoError(t, err)
+
+		dict := fabric(pool, systemTime{})
+
+		for i := 0; i < 11; i++ {
+			key := []byte(fmt.Sprintf("k%02d", i))
+			require.NoError(t, dict.Add(uint64(i), key, []byte("012345678"), expiration), name)
+		}
+
+		// the first and the second chunks keep one record of each
+		for _, i := range []int{0, 1, 2, 3, 5, 6, 7, 8} {
+			require.NoError(t, dict.Delete(uint64(i), []byte(fmt.Sprintf("k%02d", i))), name)
+		}
+
+		buf, meta, err := dict.GetMeta(4, []byte("k04"))
+		require.NoError(t, err, name)
+		buf.Free()
+
+		err = NewCompactor(&mockConfig{Compact: 0.5}, dict).Clean(ctx)
+		require.NoError(t, err, name)
+
+		assert.Equal(t, uint64(2), dict.Stats().Relocations, name)
+		assert.Len(t, memPool.(*memoryPool).free, 2, name)
+
+		for _, i := range []int{4, 9, 10} {
+			buf, err := dict.Get(uint64(i), []byte(fmt.Sprintf("k%02d", i)))
+			require.NoError(t, err, name)
+			assert.Equal(t, []byte("012345678"), buf.Bytes(), name)
+			buf.Free()
+		}
+
+		// a relocated record keeps its version and flags
+		buf, relocated, err := dict.GetMeta(4, []byte("k04"))
+		require.NoError(t, err, name)
+		buf.Free()
+		assert.Equal(t, meta, relocated, name)
+	}
+}
+
+func TestCompaction_Disabled(t *testing.T) {
+	dict := &mockDataDictionary{}
+
+	err := NewCompactor(&mockConfig{}, dict).Clean(context.Background())
+	require.NoError(t, err)
+
+	dict.AssertExpectations(t)
+}
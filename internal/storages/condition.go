@@ -0,0 +1,44 @@
+package storages
+
+import (
+	"time"
+)
+
+type Condition struct {
+	kind    conditionKind
+	version uint64
+}
+
+type conditionKind int
+
+const (
+	conditionAlways conditionKind = iota
+	conditionIfNotExists
+	conditionIfExists
+	conditionIfVersion
+)
+
+var (
+	Always      = Condition{kind: conditionAlways}
+	IfNotExists = Condition{kind: conditionIfNotExists}
+	IfExists    = Condition{kind: conditionIfExists}
+)
+
+func IfVersion(version uint64) Condition {
+	return Condition{kind: conditionIfVersion, version: version}
+}
+
+func (o Condition) met(rec *record, now time.Time) bool {
+	exists := rec != nil && !rec.isExpired(now)
+
+	switch o.kind {
+	case conditionIfNotExists:
+		return !exists
+	case conditionIfExists:
+		return exists
+	case conditionIfVersion:
+		return exists && rec.version == o.version
+	default:
+		return true
+	}
+}
@@ -0,0 +1,354 @@
+package storages
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testModes = []config.StorageMode{
+	config.StorageModeMap,
+	config.StorageModeSyncMap,
+	config.StorageModePartitionedMap,
+	config.StorageModePartitionedSyncMap,
+}
+
+func newModeStorages(t *testing.T, mode config.StorageMode, conf *mockConfig) Storages {
+	dict, err := NewDataDictionary(mode, NewMemoryPool(1024, 0), conf.TimeS)
+	require.NoError(t, err)
+
+	storage, err := NewInMemStorages(conf, dict, nil)
+	require.NoError(t, err)
+
+	return storage
+}
+
+func assertValue(t *testing.T, storage Storages, key, expected []byte) {
+	buf, err := storage.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.Bytes())
+	buf.Free()
+}
+
+func TestInMemStorages_AddIf(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("key")
+
+			assert.Equal(t, ErrConditionFailed, storage.AddIf(key, []byte("v0"), 0, IfExists))
+
+			require.NoError(t, storage.AddIf(key, []byte("v1"), 0, IfNotExists))
+			assert.Equal(t, ErrConditionFailed, storage.AddIf(key, []byte("v2"), 0, IfNotExists))
+			assertValue(t, storage, key, []byte("v1"))
+
+			require.NoError(t, storage.AddIf(key, []byte("v3"), 0, IfExists))
+			assertValue(t, storage, key, []byte("v3"))
+
+			assert.Equal(t, uint64(1), storage.Stats().Keys)
+		})
+	}
+}
+
+func TestInMemStorages_DeleteIf(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("key")
+
+			assert.Equal(t, ErrConditionFailed, storage.DeleteIf(key, IfExists))
+			assert.Equal(t, ErrKeyNotFound, storage.DeleteIf(key, IfNotExists))
+
+			require.NoError(t, storage.Add(key, []byte("v1"), 0))
+			assert.Equal(t, ErrConditionFailed, storage.DeleteIf(key, IfNotExists))
+			assertValue(t, storage, key, []byte("v1"))
+
+			require.NoError(t, storage.DeleteIf(key, IfExists))
+
+			_, err := storage.Get(key)
+			assert.Equal(t, ErrKeyNotFound, err)
+		})
+	}
+}
+
+func TestInMemStorages_AddIfVersion(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("key")
+
+			_, _, err := storage.GetMeta(key)
+			assert.Equal(t, ErrKeyNotFound, err)
+			assert.Equal(t, ErrConditionFailed, storage.AddIf(key, []byte("v0"), 0, IfVersion(0)))
+
+			require.NoError(t, storage.Add(key, []byte("v1"), 0))
+
+			buf, meta, err := storage.GetMeta(key)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("v1"), buf.Bytes())
+			buf.Free()
+
+			// an expiration keeps a version
+			require.NoError(t, storage.Expire(key, time.Hour))
+
+			assert.Equal(t, ErrConditionFailed, storage.DeleteIf(key, IfVersion(meta.Version+1)))
+			require.NoError(t, storage.AddIf(key, []byte("v2"), 0, IfVersion(meta.Version)))
+			assert.Equal(t, ErrConditionFailed, storage.AddIf(key, []byte("v3"), 0, IfVersion(meta.Version)))
+			assertValue(t, storage, key, []byte("v2"))
+
+			buf, updated, err := storage.GetMeta(key)
+			require.NoError(t, err)
+			buf.Free()
+			assert.NotEqual(t, meta.Version, updated.Version)
+
+			assert.Equal(t, ErrConditionFailed, storage.DeleteIf(key, IfVersion(meta.Version)))
+
+			require.NoError(t, storage.Update(key, 0, func(value []byte, _ bool) ([]byte, error) {
+				return value, nil
+			}))
+
+			buf, meta, err = storage.GetMeta(key)
+			require.NoError(t, err)
+			buf.Free()
+			assert.NotEqual(t, updated.Version, meta.Version)
+		})
+	}
+}
+
+func TestInMemStorages_AddIfVersionConcurrent(t *testing.T) {
+	const (
+		workers = 8
+		swaps   = 50
+	)
+
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("counter")
+
+			require.NoError(t, storage.Add(key, []byte{0}, 0))
+
+			var wg sync.WaitGroup
+
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					for done := 0; done < swaps; {
+						buf, meta, err := storage.GetMeta(key)
+						if !assert.NoError(t, err) {
+							return
+						}
+
+						value := []byte{buf.Bytes()[0] + 1}
+						buf.Free()
+
+						switch err := storage.AddIf(key, value, 0, IfVersion(meta.Version)); err {
+						case nil:
+							done++
+						case ErrConditionFailed:
+						default:
+							assert.NoError(t, err)
+							return
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			assertValue(t, storage, key, []byte{workers * swaps % 256})
+		})
+	}
+}
+
+func TestInMemStorages_AddIfExpired(t *testing.T) {
+	dict, err := NewDataDictionary(config.StorageModeMap, NewMemoryPool(1024, 0), systemTime{})
+	require.NoError(t, err)
+
+	key := []byte("key")
+	hash := uint64(1)
+
+	require.NoError(t, dict.Add(hash, key, []byte("v1"), time.Now().Add(-time.Second)))
+
+	assert.Equal(t, ErrConditionFailed, dict.AddIf(hash, key, []byte("v2"), 0, time.Now().Add(time.Minute), IfExists))
+	require.NoError(t, dict.AddIf(hash, key, []byte("v3"), 0, time.Now().Add(time.Minute), IfNotExists))
+
+	buf, err := dict.Get(hash, key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v3"), buf.Bytes())
+	buf.Free()
+}
+
+func TestInMemStorages_Expire(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("key")
+
+			assert.Equal(t, ErrKeyNotFound, storage.Expire(key, time.Hour))
+
+			_, err := storage.Persist(key)
+			assert.Equal(t, ErrKeyNotFound, err)
+
+			require.NoError(t, storage.Add(key, []byte("value"), 0))
+			require.NoError(t, storage.Expire(key, time.Hour))
+			assert.Equal(t, ErrInvalidTTL, storage.Expire(key, 0))
+
+			ttl, err := storage.TTL(key)
+			require.NoError(t, err)
+			assert.Equal(t, time.Hour, ttl)
+
+			persisted, err := storage.Persist(key)
+			require.NoError(t, err)
+			assert.True(t, persisted)
+
+			persisted, err = storage.Persist(key)
+			require.NoError(t, err)
+			assert.False(t, persisted)
+
+			ttl, err = storage.TTL(key)
+			require.NoError(t, err)
+			assert.Equal(t, Persistent, ttl)
+
+			assertValue(t, storage, key, []byte("value"))
+
+			stats := storage.Stats()
+			assert.Equal(t, uint64(1), stats.Keys)
+			assert.Equal(t, uint64(len("key")+len("value")), stats.LiveBytes)
+		})
+	}
+}
+
+func TestDictionary_ExpireExpired(t *testing.T) {
+	key := []byte("key")
+
+	for name, fabric := range map[string]dictionaryFabric{
+		"map":     NewMapDictionary,
+		"syncmap": NewSyncMapDictionary,
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock(time.Now())
+
+			pool, err := NewDataPool(NewMemoryPool(64, 0), clock)
+			require.NoError(t, err)
+
+			dict := fabric(pool, clock)
+			require.NoError(t, dict.Add(1, key, []byte("value"), clock.Now().Add(time.Second)))
+
+			clock.Advance(time.Minute)
+
+			_, err = dict.Expire(1, key, clock.Now().Add(time.Hour))
+			assert.Equal(t, ErrKeyExpired, err)
+
+			_, err = dict.GetExpire(1, key, clock.Now().Add(time.Hour))
+			assert.Equal(t, ErrKeyExpired, err)
+
+			// a chunk of the expired record isn't extended
+			assert.True(t, pool.(*dataPool).current.isExpired(clock.Now()))
+		})
+	}
+}
+
+func TestInMemStorages_StatsVolatile(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			assertVolatile := func(expected uint64) {
+				t.Helper()
+
+				stats := storage.Stats()
+				assert.Equal(t, uint64(2), stats.Keys)
+				assert.Equal(t, expected, stats.Volatile)
+			}
+
+			require.NoError(t, storage.Add([]byte("k1"), []byte("v1"), 0))
+			require.NoError(t, storage.Add([]byte("k2"), []byte("v2"), Persistent))
+			assertVolatile(1)
+
+			_, err := storage.Persist([]byte("k1"))
+			require.NoError(t, err)
+			assertVolatile(0)
+
+			buf, err := storage.GetExpire([]byte("k2"), time.Hour)
+			require.NoError(t, err)
+			buf.Free()
+			assertVolatile(1)
+
+			require.NoError(t, storage.Expire([]byte("k1"), time.Hour))
+			assertVolatile(2)
+
+			require.NoError(t, storage.Add([]byte("k1"), []byte("v3"), Persistent))
+			assertVolatile(1)
+
+			require.NoError(t, storage.Delete([]byte("k2")))
+			assert.Equal(t, uint64(0), storage.Stats().Volatile)
+		})
+	}
+}
+
+func TestInMemStorages_PersistMaxTTL(t *testing.T) {
+	storage := newModeStorages(t, config.StorageModeMap, &mockConfig{
+		Exp:   time.Minute,
+		MaxT:  time.Hour,
+		TimeS: constantTime(time.Now()),
+	})
+
+	key := []byte("key")
+
+	require.NoError(t, storage.Add(key, []byte("value"), 0))
+
+	persisted, err := storage.Persist(key)
+	require.NoError(t, err)
+	assert.True(t, persisted)
+
+	ttl, err := storage.TTL(key)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, ttl)
+}
+
+func TestPreAllocatedBuffer_extend(t *testing.T) {
+	now := time.Now()
+
+	chunk := newPreAllocatedBuffer(make([]byte, 64))
+
+	_, ok := chunk.allocate(8, now.Add(time.Second))
+	require.True(t, ok)
+
+	rec := &record{value: newBuffer(chunk, nil), expiration: now.Add(time.Second)}
+	extended := rec.expire(now.Add(time.Hour))
+
+	assert.Equal(t, now.Add(time.Hour), extended.expiration)
+	assert.False(t, chunk.isExpired(now.Add(time.Minute)))
+	assert.True(t, chunk.isExpired(now.Add(2*time.Hour)))
+}
@@ -0,0 +1,38 @@
+package storages
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+func (o *InMemStorages) Incr(key []byte, delta int64, ttl time.Duration) (int64, error) {
+	var result int64
+
+	err := o.Update(key, ttl, func(value []byte, exists bool) ([]byte, error) {
+		var n int64
+
+		if exists {
+			var err error
+
+			n, err = strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				return nil, ErrNotInteger
+			}
+		}
+
+		if delta > 0 && n > math.MaxInt64-delta ||
+			delta < 0 && n < math.MinInt64-delta {
+			return nil, ErrIntegerOverflow
+		}
+
+		result = n + delta
+
+		return strconv.AppendInt(nil, result, 10), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
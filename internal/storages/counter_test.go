@@ -0,0 +1,104 @@
+package storages
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemStorages_Incr(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("counter")
+
+			n, err := storage.Incr(key, 5, time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), n)
+
+			ttl, err := storage.TTL(key)
+			require.NoError(t, err)
+			assert.Equal(t, time.Hour, ttl)
+
+			// an existing key keeps its expiration
+			n, err = storage.Incr(key, -7, time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, int64(-2), n)
+			assertValue(t, storage, key, []byte("-2"))
+
+			ttl, err = storage.TTL(key)
+			require.NoError(t, err)
+			assert.Equal(t, time.Hour, ttl)
+
+			require.NoError(t, storage.Add(key, []byte(strconv.FormatInt(math.MaxInt64, 10)), 0))
+			_, err = storage.Incr(key, 1, 0)
+			assert.Equal(t, ErrIntegerOverflow, err)
+
+			require.NoError(t, storage.Add(key, []byte(strconv.FormatInt(math.MinInt64, 10)), 0))
+			_, err = storage.Incr(key, -1, 0)
+			assert.Equal(t, ErrIntegerOverflow, err)
+
+			require.NoError(t, storage.Add(key, []byte("1.5"), 0))
+			_, err = storage.Incr(key, 1, 0)
+			assert.Equal(t, ErrNotInteger, err)
+			assertValue(t, storage, key, []byte("1.5"))
+
+			_, err = storage.Incr([]byte("other"), 1, -time.Second)
+			assert.Equal(t, ErrInvalidTTL, err)
+		})
+	}
+}
+
+func TestInMemStorages_IncrConcurrent(t *testing.T) {
+	const (
+		workers = 8
+		incrs   = 100
+	)
+
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("counter")
+
+			var wg sync.WaitGroup
+
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+
+				go func(delta int64) {
+					defer wg.Done()
+
+					for j := 0; j < incrs; j++ {
+						_, err := storage.Incr(key, delta, 0)
+						assert.NoError(t, err)
+
+						// readers and writers of other keys of the same partition run alongside
+						if buf, err := storage.Get(key); assert.NoError(t, err) {
+							buf.Free()
+						}
+
+						assert.NoError(t, storage.Add([]byte("neighbour"), []byte("value"), 0))
+					}
+				}(int64(i%2*3 - 1))
+			}
+
+			wg.Wait()
+
+			// a half of workers adds 2, another one subtracts 1
+			assertValue(t, storage, key, []byte(strconv.Itoa(workers/2*incrs*2-workers/2*incrs)))
+		})
+	}
+}
@@ -3,56 +3,81 @@ package storages
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/7phs/kvs/internal/config"
 )
 
 type DataPool interface {
-	Copy(data []byte, expiration time.Time) (Buffer, error)
+	Copy(key, data []byte, expiration time.Time) (Buffer, error)
 	Clean(ctx context.Context) error
+	isCurrent(chunk *preAllocatedBuffer) bool
+	Stats() Stats
 }
 
 type dataPool struct {
 	sync.Mutex
 
-	valuePool MemoryPool
+	valuePool  MemoryPool
+	timeSource config.TimeSource
+	chunkSize  int
+	chunks     int64
+	allocated  int64
 
 	current      *preAllocatedBuffer
 	queueToClean queueAllocations
 }
 
-func NewDataPool(memPool MemoryPool) (DataPool, error) {
+func NewDataPool(memPool MemoryPool, timeSource config.TimeSource) (DataPool, error) {
 	buf, err := memPool.Get()
 	if err != nil {
 		return nil, err
 	}
 
 	return &dataPool{
-		valuePool: memPool,
-		current:   newPreAllocatedBuffer(buf),
+		valuePool:  memPool,
+		timeSource: timeSource,
+		chunkSize:  len(buf),
+		chunks:     1,
+		allocated:  int64(len(buf)),
+		current:    newPreAllocatedBuffer(buf),
 	}, nil
 }
 
-func (o *dataPool) Copy(data []byte, expiration time.Time) (Buffer, error) {
-	valueBuf, err := o.allocate(len(data), expiration)
+func (o *dataPool) Copy(key, data []byte, expiration time.Time) (Buffer, error) {
+	valueBuf, err := o.allocate(len(key)+len(data), expiration)
 	if err != nil {
 		return Buffer{}, err
 	}
 
-	valueBuf.Copy(data)
+	valueBuf.Copy(key, data)
 
 	return valueBuf, nil
 }
 
 func (o *dataPool) allocate(sz int, expiration time.Time) (Buffer, error) {
+	if sz > o.chunkSize {
+		return o.allocateDedicated(sz, expiration)
+	}
+
 	o.Lock()
 	defer o.Unlock()
 
+	if o.current.index > 0 && o.current.isReleased() && o.current.reclaim() {
+		o.current = newPreAllocatedBuffer(o.current.buf)
+	}
+
 	buf, ok := o.current.allocate(sz, expiration)
 	if ok {
 		return buf, nil
 	}
 
-	bufP, err := o.valuePool.Get()
+	bufP, err := o.get()
+	if err == ErrOutOfLimit {
+		bufP, err = o.reclaim()
+	}
+
 	if err != nil {
 		return Buffer{}, err
 	}
@@ -60,7 +85,8 @@ func (o *dataPool) allocate(sz int, expiration time.Time) (Buffer, error) {
 	nodeToClean := o.current
 	o.current = newPreAllocatedBuffer(bufP)
 
-	go o.queueToClean.push(nodeToClean)
+	// the queue is updated synchronously to reclaim the chunk on the next allocation out of the limit
+	o.queueToClean.push(nodeToClean)
 
 	buf, ok = o.current.allocate(sz, expiration)
 	if ok {
@@ -70,8 +96,95 @@ func (o *dataPool) allocate(sz int, expiration time.Time) (Buffer, error) {
 	return Buffer{}, ErrOutOfLimit
 }
 
+func (o *dataPool) allocateDedicated(sz int, expiration time.Time) (Buffer, error) {
+	bufP, err := o.allocateBuffer(sz)
+	if err == ErrOutOfLimit {
+		_ = o.Clean(context.Background())
+
+		bufP, err = o.allocateBuffer(sz)
+	}
+
+	if err != nil {
+		return Buffer{}, err
+	}
+
+	node := newPreAllocatedBuffer(bufP)
+
+	buf, ok := node.allocate(sz, expiration)
+	if !ok {
+		return Buffer{}, ErrOutOfLimit
+	}
+
+	o.queueToClean.push(node)
+
+	return buf, nil
+}
+
+func (o *dataPool) reclaim() ([]byte, error) {
+	for {
+		node, ok := o.queueToClean.pop(o.timeSource.Now())
+		if !ok {
+			return nil, ErrOutOfLimit
+		}
+
+		buf := node.buf
+		node.buf = nil
+
+		if len(buf) == o.chunkSize {
+			return buf, nil
+		}
+
+		if buf != nil {
+			o.put(buf)
+
+			if chunk, err := o.get(); err == nil {
+				return chunk, nil
+			}
+		}
+	}
+}
+
+func (o *dataPool) get() ([]byte, error) {
+	return o.track(o.valuePool.Get())
+}
+
+func (o *dataPool) allocateBuffer(sz int) ([]byte, error) {
+	return o.track(o.valuePool.Allocate(sz))
+}
+
+func (o *dataPool) track(buf []byte, err error) ([]byte, error) {
+	if err == nil {
+		atomic.AddInt64(&o.chunks, 1)
+		atomic.AddInt64(&o.allocated, int64(len(buf)))
+	}
+
+	return buf, err
+}
+
+func (o *dataPool) put(buf []byte) {
+	atomic.AddInt64(&o.chunks, -1)
+	atomic.AddInt64(&o.allocated, -int64(len(buf)))
+
+	o.valuePool.Put(buf)
+}
+
+func (o *dataPool) Stats() Stats {
+	return Stats{
+		Chunks:         uint64(atomic.LoadInt64(&o.chunks)),
+		QueuedChunks:   uint64(o.queueToClean.len()),
+		AllocatedBytes: uint64(atomic.LoadInt64(&o.allocated)),
+	}
+}
+
+func (o *dataPool) isCurrent(chunk *preAllocatedBuffer) bool {
+	o.Lock()
+	defer o.Unlock()
+
+	return o.current == chunk
+}
+
 func (o *dataPool) Clean(ctx context.Context) error {
-	now := time.Now()
+	now := o.timeSource.Now()
 
 	for {
 		select {
@@ -86,7 +199,7 @@ func (o *dataPool) Clean(ctx context.Context) error {
 		}
 
 		if node.buf != nil {
-			o.valuePool.Put(node.buf)
+			o.put(node.buf)
 			node.buf = nil
 			node = nil
 		}
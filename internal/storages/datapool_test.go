@@ -11,6 +11,7 @@ import (
 )
 
 func TestDataPool_Copy(t *testing.T) {
+	key := []byte("key")
 	data := []byte("0123456789")
 	bufSize := 16
 	expiration := time.Now()
@@ -19,19 +20,20 @@ func TestDataPool_Copy(t *testing.T) {
 	memPool.On("Get").Return(make([]byte, bufSize), nil)
 	memPool.On("Get").Return(make([]byte, bufSize), nil)
 
-	pool, err := NewDataPool(memPool)
+	pool, err := NewDataPool(memPool, systemTime{})
 	require.NoError(t, err)
 
 	for i := 0; i < 2; i++ {
-		buf, err := pool.Copy(data, expiration)
+		buf, err := pool.Copy(key, data, expiration)
 		require.NoError(t, err)
-		assert.Equal(t, data, buf.Bytes())
+		assert.Equal(t, append(key, data...), buf.Bytes())
 	}
 
 	memPool.AssertExpectations(t)
 }
 
 func TestDataPool_Clean(t *testing.T) {
+	key := []byte("key")
 	data := []byte("0123456789")
 	bufSize := 16
 	buf1 := make([]byte, bufSize)
@@ -43,13 +45,13 @@ func TestDataPool_Clean(t *testing.T) {
 	memPool.On("Get").Return(make([]byte, bufSize), nil)
 	memPool.On("Put", buf1)
 
-	pool, err := NewDataPool(memPool)
+	pool, err := NewDataPool(memPool, systemTime{})
 	require.NoError(t, err)
 
 	for i := 0; i < 2; i++ {
-		buf, err := pool.Copy(data, expiration)
+		buf, err := pool.Copy(key, data, expiration)
 		require.NoError(t, err)
-		assert.Equal(t, data, buf.Bytes())
+		assert.Equal(t, append(key, data...), buf.Bytes())
 	}
 
 	runtime.Gosched()
@@ -59,3 +61,131 @@ func TestDataPool_Clean(t *testing.T) {
 
 	memPool.AssertExpectations(t)
 }
+
+func TestDataPool_CopyOutOfLimit(t *testing.T) {
+	key := []byte("key")
+	data := []byte("0123456789")
+	bufSize := 16
+	buf1 := make([]byte, bufSize)
+	now := time.Now()
+
+	memPool := &mockMemoryPool{}
+	memPool.On("Get").Return(buf1, nil).Once()
+	memPool.On("Get").Return(make([]byte, bufSize), nil).Once()
+	memPool.On("Get").Return([]byte(nil), ErrOutOfLimit)
+
+	pool, err := NewDataPool(memPool, systemTime{})
+	require.NoError(t, err)
+
+	// the first chunk is expired
+	_, err = pool.Copy(key, data, now)
+	require.NoError(t, err)
+
+	_, err = pool.Copy(key, data, now.Add(1*time.Minute))
+	require.NoError(t, err)
+
+	// the expired chunk is reclaimed
+	buf, err := pool.Copy(key, data, now.Add(1*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, append(key, data...), buf.Bytes())
+	assert.Equal(t, &buf1[0], &buf.Bytes()[0])
+
+	// chunks are in use
+	_, err = pool.Copy(key, data, now.Add(1*time.Minute))
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrOutOfLimit.Error())
+
+	memPool.AssertExpectations(t)
+}
+
+func TestDataPool_CopyToReleasedCurrent(t *testing.T) {
+	key := []byte("key")
+	data := []byte("0123")
+	bufSize := 16
+	buf1 := make([]byte, bufSize)
+	expiration := time.Now().Add(1 * time.Minute)
+
+	memPool := &mockMemoryPool{}
+	memPool.On("Get").Return(buf1, nil).Once()
+
+	pool, err := NewDataPool(memPool, systemTime{})
+	require.NoError(t, err)
+
+	buf, err := pool.Copy(key, data, expiration)
+	require.NoError(t, err)
+
+	buf.release()
+
+	// the current chunk is rewound, since all of its records are released
+	for i := 0; i < 2; i++ {
+		buf, err = pool.Copy(key, data, expiration)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, &buf1[len(key)+len(data)], &buf.Bytes()[0])
+
+	memPool.AssertExpectations(t)
+}
+
+func TestDataPool_CopyLarge(t *testing.T) {
+	key := []byte("key")
+	data := []byte("0123456789abcdef")
+	bufSize := 16
+	sz := len(key) + len(data)
+	large := make([]byte, sz)
+	now := time.Now()
+	ctx := context.Background()
+
+	memPool := &mockMemoryPool{}
+	memPool.On("Get").Return(make([]byte, bufSize), nil).Once()
+	memPool.On("Allocate", sz).Return(large, nil).Once()
+	memPool.On("Put", large).Once()
+
+	pool, err := NewDataPool(memPool, systemTime{})
+	require.NoError(t, err)
+
+	buf, err := pool.Copy(key, data, now)
+	require.NoError(t, err)
+	assert.Equal(t, append(key, data...), buf.Bytes())
+	assert.Equal(t, &large[0], &buf.Bytes()[0])
+
+	// the dedicated buffer is reclaimed as an expired chunk
+	err = pool.Clean(ctx)
+	require.NoError(t, err)
+
+	memPool.AssertExpectations(t)
+}
+
+func TestDataPool_CleanByTimeSource(t *testing.T) {
+	key := []byte("key")
+	data := []byte("0123456789")
+	bufSize := 16
+	buf1 := make([]byte, bufSize)
+	clock := newFakeClock(time.Now())
+	ctx := context.Background()
+
+	memPool := &mockMemoryPool{}
+	memPool.On("Get").Return(buf1, nil).Once()
+	memPool.On("Get").Return(make([]byte, bufSize), nil).Once()
+
+	pool, err := NewDataPool(memPool, clock)
+	require.NoError(t, err)
+
+	// the first chunk is queued to clean, when the second one is taken
+	for i := 0; i < 2; i++ {
+		_, err = pool.Copy(key, data, clock.Now().Add(1*time.Minute))
+		require.NoError(t, err)
+	}
+
+	// the chunk is alive by the clock
+	require.NoError(t, pool.Clean(ctx))
+	assert.Equal(t, uint64(1), pool.Stats().QueuedChunks)
+
+	memPool.On("Put", buf1).Once()
+
+	clock.Advance(1 * time.Minute)
+	require.NoError(t, pool.Clean(ctx))
+	assert.Equal(t, uint64(0), pool.Stats().QueuedChunks)
+
+	memPool.AssertExpectations(t)
+}
@@ -0,0 +1,68 @@
+package storages
+
+import (
+	"github.com/7phs/kvs/internal/config"
+)
+
+func NewDataDictionary(
+	mode config.StorageMode,
+	memoryPool MemoryPool,
+	timeSource config.TimeSource,
+) (DataDictionary, error) {
+	switch mode {
+	case config.StorageModeMap:
+		return newMapDictionary(memoryPool, timeSource)
+
+	case config.StorageModeSyncMap:
+		return newSyncMapDictionary(memoryPool, timeSource)
+
+	case config.StorageModePartitionedMap:
+		return NewPartitionedDictionary(
+			DefaultPartitionNum,
+			DefaultPartitionMask,
+			func() (DataDictionary, error) {
+				return newMapDictionary(memoryPool, timeSource)
+			},
+		)
+
+	case config.StorageModePartitionedSyncMap:
+		return NewPartitionedDictionary(
+			DefaultPartitionNum,
+			DefaultPartitionMask,
+			func() (DataDictionary, error) {
+				return newSyncMapDictionary(memoryPool, timeSource)
+			},
+		)
+
+	default:
+		return nil, ErrUnsupportedMode
+	}
+}
+
+func MinMemory(mode config.StorageMode, preAllocated int) int64 {
+	switch mode {
+	case config.StorageModePartitionedMap,
+		config.StorageModePartitionedSyncMap:
+		return int64(DefaultPartitionNum) * int64(preAllocated)
+	default:
+		return int64(preAllocated)
+	}
+}
+
+func newMapDictionary(memoryPool MemoryPool, timeSource config.TimeSource) (DataDictionary, error) {
+	pool, err := NewDataPool(memoryPool, timeSource)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMapDictionary(pool, timeSource), nil
+}
+
+func newSyncMapDictionary(memoryPool MemoryPool, timeSource config.TimeSource) (DataDictionary, error) {
+	pool, err := NewDataPool(memoryPool, timeSource)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSyncMapDictionary(pool, timeSource), nil
+}
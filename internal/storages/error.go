@@ -4,6 +4,24 @@ const (
 	ErrOutOfLimit  Error = "out_of_the_limit"
 	ErrKeyNotFound Error = "key_not_found"
 	ErrKeyExpired  Error = "key_expired"
+	ErrInvalidTTL  Error = "invalid_ttl"
+	ErrMemoryFloor Error = "max_memory_below_partitions"
+
+	ErrConditionFailed Error = "condition_failed"
+	ErrWatchOverflow   Error = "watch_overflow"
+	ErrNotInteger      Error = "value_not_integer"
+	ErrIntegerOverflow Error = "integer_overflow"
+
+	ErrUnsupportedMode   Error = "unsupported_storage_mode"
+	ErrInvalidBucket     Error = "invalid_bucket"
+	ErrBucketExists      Error = "bucket_exists"
+	ErrBucketNotFound    Error = "bucket_not_found"
+	ErrDefaultBucketDrop Error = "default_bucket_drop"
+
+	ErrSnapshotCorrupted Error = "snapshot_corrupted"
+	ErrSnapshotVersion   Error = "snapshot_unsupported_version"
+	ErrJournalCorrupted  Error = "journal_corrupted"
+	ErrJournalVersion    Error = "journal_unsupported_version"
 )
 
 type Error string
@@ -0,0 +1,183 @@
+package storages
+
+import (
+	"math/rand"
+
+	"github.com/7phs/kvs/internal/config"
+)
+
+const (
+	evictionSamples = 16
+	lfuScale        = 1024
+)
+
+var (
+	_ EvictionPolicy = lruPolicy{}
+	_ EvictionPolicy = lfuPolicy{}
+	_ EvictionPolicy = randomPolicy{}
+	_ EvictionPolicy = volatileTTLPolicy{}
+)
+
+// EvictionPolicy scores a chunk by its sampled records, a chunk with the lowest score is evicted.
+// A skipped record doesn't make its chunk a candidate.
+type EvictionPolicy interface {
+	ID() config.EvictionPolicy
+	score(prev chunkScore, rec *record) (chunkScore, bool)
+}
+
+type chunkScore struct {
+	value   int64
+	total   int64
+	records int64
+}
+
+func NewEvictionPolicy(policy config.EvictionPolicy) EvictionPolicy {
+	switch policy {
+	case config.EvictionLRU:
+		return lruPolicy{}
+	case config.EvictionLFU:
+		return lfuPolicy{}
+	case config.EvictionRandom:
+		return randomPolicy{}
+	case config.EvictionVolatileTTL:
+		return volatileTTLPolicy{}
+	default:
+		return nil
+	}
+}
+
+type lruPolicy struct{}
+
+func (lruPolicy) ID() config.EvictionPolicy {
+	return config.EvictionLRU
+}
+
+func (lruPolicy) score(prev chunkScore, rec *record) (chunkScore, bool) {
+	if accessed := rec.lastAccess(); accessed > prev.value {
+		prev.value = accessed
+	}
+
+	return prev, true
+}
+
+type lfuPolicy struct{}
+
+func (lfuPolicy) ID() config.EvictionPolicy {
+	return config.EvictionLFU
+}
+
+// score of LFU is mean hits of records, so a chunk isn't protected by a number of its records.
+func (lfuPolicy) score(prev chunkScore, rec *record) (chunkScore, bool) {
+	prev.total += int64(rec.hitCount()) * lfuScale
+	prev.records++
+	prev.value = prev.total / prev.records
+
+	return prev, true
+}
+
+type randomPolicy struct{}
+
+func (randomPolicy) ID() config.EvictionPolicy {
+	return config.EvictionRandom
+}
+
+func (randomPolicy) score(prev chunkScore, _ *record) (chunkScore, bool) {
+	if prev.value == 0 {
+		prev.value = rand.Int63() + 1 //nolint:gosec
+	}
+
+	return prev, true
+}
+
+type volatileTTLPolicy struct{}
+
+func (volatileTTLPolicy) ID() config.EvictionPolicy {
+	return config.EvictionVolatileTTL
+}
+
+// score of volatile-TTL skips records without an expiration, so chunks of persistent keys aren't evicted.
+func (volatileTTLPolicy) score(prev chunkScore, rec *record) (chunkScore, bool) {
+	if volatile(rec.expiration) == 0 {
+		return prev, false
+	}
+
+	if expiration := rec.expiration.UnixNano(); expiration > prev.value {
+		prev.value = expiration
+	}
+
+	return prev, true
+}
+
+type Victim struct {
+	owner DataDictionary
+	chunk *preAllocatedBuffer
+	score int64
+}
+
+type chunkScores struct {
+	policy  EvictionPolicy
+	pool    DataPool
+	scores  map[*preAllocatedBuffer]chunkScore
+	samples int
+}
+
+func newChunkScores(policy EvictionPolicy, pool DataPool) chunkScores {
+	return chunkScores{
+		policy: policy,
+		pool:   pool,
+		scores: make(map[*preAllocatedBuffer]chunkScore),
+	}
+}
+
+func (o *chunkScores) add(chain *record) {
+	for cursor := chain; cursor != nil; cursor = cursor.next {
+		chunk, ok := cursor.value.refCounter.(*preAllocatedBuffer)
+		if !ok || o.pool.isCurrent(chunk) {
+			continue
+		}
+
+		if score, ok := o.policy.score(o.scores[chunk], cursor); ok {
+			o.scores[chunk] = score
+			o.samples++
+		}
+	}
+}
+
+func (o *chunkScores) sampled() bool {
+	return o.samples >= evictionSamples
+}
+
+func (o *chunkScores) victim(owner DataDictionary) (Victim, bool) {
+	var (
+		victim Victim
+		found  bool
+	)
+
+	for chunk, score := range o.scores {
+		if !found || score.value < victim.score {
+			victim = Victim{
+				owner: owner,
+				chunk: chunk,
+				score: score.value,
+			}
+			found = true
+		}
+	}
+
+	return victim, found
+}
+
+func evictedKeys(evicted []*record) [][]byte {
+	keys := make([][]byte, len(evicted))
+	for i, rec := range evicted {
+		keys[i] = append([]byte(nil), rec.key...)
+	}
+
+	return keys
+}
+
+func inChunk(chunk *preAllocatedBuffer) func(rec *record) bool {
+	return func(rec *record) bool {
+		return rec.value.refCounter == chunk
+	}
+}
@@ -0,0 +1,323 @@
+package storages
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEvictedStorages keeps one record of 42 bytes per chunk and no more than three chunks.
+// Records are accessed by the clock.
+func newEvictedStorages(
+	t *testing.T,
+	policy config.EvictionPolicy,
+	fabric dictionaryFabric,
+	clock *fakeClock,
+) Storages {
+	conf := &mockConfig{
+		Exp:   time.Minute,
+		TimeS: clock,
+		Evict: policy,
+	}
+
+	pool, err := NewDataPool(NewMemoryPool(64, 3*64), conf.TimeS)
+	require.NoError(t, err)
+
+	storage, err := NewInMemStorages(conf, fabric(pool, conf.TimeS), nil)
+	require.NoError(t, err)
+
+	return storage
+}
+
+func TestEviction(t *testing.T) {
+	value := make([]byte, 40)
+
+	testSuites := []struct {
+		policy  config.EvictionPolicy
+		prepare func(t *testing.T, storage Storages, clock *fakeClock)
+		evicted string
+	}{
+		{
+			policy: config.EvictionLRU,
+			prepare: func(t *testing.T, storage Storages, clock *fakeClock) {
+				require.NoError(t, storage.Add([]byte("k0"), value, 0))
+				require.NoError(t, storage.Add([]byte("k1"), value, 0))
+				require.NoError(t, storage.Add([]byte("k2"), value, 0))
+
+				clock.Advance(time.Second)
+
+				buf, err := storage.Get([]byte("k0"))
+				require.NoError(t, err)
+				buf.Free()
+			},
+			evicted: "k1",
+		},
+		{
+			policy: config.EvictionLFU,
+			prepare: func(t *testing.T, storage Storages, _ *fakeClock) {
+				require.NoError(t, storage.Add([]byte("k0"), value, 0))
+				require.NoError(t, storage.Add([]byte("k1"), value, 0))
+				require.NoError(t, storage.Add([]byte("k2"), value, 0))
+
+				for i := 0; i < 2; i++ {
+					buf, err := storage.Get([]byte("k1"))
+					require.NoError(t, err)
+					buf.Free()
+				}
+			},
+			evicted: "k0",
+		},
+		{
+			policy: config.EvictionVolatileTTL,
+			prepare: func(t *testing.T, storage Storages, _ *fakeClock) {
+				require.NoError(t, storage.Add([]byte("k0"), value, 2*time.Minute))
+				require.NoError(t, storage.Add([]byte("k1"), value, time.Minute))
+				require.NoError(t, storage.Add([]byte("k2"), value, 0))
+			},
+			evicted: "k1",
+		},
+	}
+
+	fabrics := map[string]dictionaryFabric{
+		"map":     NewMapDictionary,
+		"syncmap": NewSyncMapDictionary,
+	}
+
+	for name, fabric := range fabrics {
+		for _, test := range testSuites {
+			clock := newFakeClock(time.Now())
+			storage := newEvictedStorages(t, test.policy, fabric, clock)
+
+			test.prepare(t, storage, clock)
+
+			require.NoError(t, storage.Add([]byte("k3"), value, 0), name+": "+string(test.policy))
+
+			for _, key := range []string{"k0", "k1", "k2", "k3"} {
+				buf, err := storage.Get([]byte(key))
+				if key == test.evicted {
+					assert.Equal(t, ErrKeyNotFound, err, name+": "+string(test.policy))
+					continue
+				}
+
+				require.NoError(t, err, name+": "+string(test.policy)+": "+key)
+				buf.Free()
+			}
+
+			stats := storage.Stats()
+			assert.Equal(t, uint64(1), stats.Evictions, name+": "+string(test.policy))
+			assert.Equal(t, uint64(1), stats.EvictedChunks, name+": "+string(test.policy))
+		}
+	}
+}
+
+func TestEviction_Journaled(t *testing.T) {
+	conf, cleanup := newTestJournalConfig(t)
+	defer cleanup()
+
+	clock := newFakeClock(time.Now())
+	conf.TimeS = clock
+	conf.Evict = config.EvictionLRU
+
+	pool, err := NewDataPool(NewMemoryPool(64, 3*64), conf.TimeS)
+	require.NoError(t, err)
+
+	journal := NewAppendOnlyLog(conf)
+
+	storage, err := NewInMemStorages(conf, NewMapDictionary(pool, conf.TimeS), journal)
+	require.NoError(t, err)
+	require.NoError(t, journal.Open(storage))
+
+	value := make([]byte, 40)
+
+	for _, key := range []string{"k0", "k1", "k2", "k3", "k0"} {
+		clock.Advance(time.Second)
+		// k3 evicts k0, which is stored again by evicting k1
+		require.NoError(t, storage.Add([]byte(key), value, 0), key)
+	}
+
+	require.NoError(t, journal.Close())
+
+	restoredStorage, restoredJournal := newTestJournaledStorages(t, conf)
+
+	_, err = restoredJournal.Replay(restoredStorage)
+	require.NoError(t, err)
+
+	_, err = restoredStorage.Get([]byte("k1"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	for _, key := range []string{"k0", "k2", "k3"} {
+		buf, err := restoredStorage.Get([]byte(key))
+		require.NoError(t, err, key)
+		buf.Free()
+	}
+}
+
+func TestEviction_TrackedChunk(t *testing.T) {
+	fabrics := map[string]dictionaryFabric{
+		"map":     NewMapDictionary,
+		"syncmap": NewSyncMapDictionary,
+	}
+
+	for name, fabric := range fabrics {
+		ts := constantTime(time.Now())
+
+		pool, err := NewDataPool(NewMemoryPool(256, 0), ts)
+		require.NoError(t, err)
+
+		dict := fabric(pool, ts)
+		value := make([]byte, 20)
+
+		// ten records of 24 bytes are stored by a chunk
+		keys := make(map[string]bool)
+
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("k%03d", i)
+			require.NoError(t, dict.Add(uint64(i), []byte(key), value, ts.Now().Add(time.Minute)), name)
+			keys[key] = true
+		}
+
+		// a replaced record leaves a stale hash in its previous chunk
+		require.NoError(t, dict.Add(0, []byte("k000"), value, ts.Now().Add(time.Minute)), name)
+
+		victim, ok := dict.Victim(lruPolicy{})
+		require.True(t, ok, name)
+
+		evicted := dict.Evict(victim)
+		require.NotEmpty(t, evicted, name)
+		assert.LessOrEqual(t, len(evicted), 10, name)
+
+		for _, key := range evicted {
+			delete(keys, string(key))
+		}
+
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("k%03d", i)
+
+			buf, err := dict.Get(uint64(i), []byte(key))
+			if !keys[key] {
+				assert.Equal(t, ErrKeyNotFound, err, name+": "+key)
+				continue
+			}
+
+			require.NoError(t, err, name+": "+key)
+			buf.Free()
+		}
+	}
+}
+
+func TestEviction_Disabled(t *testing.T) {
+	value := make([]byte, 40)
+
+	storage := newEvictedStorages(t, config.EvictionNone, NewMapDictionary, newFakeClock(time.Now()))
+
+	for _, key := range []string{"k0", "k1", "k2"} {
+		require.NoError(t, storage.Add([]byte(key), value, 0))
+	}
+
+	assert.Equal(t, ErrOutOfLimit, storage.Add([]byte("k3"), value, 0))
+	assert.Equal(t, uint64(0), storage.Stats().Evictions)
+}
+
+func TestEviction_LFUMeanHits(t *testing.T) {
+	fabrics := map[string]dictionaryFabric{
+		"map":     NewMapDictionary,
+		"syncmap": NewSyncMapDictionary,
+	}
+
+	for name, fabric := range fabrics {
+		ts := constantTime(time.Now())
+
+		pool, err := NewDataPool(NewMemoryPool(64, 0), ts)
+		require.NoError(t, err)
+
+		var (
+			dict   = fabric(pool, ts)
+			value  = make([]byte, 18)
+			hashes = make(map[string]uint64)
+		)
+
+		// three records of 20 bytes are stored by a chunk, c0 is in the current chunk
+		for i, key := range []string{"a0", "a1", "a2", "b0", "b1", "b2", "c0"} {
+			hashes[key] = uint64(i)
+			require.NoError(t, dict.Add(hashes[key], []byte(key), value, ts.Now().Add(time.Minute)), name)
+		}
+
+		require.NoError(t, dict.Delete(hashes["a1"], []byte("a1")), name)
+		require.NoError(t, dict.Delete(hashes["a2"], []byte("a2")), name)
+
+		// a0 is hit twice, b0, b1 and b2 are hit once, so the chunk of b-keys has more hits in total
+		for _, key := range []string{"a0", "a0", "b0", "b1", "b2"} {
+			buf, err := dict.Get(hashes[key], []byte(key))
+			require.NoError(t, err, name)
+			buf.Free()
+		}
+
+		victim, ok := dict.Victim(lfuPolicy{})
+		require.True(t, ok, name)
+		assert.ElementsMatch(t, [][]byte{[]byte("b0"), []byte("b1"), []byte("b2")}, dict.Evict(victim), name)
+	}
+}
+
+func TestEviction_VolatileTTLPersistent(t *testing.T) {
+	fabrics := map[string]dictionaryFabric{
+		"map":     NewMapDictionary,
+		"syncmap": NewSyncMapDictionary,
+	}
+
+	for name, fabric := range fabrics {
+		ts := constantTime(time.Now())
+
+		pool, err := NewDataPool(NewMemoryPool(64, 0), ts)
+		require.NoError(t, err)
+
+		dict := fabric(pool, ts)
+		value := make([]byte, 40)
+
+		for i, key := range []string{"k0", "k1", "k2"} {
+			require.NoError(t, dict.Add(uint64(i), []byte(key), value, NoExpiration), name)
+		}
+
+		_, ok := dict.Victim(volatileTTLPolicy{})
+		assert.False(t, ok, name)
+
+		require.NoError(t, dict.Add(3, []byte("k3"), value, ts.Now().Add(time.Minute)), name)
+		require.NoError(t, dict.Add(4, []byte("k4"), value, NoExpiration), name)
+
+		victim, ok := dict.Victim(volatileTTLPolicy{})
+		require.True(t, ok, name)
+		assert.Equal(t, [][]byte{[]byte("k3")}, dict.Evict(victim), name)
+	}
+}
+
+func TestPartitionedDictionary_Evict(t *testing.T) {
+	policy := lruPolicy{}
+	partitions := []*mockDataDictionary{{}, {}, {}}
+	victim := Victim{owner: partitions[1], score: 10}
+
+	partitions[0].On("Victim", policy).Return(Victim{owner: partitions[0], score: 20}, true)
+	partitions[1].On("Victim", policy).Return(victim, true)
+	partitions[1].On("Evict", victim).Return([][]byte{[]byte("k1"), []byte("k2")})
+	partitions[2].On("Victim", policy).Return(Victim{}, false)
+
+	index := 0
+	dict, err := NewPartitionedDictionary(4, 0x3, func() (DataDictionary, error) {
+		partition := partitions[index%len(partitions)]
+		index++
+
+		return partition, nil
+	})
+	require.NoError(t, err)
+
+	candidate, ok := dict.Victim(policy)
+	require.True(t, ok)
+	assert.Equal(t, victim, candidate)
+	assert.Equal(t, [][]byte{[]byte("k1"), []byte("k2")}, dict.Evict(candidate))
+
+	for _, partition := range partitions {
+		partition.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,486 @@
+package storages
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+)
+
+const (
+	journalMagic             = "KVSJ"
+	journalVersion    uint16 = 2
+	journalHeaderSize        = len(journalMagic) + 2
+
+	journalOpAdd    byte = 1
+	journalOpDelete byte = 2
+	journalOpExpire byte = 3
+
+	journalSyncInterval   = 1 * time.Second
+	journalMinCompactSize = 1024 * 1024
+)
+
+var (
+	_ Journal = nopJournal{}
+	_ Journal = (*AppendOnlyLog)(nil)
+)
+
+type Journal interface {
+	Add(key, body []byte, flags uint32, expiration time.Time) error
+	Expire(key []byte, expiration time.Time) error
+	Delete(key []byte) error
+}
+
+type nopJournal struct{}
+
+func (nopJournal) Add(_, _ []byte, _ uint32, _ time.Time) error {
+	return nil
+}
+
+func (nopJournal) Expire(_ []byte, _ time.Time) error {
+	return nil
+}
+
+func (nopJournal) Delete(_ []byte) error {
+	return nil
+}
+
+type AppendOnlyLog struct {
+	sync.Mutex
+
+	path   string
+	policy config.FsyncPolicy
+
+	storages    Storages
+	file        *os.File
+	buf         *bufio.Writer
+	size        int64
+	compactSize int64
+	entry       []byte
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+func NewAppendOnlyLog(conf config.Config) *AppendOnlyLog {
+	return &AppendOnlyLog{
+		path:   conf.JournalPath(),
+		policy: conf.JournalFsync(),
+	}
+}
+
+func (o *AppendOnlyLog) ID() string {
+	return "journal"
+}
+
+func (o *AppendOnlyLog) Replay(storages Storages) (int, error) {
+	f, err := os.Open(o.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	count := 0
+
+	_, err = readJournal(f, func(op byte, key, value []byte, flags uint32, expiration time.Time) error {
+		var err error
+
+		switch op {
+		case journalOpAdd:
+			err = storages.AddUntilIf(key, value, flags, expiration, Always)
+		case journalOpExpire:
+			err = storages.ExpireAt(key, expiration)
+			if err == ErrInvalidTTL {
+				err = storages.Delete(key)
+			}
+		case journalOpDelete:
+			err = storages.Delete(key)
+		}
+
+		switch err {
+		case nil:
+			count++
+			return nil
+		case ErrInvalidTTL, ErrKeyNotFound, ErrKeyExpired:
+			return nil
+		default:
+			return err
+		}
+	})
+
+	return count, err
+}
+
+func (o *AppendOnlyLog) Open(storages Storages) error {
+	o.Lock()
+	defer o.Unlock()
+
+	f, err := os.OpenFile(o.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	size, err := readJournal(f, func(_ byte, _, _ []byte, _ uint32, _ time.Time) error {
+		return nil
+	})
+	if err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	err = o.attach(f, size)
+	if err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	o.storages = storages
+	o.compactSize = size
+	o.stop = make(chan struct{})
+	o.done = make(chan struct{})
+
+	go o.syncLoop()
+
+	return nil
+}
+
+func (o *AppendOnlyLog) attach(f *os.File, size int64) error {
+	if size == 0 {
+		header := make([]byte, journalHeaderSize)
+		copy(header, journalMagic)
+		binary.BigEndian.PutUint16(header[len(journalMagic):], journalVersion)
+
+		err := f.Truncate(0)
+		if err != nil {
+			return err
+		}
+
+		_, err = f.WriteAt(header, 0)
+		if err != nil {
+			return err
+		}
+
+		size = int64(journalHeaderSize)
+	}
+
+	err := f.Truncate(size)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Seek(size, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	o.file = f
+	o.buf = bufio.NewWriter(f)
+	o.size = size
+
+	return nil
+}
+
+func (o *AppendOnlyLog) Add(key, body []byte, flags uint32, expiration time.Time) error {
+	return o.append(journalOpAdd, key, body, flags, expiration)
+}
+
+func (o *AppendOnlyLog) Expire(key []byte, expiration time.Time) error {
+	return o.append(journalOpExpire, key, nil, 0, expiration)
+}
+
+func (o *AppendOnlyLog) Delete(key []byte) error {
+	return o.append(journalOpDelete, key, nil, 0, time.Time{})
+}
+
+func (o *AppendOnlyLog) append(op byte, key, value []byte, flags uint32, expiration time.Time) error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.file == nil {
+		return nil
+	}
+
+	o.entry = encodeJournalEntry(o.entry[:0], op, key, value, flags, expiration)
+
+	_, err := o.buf.Write(o.entry)
+	if err != nil {
+		return err
+	}
+
+	o.size += int64(len(o.entry))
+
+	if o.policy != config.FsyncAlways {
+		return nil
+	}
+
+	return o.sync()
+}
+
+func (o *AppendOnlyLog) sync() error {
+	err := o.buf.Flush()
+	if err != nil {
+		return err
+	}
+
+	if o.policy == config.FsyncNever {
+		return nil
+	}
+
+	return o.file.Sync()
+}
+
+func (o *AppendOnlyLog) syncLoop() {
+	ticker := time.NewTicker(journalSyncInterval)
+
+	defer func() {
+		ticker.Stop()
+		close(o.done)
+	}()
+
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+		}
+
+		o.Lock()
+		if o.file != nil {
+			_ = o.sync()
+		}
+		o.Unlock()
+	}
+}
+
+func (o *AppendOnlyLog) Clean(ctx context.Context) error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.file == nil || o.size < journalMinCompactSize || o.size < 2*o.compactSize {
+		return nil
+	}
+
+	return o.compact(ctx)
+}
+
+func (o *AppendOnlyLog) compact(ctx context.Context) error {
+	tmpPath := o.path + ".tmp"
+
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	err = o.rewrite(ctx, f)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpPath)
+
+		return err
+	}
+
+	err = os.Rename(tmpPath, o.path)
+	if err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	_ = o.file.Close()
+
+	o.file = f
+	o.buf = bufio.NewWriter(f)
+	o.compactSize = o.size
+
+	return nil
+}
+
+func (o *AppendOnlyLog) rewrite(ctx context.Context, f *os.File) error {
+	buf := bufio.NewWriter(f)
+
+	header := make([]byte, journalHeaderSize)
+	copy(header, journalMagic)
+	binary.BigEndian.PutUint16(header[len(journalMagic):], journalVersion)
+
+	_, err := buf.Write(header)
+	if err != nil {
+		return err
+	}
+
+	size := int64(len(header))
+
+	err = o.storages.Range(ctx, func(key, value []byte, flags uint32, expiration time.Time) error {
+		o.entry = encodeJournalEntry(o.entry[:0], journalOpAdd, key, value, flags, expiration)
+		size += int64(len(o.entry))
+
+		_, err := buf.Write(o.entry)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = buf.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+
+	o.size = size
+
+	return nil
+}
+
+func (o *AppendOnlyLog) Close() error {
+	o.Lock()
+
+	if o.file == nil {
+		o.Unlock()
+
+		return nil
+	}
+
+	close(o.stop)
+
+	err := o.buf.Flush()
+	if err == nil {
+		err = o.file.Sync()
+	}
+
+	if closeErr := o.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	o.file = nil
+	o.Unlock()
+
+	<-o.done
+
+	return err
+}
+
+func encodeJournalEntry(buf []byte, op byte, key, value []byte, flags uint32, expiration time.Time) []byte {
+	var num [binary.MaxVarintLen64]byte
+
+	start := len(buf)
+
+	buf = append(buf, op)
+
+	binary.BigEndian.PutUint64(num[:8], uint64(unixNano(expiration)))
+	buf = append(buf, num[:8]...)
+
+	binary.BigEndian.PutUint32(num[:4], flags)
+	buf = append(buf, num[:4]...)
+
+	for _, item := range [][]byte{key, value} {
+		n := binary.PutUvarint(num[:], uint64(len(item)))
+		buf = append(buf, num[:n]...)
+		buf = append(buf, item...)
+	}
+
+	binary.BigEndian.PutUint32(num[:4], crc32.Checksum(buf[start:], snapshotTable))
+
+	return append(buf, num[:4]...)
+}
+
+func readJournal(
+	r io.Reader,
+	fn func(op byte, key, value []byte, flags uint32, expiration time.Time) error,
+) (int64, error) {
+	reader := &snapshotReader{
+		r:   bufio.NewReader(r),
+		crc: crc32.New(snapshotTable),
+	}
+
+	header := make([]byte, journalHeaderSize)
+
+	_, err := io.ReadFull(reader, header)
+	if err == io.EOF {
+		return 0, nil
+	}
+
+	if err != nil || string(header[:len(journalMagic)]) != journalMagic {
+		return 0, ErrJournalCorrupted
+	}
+
+	if binary.BigEndian.Uint16(header[len(journalMagic):]) != journalVersion {
+		return 0, ErrJournalVersion
+	}
+
+	var (
+		size  = int64(journalHeaderSize)
+		fixed = make([]byte, 8)
+		key   []byte
+		value []byte
+	)
+
+	for {
+		reader.crc.Reset()
+
+		op, err := reader.ReadByte()
+		if err != nil || (op != journalOpAdd && op != journalOpExpire && op != journalOpDelete) {
+			return size, nil
+		}
+
+		_, err = io.ReadFull(reader, fixed)
+		if err != nil {
+			return size, nil
+		}
+
+		expiration := time.Unix(0, int64(binary.BigEndian.Uint64(fixed)))
+
+		_, err = io.ReadFull(reader, fixed[:4])
+		if err != nil {
+			return size, nil
+		}
+
+		flags := binary.BigEndian.Uint32(fixed[:4])
+
+		key, err = readSnapshotItem(reader, key)
+		if err != nil {
+			return size, nil
+		}
+
+		value, err = readSnapshotItem(reader, value)
+		if err != nil {
+			return size, nil
+		}
+
+		expectedSum := reader.crc.Sum32()
+
+		_, err = io.ReadFull(reader.r, fixed[:4])
+		if err != nil || binary.BigEndian.Uint32(fixed[:4]) != expectedSum {
+			return size, nil
+		}
+
+		err = fn(op, key, value, flags, expiration)
+		if err != nil {
+			return size, err
+		}
+
+		size += int64(1 + 8 + 4 + uvarintLen(len(key)) + len(key) + uvarintLen(len(value)) + len(value) + 4)
+	}
+}
+
+func uvarintLen(v int) int {
+	var num [binary.MaxVarintLen64]byte
+
+	return binary.PutUvarint(num[:], uint64(v))
+}
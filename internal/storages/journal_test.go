@@ -0,0 +1,269 @@
+package storages
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJournalConfig(t *testing.T) (*mockConfig, func()) {
+	dir, err := ioutil.TempDir("", "kvs-journal")
+	require.NoError(t, err)
+
+	conf := &mockConfig{
+		Exp:     1 * time.Minute,
+		TimeS:   constantTime(time.Now()),
+		Journal: filepath.Join(dir, "kvs.journal"),
+		Fsync:   config.FsyncAlways,
+	}
+
+	return conf, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func newTestJournaledStorages(t *testing.T, conf *mockConfig) (Storages, *AppendOnlyLog) {
+	pool, err := NewDataPool(NewMemoryPool(1024, 0), conf.TimeS)
+	require.NoError(t, err)
+
+	journal := NewAppendOnlyLog(conf)
+
+	storage, err := NewInMemStorages(conf, NewMapDictionary(pool, conf.TimeS), journal)
+	require.NoError(t, err)
+
+	return storage, journal
+}
+
+func TestAppendOnlyLog_Replay(t *testing.T) {
+	conf, cleanup := newTestJournalConfig(t)
+	defer cleanup()
+
+	storage, journal := newTestJournaledStorages(t, conf)
+
+	err := journal.Open(storage)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.Add([]byte("/1"), []byte("value-1"), 0))
+	require.NoError(t, storage.Add([]byte("/2"), []byte("value-2"), 0))
+	require.NoError(t, storage.Add([]byte("/1"), []byte("value-3"), 0))
+	require.NoError(t, storage.Delete([]byte("/2")))
+	require.NoError(t, storage.Expire([]byte("/1"), time.Hour))
+	// an update keeps flags of a value
+	require.NoError(t, storage.AddUntilIf([]byte("/3"), []byte("1"), 42, conf.TimeS.Now().Add(time.Hour), Always))
+	_, err = storage.Incr([]byte("/3"), 1, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, journal.Close())
+
+	restoredStorage, restoredJournal := newTestJournaledStorages(t, conf)
+
+	replayed, err := restoredJournal.Replay(restoredStorage)
+	require.NoError(t, err)
+	assert.Equal(t, 7, replayed)
+
+	ttl, err := restoredStorage.TTL([]byte("/1"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, ttl)
+
+	buf, err := restoredStorage.Get([]byte("/1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value-3"), buf.Bytes())
+	buf.Free()
+
+	_, err = restoredStorage.Get([]byte("/2"))
+	assert.EqualError(t, err, ErrKeyNotFound.Error())
+
+	buf, meta, err := restoredStorage.GetMeta([]byte("/3"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("2"), buf.Bytes())
+	assert.Equal(t, uint32(42), meta.Flags)
+	buf.Free()
+}
+
+func TestAppendOnlyLog_ReplayPassedExpiration(t *testing.T) {
+	conf, cleanup := newTestJournalConfig(t)
+	defer cleanup()
+
+	storage, journal := newTestJournaledStorages(t, conf)
+
+	require.NoError(t, journal.Open(storage))
+
+	require.NoError(t, storage.Add([]byte("/1"), []byte("value-1"), Persistent))
+	require.NoError(t, storage.Expire([]byte("/1"), time.Second))
+
+	require.NoError(t, journal.Close())
+
+	conf.TimeS = constantTime(conf.TimeS.Now().Add(time.Minute))
+
+	restoredStorage, restoredJournal := newTestJournaledStorages(t, conf)
+
+	replayed, err := restoredJournal.Replay(restoredStorage)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+
+	_, err = restoredStorage.TTL([]byte("/1"))
+	assert.EqualError(t, err, ErrKeyNotFound.Error())
+}
+
+func TestAppendOnlyLog_TornTail(t *testing.T) {
+	conf, cleanup := newTestJournalConfig(t)
+	defer cleanup()
+
+	storage, journal := newTestJournaledStorages(t, conf)
+
+	require.NoError(t, journal.Open(storage))
+	require.NoError(t, storage.Add([]byte("/1"), []byte("value-1"), 0))
+	require.NoError(t, storage.Add([]byte("/2"), []byte("value-2"), 0))
+	require.NoError(t, journal.Close())
+
+	info, err := os.Stat(conf.Journal)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(conf.Journal, info.Size()-2))
+
+	restoredStorage, restoredJournal := newTestJournaledStorages(t, conf)
+
+	replayed, err := restoredJournal.Replay(restoredStorage)
+	require.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+
+	// a torn entry is cut and new entries follow valid ones
+	require.NoError(t, restoredJournal.Open(restoredStorage))
+	require.NoError(t, restoredStorage.Add([]byte("/3"), []byte("value-3"), 0))
+	require.NoError(t, restoredJournal.Close())
+
+	replayedStorage, replayedJournal := newTestJournaledStorages(t, conf)
+
+	replayed, err = replayedJournal.Replay(replayedStorage)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+}
+
+func TestAppendOnlyLog_Compact(t *testing.T) {
+	conf, cleanup := newTestJournalConfig(t)
+	defer cleanup()
+
+	storage, journal := newTestJournaledStorages(t, conf)
+
+	require.NoError(t, journal.Open(storage))
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, storage.Add([]byte("/1"), []byte("value-1"), 0))
+	}
+
+	journal.Lock()
+	sizeBefore := journal.size
+	err := journal.compact(context.Background())
+	sizeAfter := journal.size
+	journal.Unlock()
+
+	require.NoError(t, err)
+	assert.Less(t, sizeAfter, sizeBefore)
+
+	require.NoError(t, storage.Add([]byte("/2"), []byte("value-2"), 0))
+	require.NoError(t, journal.Close())
+
+	restoredStorage, restoredJournal := newTestJournaledStorages(t, conf)
+
+	replayed, err := restoredJournal.Replay(restoredStorage)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+}
+
+func TestAppendOnlyLog_ReplayConcurrentKey(t *testing.T) {
+	const (
+		keys    = 128
+		workers = 8
+		writes  = 40
+	)
+
+	conf, cleanup := newTestJournalConfig(t)
+	defer cleanup()
+
+	conf.Fsync = config.FsyncNever
+
+	pool, err := NewDataPool(NewMemoryPool(1024, 0), conf.TimeS)
+	require.NoError(t, err)
+
+	journal := NewAppendOnlyLog(conf)
+
+	storage, err := NewInMemStorages(conf, NewMapDictionary(pool, conf.TimeS), yieldingJournal{journal})
+	require.NoError(t, err)
+
+	require.NoError(t, journal.Open(storage))
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for k := 0; k < keys; k++ {
+				key := []byte(fmt.Sprintf("/key-%d", k))
+
+				for i := 0; i < writes; i++ {
+					switch i % 4 {
+					case 0:
+						_ = storage.Delete(key)
+					case 1:
+						_, _ = storage.Incr(key, 1, 0)
+					default:
+						_ = storage.Add(key, []byte(fmt.Sprintf("value-%d-%d", w, i)), 0)
+					}
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	require.NoError(t, journal.Close())
+
+	restoredStorage, restoredJournal := newTestJournaledStorages(t, conf)
+
+	_, err = restoredJournal.Replay(restoredStorage)
+	require.NoError(t, err)
+
+	// the journal ends with the last change applied to each key
+	for k := 0; k < keys; k++ {
+		key := []byte(fmt.Sprintf("/key-%d", k))
+
+		expected, expectedErr := storage.Get(key)
+		buf, err := restoredStorage.Get(key)
+		require.Equal(t, expectedErr, err, string(key))
+
+		if expectedErr == nil {
+			assert.Equal(t, string(expected.Bytes()), string(buf.Bytes()), string(key))
+			expected.Free()
+			buf.Free()
+		}
+	}
+}
+
+// yieldingJournal lets other writers run between changing a dictionary and journaling the change.
+type yieldingJournal struct {
+	Journal
+}
+
+func (o yieldingJournal) Add(key, body []byte, flags uint32, expiration time.Time) error {
+	runtime.Gosched()
+
+	return o.Journal.Add(key, body, flags, expiration)
+}
+
+func (o yieldingJournal) Delete(key []byte) error {
+	runtime.Gosched()
+
+	return o.Journal.Delete(key)
+}
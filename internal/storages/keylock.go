@@ -0,0 +1,48 @@
+package storages
+
+import (
+	"sort"
+	"sync"
+)
+
+const keyLockStripes = 256
+
+type keyLocks struct {
+	stripes [keyLockStripes]sync.Mutex
+}
+
+func (o *keyLocks) lock(hash uint64) {
+	o.stripes[hash%keyLockStripes].Lock()
+}
+
+func (o *keyLocks) unlock(hash uint64) {
+	o.stripes[hash%keyLockStripes].Unlock()
+}
+
+// lockAll locks stripes of all hashes in ascending order to avoid deadlocks. It returns locked stripes.
+func (o *keyLocks) lockAll(hashes []uint64) []int {
+	stripes := make([]int, 0, len(hashes))
+	seen := make(map[int]struct{}, len(hashes))
+
+	for _, hash := range hashes {
+		stripe := int(hash % keyLockStripes)
+		if _, ok := seen[stripe]; !ok {
+			seen[stripe] = struct{}{}
+			stripes = append(stripes, stripe)
+		}
+	}
+
+	sort.Ints(stripes)
+
+	for _, stripe := range stripes {
+		o.stripes[stripe].Lock()
+	}
+
+	return stripes
+}
+
+func (o *keyLocks) unlockAll(stripes []int) {
+	for _, stripe := range stripes {
+		o.stripes[stripe].Unlock()
+	}
+}
@@ -1,11 +1,14 @@
 package storages
 
 import (
+	"bytes"
 	"context"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/7phs/kvs/internal/config"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -67,49 +70,431 @@ func (o *expiredList) Clean(fn func(keys []uint64) bool) {
 type MapDictionary struct {
 	sync.RWMutex
 
-	pool    DataPool
-	data    map[uint64]record
-	expired expiredList
+	pool       DataPool
+	timeSource config.TimeSource
+	data       map[uint64]*record
+	expired    expiredList
+	collisions uint64
+
+	evictions     uint64
+	evictedChunks uint64
+	relocations   uint64
+
+	live liveCounter
 }
 
-func NewMapDictionary(pool DataPool) DataDictionary {
+func NewMapDictionary(pool DataPool, timeSource config.TimeSource) DataDictionary {
 	return &MapDictionary{
-		pool:    pool,
-		data:    make(map[uint64]record, preAllocatedCap),
-		expired: newExpiredList(preAllocatedCap, clearedPortionSize),
+		pool:       pool,
+		timeSource: timeSource,
+		data:       make(map[uint64]*record, preAllocatedCap),
+		expired:    newExpiredList(preAllocatedCap, clearedPortionSize),
 	}
 }
 
-func (o *MapDictionary) Add(key uint64, data []byte, expiration time.Time) error {
-	buf, err := o.pool.Copy(data, expiration)
+func (o *MapDictionary) Add(hash uint64, key, data []byte, expiration time.Time) error {
+	return o.AddIf(hash, key, data, 0, expiration, Always)
+}
+
+func (o *MapDictionary) AddIf(hash uint64, key, data []byte, flags uint32, expiration time.Time, cond Condition) error {
+	buf, err := o.pool.Copy(key, data, expiration)
 	if err != nil {
 		return err
 	}
 
+	now := o.timeSource.Now()
+
 	o.Lock()
-	defer o.Unlock()
+	chain := o.data[hash]
+
+	if !cond.met(chain.lookup(key), now) {
+		o.Unlock()
+		buf.release()
+
+		return ErrConditionFailed
+	}
+
+	rec := newRecord(hash, len(key), buf, expiration, now)
+	rec.flags = flags
+	o.live.store(rec)
+
+	head, replaced := chain.replace(rec)
+	o.data[hash] = head
+	o.Unlock()
+
+	if chain != nil && len(replaced) == 0 {
+		atomic.AddUint64(&o.collisions, 1)
+	}
 
-	o.data[key] = newRecord(buf, expiration)
+	o.live.release(replaced)
 
 	return nil
 }
 
-func (o *MapDictionary) Get(key uint64) (Buffer, error) {
+func (o *MapDictionary) Update(
+	hash uint64,
+	key []byte,
+	expiration time.Time,
+	fn UpdateFunc,
+) (time.Time, uint32, error) {
+	now := o.timeSource.Now()
+
+	o.Lock()
+	chain := o.data[hash]
+
+	buf, expiration, flags, err := updateRecord(o.pool, chain.lookup(key), key, expiration, now, fn)
+	if err != nil {
+		o.Unlock()
+
+		return time.Time{}, 0, err
+	}
+
+	rec := newRecord(hash, len(key), buf, expiration, now)
+	rec.flags = flags
+	o.live.store(rec)
+
+	head, replaced := chain.replace(rec)
+	o.data[hash] = head
+	o.Unlock()
+
+	if chain != nil && len(replaced) == 0 {
+		atomic.AddUint64(&o.collisions, 1)
+	}
+
+	o.live.release(replaced)
+
+	return expiration, flags, nil
+}
+
+func (o *MapDictionary) Get(hash uint64, key []byte) (Buffer, error) {
+	buf, _, err := o.GetMeta(hash, key)
+
+	return buf, err
+}
+
+func (o *MapDictionary) GetMeta(hash uint64, key []byte) (Buffer, Meta, error) {
+	now := o.timeSource.Now()
+
 	o.RLock()
-	rec, ok := o.data[key]
+	rec := o.data[hash].lookup(key)
+	if rec != nil {
+		// take a reference before unlocking, so a concurrent Delete can't release the chunk under the reader
+		if buf, ok := rec.acquire(now); ok {
+			rec.hit(now)
+			o.RUnlock()
+
+			return buf, rec.meta(), nil
+		}
+	}
 	o.RUnlock()
 
-	if !ok {
+	if rec == nil {
+		return Buffer{}, Meta{}, ErrKeyNotFound
+	}
+
+	o.expired.push(hash)
+
+	return Buffer{}, Meta{}, ErrKeyExpired
+}
+
+func (o *MapDictionary) Expiration(hash uint64, key []byte) (time.Time, error) {
+	o.RLock()
+	rec := o.data[hash].lookup(key)
+	o.RUnlock()
+
+	if rec == nil {
+		return time.Time{}, ErrKeyNotFound
+	}
+
+	if rec.isExpired(o.timeSource.Now()) {
+		o.expired.push(hash)
+
+		return time.Time{}, ErrKeyExpired
+	}
+
+	return rec.expiration, nil
+}
+
+func (o *MapDictionary) Expire(hash uint64, key []byte, expiration time.Time) (time.Time, error) {
+	o.Lock()
+	chain := o.data[hash]
+	rec := chain.lookup(key)
+
+	if rec == nil {
+		o.Unlock()
+
+		return time.Time{}, ErrKeyNotFound
+	}
+
+	if rec.isExpired(o.timeSource.Now()) {
+		o.Unlock()
+		o.expired.push(hash)
+
+		return time.Time{}, ErrKeyExpired
+	}
+
+	extended := rec.expire(expiration)
+
+	o.data[hash], _ = chain.relocate(rec, extended)
+	o.Unlock()
+
+	o.live.expire(rec.expiration, expiration)
+
+	return rec.expiration, nil
+}
+
+func (o *MapDictionary) Delete(hash uint64, key []byte) error {
+	o.Lock()
+	head, removed := o.data[hash].remove(func(rec *record) bool {
+		return bytes.Equal(rec.key, key)
+	})
+	o.set(hash, head)
+	o.Unlock()
+
+	if len(removed) == 0 {
+		return ErrKeyNotFound
+	}
+
+	expired := removed[0].isExpired(o.timeSource.Now())
+
+	o.live.release(removed)
+
+	if expired {
+		return ErrKeyExpired
+	}
+
+	return nil
+}
+
+func (o *MapDictionary) GetDelete(hash uint64, key []byte) (Buffer, error) {
+	var (
+		buf      Buffer
+		acquired bool
+		now      = o.timeSource.Now()
+	)
+
+	o.Lock()
+	head, removed := o.data[hash].remove(func(rec *record) bool {
+		return bytes.Equal(rec.key, key)
+	})
+	o.set(hash, head)
+
+	if len(removed) > 0 {
+		buf, acquired = removed[0].acquire(now)
+	}
+	o.Unlock()
+
+	if len(removed) == 0 {
+		return Buffer{}, ErrKeyNotFound
+	}
+
+	o.live.release(removed)
+
+	if !acquired {
+		return Buffer{}, ErrKeyExpired
+	}
+
+	removed[0].hit(now)
+
+	return buf, nil
+}
+
+func (o *MapDictionary) GetExpire(hash uint64, key []byte, expiration time.Time) (Buffer, error) {
+	o.Lock()
+	now := o.timeSource.Now()
+	chain := o.data[hash]
+	rec := chain.lookup(key)
+
+	if rec == nil {
+		o.Unlock()
+
 		return Buffer{}, ErrKeyNotFound
 	}
 
-	if !rec.isExpired() {
-		return rec.get(), nil
+	buf, ok := rec.acquire(now)
+	if !ok {
+		o.Unlock()
+		o.expired.push(hash)
+
+		return Buffer{}, ErrKeyExpired
+	}
+
+	extended := rec.expire(expiration)
+	extended.hit(now)
+
+	o.data[hash], _ = chain.relocate(rec, extended)
+	o.Unlock()
+
+	o.live.expire(rec.expiration, expiration)
+
+	return buf, nil
+}
+
+func (o *MapDictionary) Scan(prefix []byte, cursor uint64, limit int) []ScanEntry {
+	var (
+		page    = newScanPage(limit)
+		entries []ScanEntry
+		now     = o.timeSource.Now()
+		match   = func(rec *record) bool {
+			return matchScan(rec, prefix, now)
+		}
+	)
+
+	o.RLock()
+	defer o.RUnlock()
+
+	for hash, chain := range o.data {
+		if hash >= cursor && page.accepts(hash) && chain.any(match) {
+			page.push(hash, chain)
+		}
+	}
+
+	for _, candidate := range page.sorted() {
+		entries = collectScan(entries, candidate.hash, candidate.chain, prefix, now)
 	}
 
-	go o.expired.push(key)
+	return entries
+}
+
+func (o *MapDictionary) Range(ctx context.Context, fn RangeFunc) error {
+	o.RLock()
+	hashes := make([]uint64, 0, len(o.data))
+	for hash := range o.data {
+		hashes = append(hashes, hash)
+	}
+	o.RUnlock()
+
+	var (
+		items []rangeItem
+		now   = o.timeSource.Now()
+	)
+
+	for _, hash := range hashes {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		o.RLock()
+		items = acquireChain(items[:0], o.data[hash], now)
+		o.RUnlock()
+
+		err := rangeItems(items, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *MapDictionary) Victim(policy EvictionPolicy) (Victim, bool) {
+	scores := newChunkScores(policy, o.pool)
+
+	o.RLock()
+	for _, chain := range o.data {
+		scores.add(chain)
+
+		if scores.sampled() {
+			break
+		}
+	}
+	o.RUnlock()
 
-	return Buffer{}, ErrKeyExpired
+	return scores.victim(o)
+}
+
+func (o *MapDictionary) Evict(victim Victim) [][]byte {
+	var (
+		match   = inChunk(victim.chunk)
+		evicted []*record
+	)
+
+	o.Lock()
+	for _, hash := range victim.chunk.trackedHashes() {
+		head, removed := o.data[hash].remove(match)
+		if len(removed) > 0 {
+			o.set(hash, head)
+			evicted = append(evicted, removed...)
+		}
+	}
+	o.Unlock()
+
+	return o.reclaim(evicted)
+}
+
+func (o *MapDictionary) reclaim(evicted []*record) [][]byte {
+	if len(evicted) == 0 {
+		return nil
+	}
+
+	keys := evictedKeys(evicted)
+
+	o.live.release(evicted)
+
+	atomic.AddUint64(&o.evictions, uint64(len(evicted)))
+	atomic.AddUint64(&o.evictedChunks, 1)
+
+	_ = o.pool.Clean(context.Background())
+
+	return keys
+}
+
+func (o *MapDictionary) Compact(ctx context.Context, ratio float64) error {
+	var (
+		usage       = newChunkUsage(o.pool, o.timeSource.Now())
+		relocations []relocation
+	)
+
+	o.RLock()
+	for _, chain := range o.data {
+		usage.add(chain)
+	}
+
+	usage.sparse(ratio)
+
+	if len(usage.live) > 0 {
+		for hash, chain := range o.data {
+			relocations = usage.collect(relocations, hash, chain)
+		}
+	}
+	o.RUnlock()
+
+	relocated := relocateRecords(ctx, o.pool, relocations, func(hash uint64, old, moved *record) bool {
+		o.Lock()
+		defer o.Unlock()
+
+		head, ok := o.data[hash].relocate(old, moved)
+		if ok {
+			o.data[hash] = head
+		}
+
+		return ok
+	})
+
+	atomic.AddUint64(&o.relocations, relocated)
+
+	return o.pool.Clean(ctx)
+}
+
+func (o *MapDictionary) Stats() Stats {
+	stats := o.pool.Stats()
+	stats.Collisions = atomic.LoadUint64(&o.collisions)
+	stats.Evictions = atomic.LoadUint64(&o.evictions)
+	stats.EvictedChunks = atomic.LoadUint64(&o.evictedChunks)
+	stats.Relocations = atomic.LoadUint64(&o.relocations)
+
+	return o.live.add(stats)
+}
+
+func (o *MapDictionary) set(hash uint64, head *record) {
+	if head == nil {
+		delete(o.data, hash)
+		return
+	}
+
+	o.data[hash] = head
 }
 
 func (o *MapDictionary) Clean(ctx context.Context) error {
@@ -129,7 +514,7 @@ func (o *MapDictionary) Clean(ctx context.Context) error {
 }
 
 func (o *MapDictionary) cleanDictionary(ctx context.Context) error {
-	now := time.Now()
+	now := o.timeSource.Now()
 
 	o.expired.Clean(func(keys []uint64) bool {
 		select {
@@ -149,8 +534,13 @@ func (o *MapDictionary) cleanDictionary(ctx context.Context) error {
 
 		for _, k := range keys {
 			if k != prevK {
-				if r, ok := o.data[k]; ok && r.expiration.Before(now) {
-					delete(o.data, k)
+				if chain, ok := o.data[k]; ok {
+					head, removed := chain.remove(func(rec *record) bool {
+						return rec.isExpired(now)
+					})
+					o.set(k, head)
+
+					o.live.release(removed)
 				}
 			}
 
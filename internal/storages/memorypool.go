@@ -4,49 +4,232 @@ import (
 	"sync"
 )
 
+const (
+	maxFreeChunks = 64
+)
+
 var (
 	_ MemoryPool = (*memoryPool)(nil)
 )
 
 type MemoryPool interface {
 	Get() ([]byte, error)
+	Allocate(sz int) ([]byte, error)
 	Put(d []byte)
+	reserve(limit int64) (*bucketMemoryPool, error)
 }
 
+// memoryPool counts memory of reserved quotas apart, so its own buffers don't take memory reserved for others.
 type memoryPool struct {
-	sync.Pool
+	sync.Mutex
+
+	size              int
+	limit             int64
+	allocated         int64
+	reserved          int64
+	reservedAllocated int64
+	free              [][]byte
 }
 
-func NewMemoryPool(sz int) MemoryPool {
+func NewMemoryPool(sz int, limit int64) MemoryPool {
 	return &memoryPool{
-		Pool: sync.Pool{
-			New: func() interface{} {
-				return make([]byte, sz)
-			},
-		},
+		size:  sz,
+		limit: limit,
+		free:  make([][]byte, 0, maxFreeChunks),
 	}
 }
 
 func (o *memoryPool) Get() ([]byte, error) {
-	var (
-		buf []byte
-		err error
-	)
+	o.Lock()
+	defer o.Unlock()
+
+	if buf, ok := o.popFree(); ok {
+		return buf, nil
+	}
+
+	if o.limit > 0 && o.allocated-o.reservedAllocated+int64(o.size) > o.limit-o.reserved {
+		return nil, ErrOutOfLimit
+	}
+
+	o.allocated += int64(o.size)
+
+	return make([]byte, o.size), nil
+}
 
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				err = ErrOutOfLimit
-			}
-		}()
+func (o *memoryPool) Allocate(sz int) ([]byte, error) {
+	o.Lock()
+	defer o.Unlock()
 
-		buf, _ = o.Pool.Get().([]byte)
-	}()
+	if o.limit > 0 && o.allocated-o.reservedAllocated+int64(sz) > o.limit-o.reserved {
+		return nil, ErrOutOfLimit
+	}
+
+	o.allocated += int64(sz)
 
-	return buf, err
+	return make([]byte, sz), nil
 }
 
-//nolint:staticcheck
 func (o *memoryPool) Put(d []byte) {
-	o.Pool.Put(d)
+	o.Lock()
+	defer o.Unlock()
+
+	if len(d) == o.size && len(o.free) < maxFreeChunks {
+		o.free = append(o.free, d)
+		return
+	}
+
+	o.allocated -= int64(len(d))
+}
+
+func (o *memoryPool) reserve(limit int64) (*bucketMemoryPool, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.limit > 0 && (limit <= 0 || o.allocated-o.reservedAllocated+o.reserved+limit > o.limit) {
+		return nil, ErrOutOfLimit
+	}
+
+	o.reserved += limit
+
+	return &bucketMemoryPool{
+		parent: o,
+		limit:  limit,
+	}, nil
+}
+
+func (o *memoryPool) getReserved() ([]byte, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	buf, ok := o.popFree()
+	if !ok {
+		if o.limit > 0 && o.allocated+int64(o.size) > o.limit {
+			return nil, ErrOutOfLimit
+		}
+
+		o.allocated += int64(o.size)
+		buf = make([]byte, o.size)
+	}
+
+	o.reservedAllocated += int64(o.size)
+
+	return buf, nil
+}
+
+func (o *memoryPool) allocateReserved(sz int) ([]byte, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.limit > 0 && o.allocated+int64(sz) > o.limit {
+		return nil, ErrOutOfLimit
+	}
+
+	o.allocated += int64(sz)
+	o.reservedAllocated += int64(sz)
+
+	return make([]byte, sz), nil
+}
+
+func (o *memoryPool) releaseReserved(limit, sz int64) {
+	o.Lock()
+	defer o.Unlock()
+
+	o.reserved -= limit
+	o.allocated -= sz
+	o.reservedAllocated -= sz
+}
+
+func (o *memoryPool) popFree() ([]byte, bool) {
+	n := len(o.free)
+	if n == 0 {
+		return nil, false
+	}
+
+	buf := o.free[n-1]
+	o.free[n-1] = nil
+	o.free = o.free[:n-1]
+
+	return buf, true
+}
+
+type bucketMemoryPool struct {
+	sync.Mutex
+
+	parent    *memoryPool
+	limit     int64
+	allocated int64
+	dropped   bool
+}
+
+func (o *bucketMemoryPool) Get() ([]byte, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.dropped {
+		return nil, ErrBucketNotFound
+	}
+
+	if o.limit > 0 && o.allocated+int64(o.parent.size) > o.limit {
+		return nil, ErrOutOfLimit
+	}
+
+	buf, err := o.parent.getReserved()
+	if err != nil {
+		return nil, err
+	}
+
+	o.allocated += int64(len(buf))
+
+	return buf, nil
+}
+
+func (o *bucketMemoryPool) Allocate(sz int) ([]byte, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.dropped {
+		return nil, ErrBucketNotFound
+	}
+
+	if o.limit > 0 && o.allocated+int64(sz) > o.limit {
+		return nil, ErrOutOfLimit
+	}
+
+	buf, err := o.parent.allocateReserved(sz)
+	if err != nil {
+		return nil, err
+	}
+
+	o.allocated += int64(sz)
+
+	return buf, nil
+}
+
+func (o *bucketMemoryPool) Put(d []byte) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.dropped {
+		return
+	}
+
+	o.allocated -= int64(len(d))
+	o.parent.releaseReserved(0, int64(len(d)))
+}
+
+func (o *bucketMemoryPool) reserve(_ int64) (*bucketMemoryPool, error) {
+	return nil, ErrOutOfLimit
+}
+
+func (o *bucketMemoryPool) drop() {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.dropped {
+		return
+	}
+
+	o.parent.releaseReserved(o.limit, o.allocated)
+	o.allocated = 0
+	o.dropped = true
 }
@@ -9,7 +9,7 @@ import (
 
 func TestMemoryPool_Get(t *testing.T) {
 	sz := 1024
-	pool := NewMemoryPool(sz)
+	pool := NewMemoryPool(sz, 0)
 
 	buf, err := pool.Get()
 	require.NoError(t, err)
@@ -19,7 +19,7 @@ func TestMemoryPool_Get(t *testing.T) {
 func TestMemoryPool_Put(t *testing.T) {
 	sz := 1024
 	v := []byte("01234567")
-	pool := NewMemoryPool(sz)
+	pool := NewMemoryPool(sz, 0)
 
 	buf, err := pool.Get()
 	require.NoError(t, err)
@@ -33,3 +33,121 @@ func TestMemoryPool_Put(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, buf, sz)
 }
+
+func TestMemoryPool_Limit(t *testing.T) {
+	sz := 1024
+	pool := NewMemoryPool(sz, int64(2*sz))
+
+	buf1, err := pool.Get()
+	require.NoError(t, err)
+
+	_, err = pool.Get()
+	require.NoError(t, err)
+
+	_, err = pool.Get()
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrOutOfLimit.Error())
+
+	pool.Put(buf1)
+
+	buf, err := pool.Get()
+	require.NoError(t, err)
+	assert.Len(t, buf, sz)
+}
+
+func TestMemoryPool_Allocate(t *testing.T) {
+	sz := 1024
+	pool := NewMemoryPool(sz, int64(3*sz))
+
+	buf, err := pool.Allocate(2 * sz)
+	require.NoError(t, err)
+	assert.Len(t, buf, 2*sz)
+
+	_, err = pool.Allocate(2 * sz)
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrOutOfLimit.Error())
+
+	// a dedicated buffer isn't reused, but frees the limit
+	pool.Put(buf)
+
+	for i := 0; i < 3; i++ {
+		buf, err = pool.Get()
+		require.NoError(t, err)
+		assert.Len(t, buf, sz)
+	}
+}
+
+func TestBucketMemoryPool_Quota(t *testing.T) {
+	sz := 1024
+	shared := NewMemoryPool(sz, int64(3*sz))
+
+	pool, err := shared.reserve(int64(2 * sz))
+	require.NoError(t, err)
+
+	buf1, err := pool.Get()
+	require.NoError(t, err)
+
+	_, err = pool.Allocate(sz)
+	require.NoError(t, err)
+
+	// the quota is reached before the shared limit
+	_, err = pool.Get()
+	assert.Equal(t, ErrOutOfLimit, err)
+
+	_, err = pool.Allocate(sz)
+	assert.Equal(t, ErrOutOfLimit, err)
+
+	pool.Put(buf1)
+
+	_, err = pool.Get()
+	require.NoError(t, err)
+
+	// memory of a dropped pool is released in the shared one
+	pool.drop()
+
+	for i := 0; i < 3; i++ {
+		_, err = shared.Get()
+		require.NoError(t, err)
+	}
+
+	_, err = pool.Get()
+	assert.Equal(t, ErrBucketNotFound, err)
+}
+
+func TestBucketMemoryPool_Reserve(t *testing.T) {
+	sz := 1024
+	shared := NewMemoryPool(sz, int64(4*sz))
+
+	_, err := shared.Get()
+	require.NoError(t, err)
+
+	pool, err := shared.reserve(int64(2 * sz))
+	require.NoError(t, err)
+
+	// the rest of the limit is used or reserved
+	_, err = shared.reserve(int64(2 * sz))
+	assert.Equal(t, ErrOutOfLimit, err)
+
+	_, err = shared.reserve(0)
+	assert.Equal(t, ErrOutOfLimit, err)
+
+	// the shared pool doesn't take the reserved memory, even if it isn't used
+	_, err = shared.Get()
+	require.NoError(t, err)
+
+	_, err = shared.Get()
+	assert.Equal(t, ErrOutOfLimit, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = pool.Get()
+		require.NoError(t, err)
+	}
+
+	// the reserved memory is returned with a dropped pool
+	pool.drop()
+
+	for i := 0; i < 2; i++ {
+		_, err = shared.Get()
+		require.NoError(t, err)
+	}
+}
@@ -2,6 +2,7 @@ package storages
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/7phs/kvs/internal/config"
@@ -15,18 +16,63 @@ var (
 	_ refCounter     = (*mockDataPool)(nil)
 	_ DataPool       = (*mockDataPool)(nil)
 	_ DataDictionary = (*mockDataDictionary)(nil)
+	_ Journal        = (*mockJournal)(nil)
 )
 
+// dictionaryFabric builds a dictionary of one mode over the pool.
+type dictionaryFabric func(pool DataPool, timeSource config.TimeSource) DataDictionary
+
 type constantTime time.Time
 
 func (o constantTime) Now() time.Time {
 	return time.Time(o)
 }
 
+type systemTime struct{}
+
+func (systemTime) Now() time.Time {
+	return time.Now()
+}
+
+// fakeClock is a time source moved by a test only, so expiration is checked without sleeping.
+type fakeClock struct {
+	sync.Mutex
+
+	now time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (o *fakeClock) Now() time.Time {
+	o.Lock()
+	defer o.Unlock()
+
+	return o.now
+}
+
+// Advance moves the clock forward by d.
+func (o *fakeClock) Advance(d time.Duration) {
+	o.Lock()
+	defer o.Unlock()
+
+	o.now = o.now.Add(d)
+}
+
 type mockConfig struct {
 	Exp      time.Duration
+	MaxT     time.Duration
 	TimeS    config.TimeSource
 	PreAlloc int
+	MaxMem   int64
+	Evict    config.EvictionPolicy
+	Compact  float64
+	Seed     [config.HashSeedSize]byte
+	Snapshot string
+	SnapInt  time.Duration
+	Journal  string
+	Fsync    config.FsyncPolicy
 }
 
 func (o *mockConfig) LogLevel() config.LogLevel {
@@ -41,6 +87,10 @@ func (o *mockConfig) Expiration() time.Duration {
 	return o.Exp
 }
 
+func (o *mockConfig) MaxTTL() time.Duration {
+	return o.MaxT
+}
+
 func (o *mockConfig) Maintenance() time.Duration {
 	return 0
 }
@@ -49,6 +99,54 @@ func (o *mockConfig) PreAllocated() int {
 	return o.PreAlloc
 }
 
+func (o *mockConfig) MaxMemory() int64 {
+	return o.MaxMem
+}
+
+func (o *mockConfig) EvictionPolicy() config.EvictionPolicy {
+	return o.Evict
+}
+
+func (o *mockConfig) CompactionRatio() float64 {
+	return o.Compact
+}
+
+func (o *mockConfig) KeysPrefix() string {
+	return ""
+}
+
+func (o *mockConfig) RootKeys() bool {
+	return true
+}
+
+func (o *mockConfig) BinaryPort() int {
+	return 0
+}
+
+func (o *mockConfig) BinaryMaxConns() int {
+	return 0
+}
+
+func (o *mockConfig) RedisPort() int {
+	return 0
+}
+
+func (o *mockConfig) RedisMaxConns() int {
+	return 0
+}
+
+func (o *mockConfig) MemcachedPort() int {
+	return 0
+}
+
+func (o *mockConfig) MemcachedMaxConns() int {
+	return 0
+}
+
+func (o *mockConfig) GRPCPort() int {
+	return 0
+}
+
 func (o *mockConfig) Mode() config.StorageMode {
 	return config.StorageModeMap
 }
@@ -57,18 +155,64 @@ func (o *mockConfig) TimeSource() config.TimeSource {
 	return o.TimeS
 }
 
+func (o *mockConfig) HashSeed() [config.HashSeedSize]byte {
+	return o.Seed
+}
+
+func (o *mockConfig) SnapshotPath() string {
+	return o.Snapshot
+}
+
+func (o *mockConfig) SnapshotInterval() time.Duration {
+	return o.SnapInt
+}
+
+func (o *mockConfig) JournalPath() string {
+	return o.Journal
+}
+
+func (o *mockConfig) JournalFsync() config.FsyncPolicy {
+	return o.Fsync
+}
+
+// newKeyValueBuffer builds a buffer like a data pool places a key and a value.
+func newKeyValueBuffer(counter refCounter, key, value []byte) Buffer {
+	buf := make([]byte, 0, len(key)+len(value))
+
+	return newBuffer(counter, append(append(buf, key...), value...))
+}
+
+// countCalls returns a number of calls of the method.
+func countCalls(m *mock.Mock, method string) int {
+	count := 0
+
+	for _, call := range m.Calls {
+		if call.Method == method {
+			count++
+		}
+	}
+
+	return count
+}
+
 type mockRefCounter struct {
 	mock.Mock
 }
 
-func (m *mockRefCounter) BufferInUse() {
+func (m *mockRefCounter) BufferInUse() bool {
 	m.Called()
+
+	return true
 }
 
 func (m *mockRefCounter) BufferFree() {
 	m.Called()
 }
 
+func (m *mockRefCounter) BufferRelease() {
+	m.Called()
+}
+
 type mockMemoryPool struct {
 	mock.Mock
 }
@@ -79,52 +223,201 @@ func (m *mockMemoryPool) Get() ([]byte, error) {
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (m *mockMemoryPool) Allocate(sz int) ([]byte, error) {
+	args := m.Called(sz)
+
+	return args.Get(0).([]byte), args.Error(1)
+}
+
 func (m *mockMemoryPool) Put(d []byte) {
 	m.Called(d)
 }
 
+func (m *mockMemoryPool) reserve(limit int64) (*bucketMemoryPool, error) {
+	args := m.Called(limit)
+
+	return args.Get(0).(*bucketMemoryPool), args.Error(1)
+}
+
 type mockDataPool struct {
 	mock.Mock
 }
 
-func (m *mockDataPool) Copy(data []byte, expiration time.Time) (Buffer, error) {
-	args := m.Called(data, expiration)
+func (m *mockDataPool) Copy(key, data []byte, expiration time.Time) (Buffer, error) {
+	args := m.Called(key, data, expiration)
 
 	return args.Get(0).(Buffer), args.Error(1)
 }
 
-func (m *mockDataPool) BufferInUse() {
+func (m *mockDataPool) BufferInUse() bool {
 	m.Called()
+
+	return true
 }
 
 func (m *mockDataPool) BufferFree() {
 	m.Called()
 }
 
+func (m *mockDataPool) BufferRelease() {
+	m.Called()
+}
+
 func (m *mockDataPool) Clean(ctx context.Context) error {
 	args := m.Called(ctx)
 
 	return args.Error(0)
 }
 
+func (m *mockDataPool) Stats() Stats {
+	args := m.Called()
+
+	return args.Get(0).(Stats)
+}
+
+func (m *mockDataPool) isCurrent(chunk *preAllocatedBuffer) bool {
+	args := m.Called(chunk)
+
+	return args.Bool(0)
+}
+
 type mockDataDictionary struct {
 	mock.Mock
 }
 
-func (m *mockDataDictionary) Add(key uint64, data []byte, expiration time.Time) error {
-	args := m.Called(key, data, expiration)
+func (m *mockDataDictionary) Add(hash uint64, key, data []byte, expiration time.Time) error {
+	args := m.Called(hash, key, data, expiration)
 
 	return args.Error(0)
 }
 
-func (m *mockDataDictionary) Get(key uint64) (Buffer, error) {
-	args := m.Called(key)
+func (m *mockDataDictionary) AddIf(
+	hash uint64,
+	key, data []byte,
+	flags uint32,
+	expiration time.Time,
+	cond Condition,
+) error {
+	args := m.Called(hash, key, data, flags, expiration, cond)
+
+	return args.Error(0)
+}
+
+func (m *mockDataDictionary) Update(
+	hash uint64,
+	key []byte,
+	expiration time.Time,
+	fn UpdateFunc,
+) (time.Time, uint32, error) {
+	args := m.Called(hash, key, expiration, fn)
+
+	return args.Get(0).(time.Time), args.Get(1).(uint32), args.Error(2)
+}
+
+func (m *mockDataDictionary) Expire(hash uint64, key []byte, expiration time.Time) (time.Time, error) {
+	args := m.Called(hash, key, expiration)
+
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *mockDataDictionary) Get(hash uint64, key []byte) (Buffer, error) {
+	args := m.Called(hash, key)
+
+	return args.Get(0).(Buffer), args.Error(1)
+}
+
+func (m *mockDataDictionary) GetMeta(hash uint64, key []byte) (Buffer, Meta, error) {
+	args := m.Called(hash, key)
+
+	return args.Get(0).(Buffer), args.Get(1).(Meta), args.Error(2)
+}
+
+func (m *mockDataDictionary) GetDelete(hash uint64, key []byte) (Buffer, error) {
+	args := m.Called(hash, key)
 
 	return args.Get(0).(Buffer), args.Error(1)
 }
 
+func (m *mockDataDictionary) GetExpire(hash uint64, key []byte, expiration time.Time) (Buffer, error) {
+	args := m.Called(hash, key, expiration)
+
+	return args.Get(0).(Buffer), args.Error(1)
+}
+
+func (m *mockDataDictionary) Expiration(hash uint64, key []byte) (time.Time, error) {
+	args := m.Called(hash, key)
+
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *mockDataDictionary) Delete(hash uint64, key []byte) error {
+	args := m.Called(hash, key)
+
+	return args.Error(0)
+}
+
+func (m *mockDataDictionary) Scan(prefix []byte, cursor uint64, limit int) []ScanEntry {
+	args := m.Called(prefix, cursor, limit)
+
+	return args.Get(0).([]ScanEntry)
+}
+
+func (m *mockDataDictionary) Range(ctx context.Context, fn RangeFunc) error {
+	args := m.Called(ctx, fn)
+
+	return args.Error(0)
+}
+
+func (m *mockDataDictionary) Victim(policy EvictionPolicy) (Victim, bool) {
+	args := m.Called(policy)
+
+	return args.Get(0).(Victim), args.Bool(1)
+}
+
+func (m *mockDataDictionary) Evict(victim Victim) [][]byte {
+	args := m.Called(victim)
+
+	keys, _ := args.Get(0).([][]byte)
+
+	return keys
+}
+
+func (m *mockDataDictionary) Compact(ctx context.Context, ratio float64) error {
+	args := m.Called(ctx, ratio)
+
+	return args.Error(0)
+}
+
+func (m *mockDataDictionary) Stats() Stats {
+	args := m.Called()
+
+	return args.Get(0).(Stats)
+}
+
 func (m *mockDataDictionary) Clean(ctx context.Context) error {
 	args := m.Called(ctx)
 
 	return args.Error(0)
 }
+
+type mockJournal struct {
+	mock.Mock
+}
+
+func (m *mockJournal) Add(key, body []byte, flags uint32, expiration time.Time) error {
+	args := m.Called(key, body, flags, expiration)
+
+	return args.Error(0)
+}
+
+func (m *mockJournal) Expire(key []byte, expiration time.Time) error {
+	args := m.Called(key, expiration)
+
+	return args.Error(0)
+}
+
+func (m *mockJournal) Delete(key []byte) error {
+	args := m.Called(key)
+
+	return args.Error(0)
+}
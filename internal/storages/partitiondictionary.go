@@ -2,6 +2,7 @@ package storages
 
 import (
 	"context"
+	"math/bits"
 	"time"
 )
 
@@ -38,16 +39,186 @@ func NewPartitionedDictionary(
 	return &dict, nil
 }
 
-func (o *PartitionedDictionary) Add(key uint64, value []byte, expiration time.Time) error {
-	return o.partitions[o.chunkKey(key)].Add(key, value, expiration)
+func (o *PartitionedDictionary) Add(hash uint64, key, value []byte, expiration time.Time) error {
+	partition := o.partitions[o.chunkKey(hash)]
+
+	err := partition.Add(hash, key, value, expiration)
+	if err == ErrOutOfLimit {
+		o.reclaim()
+
+		err = partition.Add(hash, key, value, expiration)
+	}
+
+	return err
+}
+
+func (o *PartitionedDictionary) AddIf(
+	hash uint64,
+	key, value []byte,
+	flags uint32,
+	expiration time.Time,
+	cond Condition,
+) error {
+	partition := o.partitions[o.chunkKey(hash)]
+
+	err := partition.AddIf(hash, key, value, flags, expiration, cond)
+	if err == ErrOutOfLimit {
+		o.reclaim()
+
+		err = partition.AddIf(hash, key, value, flags, expiration, cond)
+	}
+
+	return err
+}
+
+func (o *PartitionedDictionary) Update(
+	hash uint64,
+	key []byte,
+	expiration time.Time,
+	fn UpdateFunc,
+) (time.Time, uint32, error) {
+	partition := o.partitions[o.chunkKey(hash)]
+
+	storedExpiration, flags, err := partition.Update(hash, key, expiration, fn)
+	if err == ErrOutOfLimit {
+		o.reclaim()
+
+		storedExpiration, flags, err = partition.Update(hash, key, expiration, fn)
+	}
+
+	return storedExpiration, flags, err
+}
+
+func (o *PartitionedDictionary) Expire(hash uint64, key []byte, expiration time.Time) (time.Time, error) {
+	return o.partitions[o.chunkKey(hash)].Expire(hash, key, expiration)
+}
+
+func (o *PartitionedDictionary) Get(hash uint64, key []byte) (Buffer, error) {
+	return o.partitions[o.chunkKey(hash)].Get(hash, key)
+}
+
+func (o *PartitionedDictionary) GetMeta(hash uint64, key []byte) (Buffer, Meta, error) {
+	return o.partitions[o.chunkKey(hash)].GetMeta(hash, key)
+}
+
+func (o *PartitionedDictionary) Expiration(hash uint64, key []byte) (time.Time, error) {
+	return o.partitions[o.chunkKey(hash)].Expiration(hash, key)
+}
+
+func (o *PartitionedDictionary) Delete(hash uint64, key []byte) error {
+	return o.partitions[o.chunkKey(hash)].Delete(hash, key)
+}
+
+func (o *PartitionedDictionary) GetDelete(hash uint64, key []byte) (Buffer, error) {
+	return o.partitions[o.chunkKey(hash)].GetDelete(hash, key)
+}
+
+func (o *PartitionedDictionary) GetExpire(hash uint64, key []byte, expiration time.Time) (Buffer, error) {
+	return o.partitions[o.chunkKey(hash)].GetExpire(hash, key, expiration)
+}
+
+// Scan walks partitions in order of their indexes and stops, when a page is full.
+// A position of a key puts bits of its partition above the rest of its hash,
+// so a cursor points to a partition and a hash inside of it.
+func (o *PartitionedDictionary) Scan(prefix []byte, cursor uint64, limit int) []ScanEntry {
+	var (
+		entries []ScanEntry
+		shift   = bits.Len64(o.partitionMask)
+		from    = bits.RotateLeft64(cursor, shift)
+		count   = 0
+	)
+
+	for i := o.chunkKey(from); i < len(o.partitions) && count < limit; i++ {
+		page := o.partitions[i].Scan(prefix, from, limit-count)
+		count += countPositions(page)
+
+		for _, entry := range page {
+			entry.Position = bits.RotateLeft64(entry.Position, -shift)
+			entries = append(entries, entry)
+		}
+
+		from = 0
+	}
+
+	return entries
+}
+
+func (o *PartitionedDictionary) Range(ctx context.Context, fn RangeFunc) error {
+	for _, partition := range o.partitions {
+		err := partition.Range(ctx, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *PartitionedDictionary) Victim(policy EvictionPolicy) (Victim, bool) {
+	var (
+		victim Victim
+		found  bool
+	)
+
+	for _, partition := range o.partitions {
+		candidate, ok := partition.Victim(policy)
+		if ok && (!found || candidate.score < victim.score) {
+			victim = candidate
+			found = true
+		}
+	}
+
+	return victim, found
+}
+
+func (o *PartitionedDictionary) Evict(victim Victim) [][]byte {
+	if victim.owner == nil {
+		return nil
+	}
+
+	return victim.owner.Evict(victim)
+}
+
+func (o *PartitionedDictionary) Compact(ctx context.Context, ratio float64) error {
+	for _, partition := range o.partitions {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		err := partition.Compact(ctx, ratio)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *PartitionedDictionary) Stats() Stats {
+	var stats Stats
+
+	stats.Partitions = make([]Stats, 0, len(o.partitions))
+
+	for _, partition := range o.partitions {
+		partitionStats := partition.Stats()
+
+		stats.add(partitionStats)
+		stats.Partitions = append(stats.Partitions, partitionStats)
+	}
+
+	return stats
 }
 
-func (o *PartitionedDictionary) Get(key uint64) (Buffer, error) {
-	return o.partitions[o.chunkKey(key)].Get(key)
+// reclaim returns chunks of all partitions to a shared memory pool, since a partition out of the limit
+// can't reuse chunks released by others.
+func (o *PartitionedDictionary) reclaim() {
+	_ = o.Clean(context.Background())
 }
 
-func (o *PartitionedDictionary) chunkKey(key uint64) int {
-	return int(key & o.partitionMask)
+func (o *PartitionedDictionary) chunkKey(hash uint64) int {
+	return int(hash & o.partitionMask)
 }
 
 func (o *PartitionedDictionary) Clean(ctx context.Context) error {
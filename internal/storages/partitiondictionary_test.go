@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/7phs/kvs/internal/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,7 +24,7 @@ func TestPartitionDictionary_Add(t *testing.T) {
 		key := uint64(len(dataDicts))
 
 		d := &mockDataDictionary{}
-		d.On("Add", key, toBytes(key), expiration).Return(nil)
+		d.On("Add", key, toBytes(key), toBytes(key), expiration).Return(nil)
 
 		dataDicts = append(dataDicts, d)
 
@@ -34,7 +35,7 @@ func TestPartitionDictionary_Add(t *testing.T) {
 	require.NoError(t, err)
 
 	for i := uint64(0); i < dictCount; i++ {
-		err = dict.Add(i, toBytes(i), expiration)
+		err = dict.Add(i, toBytes(i), toBytes(i), expiration)
 		require.NoError(t, err)
 	}
 
@@ -53,8 +54,8 @@ func TestPartitionDictionary_Get(t *testing.T) {
 		value := toBytes(key)
 
 		d := &mockDataDictionary{}
-		d.On("Add", key, value, expiration).Return(nil)
-		d.On("Get", key).Return(newBuffer(&mockRefCounter{}, value), nil)
+		d.On("Add", key, value, value, expiration).Return(nil)
+		d.On("Get", key, value).Return(newBuffer(&mockRefCounter{}, value), nil)
 
 		dataDicts = append(dataDicts, d)
 
@@ -65,12 +66,12 @@ func TestPartitionDictionary_Get(t *testing.T) {
 	require.NoError(t, err)
 
 	for i := uint64(0); i < dictCount; i++ {
-		err = dict.Add(i, toBytes(i), expiration)
+		err = dict.Add(i, toBytes(i), toBytes(i), expiration)
 		require.NoError(t, err)
 	}
 
 	for i := uint64(0); i < dictCount; i++ {
-		value, err := dict.Get(i)
+		value, err := dict.Get(i, toBytes(i))
 		require.NoError(t, err)
 		assert.Equal(t, toBytes(i), value.Bytes())
 	}
@@ -104,3 +105,130 @@ func TestPartitionDictionary_Clean(t *testing.T) {
 		d.AssertExpectations(t)
 	}
 }
+
+func TestPartitionDictionary_Delete(t *testing.T) {
+	dictCount := uint64(4)
+	dataDicts := make([]*mockDataDictionary, 0, dictCount)
+
+	fabric := func() (DataDictionary, error) {
+		key := uint64(len(dataDicts))
+
+		d := &mockDataDictionary{}
+		d.On("Delete", key, toBytes(key)).Return(nil)
+
+		dataDicts = append(dataDicts, d)
+
+		return d, nil
+	}
+
+	dict, err := NewPartitionedDictionary(dictCount, 0x3, fabric)
+	require.NoError(t, err)
+
+	for i := uint64(0); i < dictCount; i++ {
+		err = dict.Delete(i, toBytes(i))
+		require.NoError(t, err)
+	}
+
+	for _, d := range dataDicts {
+		d.AssertExpectations(t)
+	}
+}
+
+func TestPartitionDictionary_Stats(t *testing.T) {
+	dictCount := uint64(4)
+	dataDicts := make([]*mockDataDictionary, 0, dictCount)
+
+	fabric := func() (DataDictionary, error) {
+		d := &mockDataDictionary{}
+		d.On("Stats").Return(Stats{Collisions: 1})
+
+		dataDicts = append(dataDicts, d)
+
+		return d, nil
+	}
+
+	dict, err := NewPartitionedDictionary(dictCount, 0x3, fabric)
+	require.NoError(t, err)
+
+	stats := dict.Stats()
+	assert.Equal(t, dictCount, stats.Collisions)
+	assert.Len(t, stats.Partitions, int(dictCount))
+
+	for _, d := range dataDicts {
+		d.AssertExpectations(t)
+	}
+}
+
+func TestPartitionDictionary_Scan(t *testing.T) {
+	dictCount := uint64(4)
+	dataDicts := make([]*mockDataDictionary, 0, dictCount)
+	prefix := []byte("/")
+	limit := 3
+
+	fabric := func() (DataDictionary, error) {
+		dataDicts = append(dataDicts, &mockDataDictionary{})
+
+		return dataDicts[len(dataDicts)-1], nil
+	}
+
+	dict, err := NewPartitionedDictionary(dictCount, 0x3, fabric)
+	require.NoError(t, err)
+
+	// a position of a hash is its partition above the rest of the hash
+	dataDicts[0].On("Scan", prefix, uint64(0), limit).Return([]ScanEntry{
+		{Position: 0, Key: toBytes(0)},
+		{Position: 4, Key: toBytes(4)},
+	})
+	dataDicts[1].On("Scan", prefix, uint64(0), 1).Return([]ScanEntry{
+		{Position: 1, Key: toBytes(1)},
+	})
+
+	entries := dict.Scan(prefix, 0, limit)
+	assert.Equal(t, []ScanEntry{
+		{Position: 0, Key: toBytes(0)},
+		{Position: 1, Key: toBytes(4)},
+		{Position: 1 << 62, Key: toBytes(1)},
+	}, entries)
+
+	// a cursor continues the partition, the next partitions are scanned from the beginning
+	dataDicts[1].On("Scan", prefix, uint64(5), limit).Return([]ScanEntry{})
+	dataDicts[2].On("Scan", prefix, uint64(0), limit).Return([]ScanEntry{
+		{Position: 6, Key: toBytes(6)},
+	})
+	dataDicts[3].On("Scan", prefix, uint64(0), 2).Return([]ScanEntry{})
+
+	entries = dict.Scan(prefix, 1<<62+1, limit)
+	assert.Equal(t, []ScanEntry{
+		{Position: 2<<62 + 1, Key: toBytes(6)},
+	}, entries)
+
+	for _, d := range dataDicts {
+		d.AssertExpectations(t)
+	}
+}
+
+func TestPartitionDictionary_ReclaimOtherPartitions(t *testing.T) {
+	timeSource := constantTime(time.Now())
+	expiration := timeSource.Now().Add(time.Hour)
+	value := make([]byte, 100)
+
+	dict, err := NewDataDictionary(config.StorageModePartitionedMap, NewMemoryPool(1024, 40*1024), timeSource)
+	require.NoError(t, err)
+
+	// keys of the first half of partitions are replaced by keys of the second half
+	hash := func(i, partition uint64) uint64 {
+		return i<<4 | partition + i%8
+	}
+
+	stored := uint64(0)
+	for ; dict.Add(hash(stored, 0), toBytes(1000+stored), value, expiration) == nil; stored++ {
+	}
+
+	for i := uint64(0); i < stored; i++ {
+		require.NoError(t, dict.Delete(hash(i, 0), toBytes(1000+i)))
+	}
+
+	for i := uint64(0); i < stored; i++ {
+		require.NoError(t, dict.Add(hash(i, 8), toBytes(1000+i), value, expiration), i)
+	}
+}
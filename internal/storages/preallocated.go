@@ -2,6 +2,7 @@ package storages
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -9,13 +10,20 @@ import (
 
 const (
 	inc = 1
+
+	reclaimedChunk int64 = math.MinInt64 / 2
 )
 
 type preAllocatedBuffer struct {
-	expiration time.Time
+	expiration int64
 	index      int
 	allocated  int64
+	stored     int64
 	buf        []byte
+
+	// hashes of records stored in the chunk let to evict them without scanning a dictionary
+	hashesLock sync.Mutex
+	hashes     []uint64
 }
 
 func newPreAllocatedBuffer(buf []byte) *preAllocatedBuffer {
@@ -25,30 +33,89 @@ func newPreAllocatedBuffer(buf []byte) *preAllocatedBuffer {
 }
 
 func (o *preAllocatedBuffer) allocate(sz int, expiration time.Time) (Buffer, bool) {
-	if o.index+sz >= len(o.buf) {
+	if o.index+sz > len(o.buf) {
 		return Buffer{}, false
 	}
 
 	index := o.index
 	o.index += sz
 
-	if o.expiration.Before(expiration) {
-		o.expiration = expiration
-	}
+	o.extend(expiration)
+
+	atomic.AddInt64(&o.stored, 1)
 
 	return newBuffer(o, o.buf[index:o.index]), true
 }
 
-func (o *preAllocatedBuffer) BufferInUse() {
-	atomic.AddInt64(&o.allocated, 1)
+func (o *preAllocatedBuffer) extend(expiration time.Time) {
+	v := unixNano(expiration)
+
+	for {
+		current := atomic.LoadInt64(&o.expiration)
+		if current >= v || atomic.CompareAndSwapInt64(&o.expiration, current, v) {
+			return
+		}
+	}
+}
+
+func (o *preAllocatedBuffer) track(hash uint64) {
+	o.hashesLock.Lock()
+	o.hashes = append(o.hashes, hash)
+	o.hashesLock.Unlock()
+}
+
+// trackedHashes returns hashes of records stored in the chunk. Some of them may be replaced already.
+func (o *preAllocatedBuffer) trackedHashes() []uint64 {
+	o.hashesLock.Lock()
+	defer o.hashesLock.Unlock()
+
+	return append([]uint64(nil), o.hashes...)
+}
+
+func (o *preAllocatedBuffer) BufferInUse() bool {
+	if atomic.AddInt64(&o.allocated, 1) > 0 {
+		return true
+	}
+
+	atomic.AddInt64(&o.allocated, -1)
+
+	return false
 }
 
 func (o *preAllocatedBuffer) BufferFree() {
 	atomic.AddInt64(&o.allocated, -1)
 }
 
+func (o *preAllocatedBuffer) BufferRelease() {
+	atomic.AddInt64(&o.stored, -1)
+}
+
+func (o *preAllocatedBuffer) isReleased() bool {
+	return atomic.LoadInt64(&o.stored) == 0
+}
+
+// reclaim fails references taken after it, since readers without locks could take them to a reused chunk.
+func (o *preAllocatedBuffer) reclaim() bool {
+	return atomic.CompareAndSwapInt64(&o.allocated, 0, reclaimedChunk)
+}
+
 func (o *preAllocatedBuffer) isExpired(now time.Time) bool {
-	return atomic.LoadInt64(&o.allocated) == 0 && !o.expiration.After(now)
+	if atomic.LoadInt64(&o.allocated) != 0 {
+		return false
+	}
+
+	return atomic.LoadInt64(&o.stored) == 0 || atomic.LoadInt64(&o.expiration) <= now.UnixNano()
+}
+
+func unixNano(expiration time.Time) int64 {
+	switch {
+	case expiration.After(NoExpiration):
+		return math.MaxInt64
+	case expiration.Before(time.Unix(0, 0)):
+		return 0
+	default:
+		return expiration.UnixNano()
+	}
 }
 
 type allocationInUse struct {
@@ -111,7 +178,7 @@ func (o *queueAllocations) pop(now time.Time) (*preAllocatedBuffer, bool) {
 	)
 
 	for cursor != nil {
-		if cursor.IsExpired(now) {
+		if cursor.IsExpired(now) && cursor.allocation.reclaim() {
 			allocation, next := cursor.Reset()
 
 			if prev != nil {
@@ -120,8 +187,8 @@ func (o *queueAllocations) pop(now time.Time) (*preAllocatedBuffer, bool) {
 				o.root = next
 			}
 
-			if o.root == nil {
-				o.last = nil
+			if next == nil {
+				o.last = prev
 			}
 
 			cursor = nil
@@ -150,7 +217,7 @@ func (o *queueAllocations) dump(now time.Time) {
 		fmt.Print(
 			o.root.allocation.index, ";",
 			len(o.root.allocation.buf), ";",
-			o.root.allocation.expiration, ":",
+			time.Unix(0, atomic.LoadInt64(&o.root.allocation.expiration)), ":",
 			atomic.LoadInt64(&o.root.allocation.allocated), ";",
 		)
 	}
@@ -182,3 +182,70 @@ func TestQueue_pop(t *testing.T) {
 	_, ok = queue.pop(time.Now())
 	require.False(t, ok)
 }
+
+func TestPreAllocatedBuffer_allocateReleased(t *testing.T) {
+	allocateSz := 16
+	now := time.Now()
+	expiration := now.Add(1 * time.Minute)
+
+	data := make([]byte, 128)
+	preAllocated := newPreAllocatedBuffer(data)
+
+	buf1, ok := preAllocated.allocate(allocateSz, expiration)
+	require.True(t, ok)
+
+	buf2, ok := preAllocated.allocate(allocateSz, expiration)
+	require.True(t, ok)
+
+	buf1.release()
+
+	assert.False(t, preAllocated.isExpired(now))
+
+	buf2.release()
+
+	assert.True(t, preAllocated.isExpired(now))
+}
+
+func TestPreAllocatedBuffer_reclaim(t *testing.T) {
+	allocateSz := 16
+	expiration := time.Now().Add(1 * time.Minute)
+
+	data := make([]byte, 128)
+	preAllocated := newPreAllocatedBuffer(data)
+
+	buf, ok := preAllocated.allocate(allocateSz, expiration)
+	require.True(t, ok)
+
+	value := buf
+
+	require.True(t, value.inUse())
+	assert.False(t, preAllocated.reclaim())
+
+	value.Free()
+	buf.release()
+
+	require.True(t, preAllocated.reclaim())
+
+	// a reader of a removed record can't take a reference to a reclaimed chunk
+	stale := newBuffer(preAllocated, data[:allocateSz])
+	assert.False(t, stale.inUse())
+	assert.False(t, preAllocated.reclaim())
+}
+
+func TestPreAllocatedBuffer_allocateFarFuture(t *testing.T) {
+	allocateSz := 16
+	now := time.Now()
+
+	data := make([]byte, 128)
+	preAllocated := newPreAllocatedBuffer(data)
+
+	_, ok := preAllocated.allocate(allocateSz, now.Add(time.Second))
+	require.True(t, ok)
+
+	// an expiration after 2262 is out of int64 nanoseconds
+	_, ok = preAllocated.allocate(allocateSz, now.AddDate(300, 0, 0))
+	require.True(t, ok)
+
+	assert.False(t, preAllocated.isExpired(now.Add(time.Minute)))
+	assert.False(t, preAllocated.isExpired(NoExpiration.Add(-time.Nanosecond)))
+}
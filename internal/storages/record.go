@@ -1,29 +1,268 @@
 package storages
 
-import "time"
+import (
+	"bytes"
+	"sync/atomic"
+	"time"
+)
 
+// versions is a counter of versions of records shared by all dictionaries,
+// so a version of a dropped bucket doesn't match a record of a new one.
+var versions uint64
+
+// record is a node of a chain of records with the same hash of keys.
+// A published chain is immutable: changing of the chain builds a new one.
 type record struct {
+	key        []byte
 	value      Buffer
 	expiration time.Time
+	version    uint64
+	flags      uint32
+	accessed   int64
+	hits       uint32
+
+	next *record
 }
 
-func newRecord(value Buffer, expiration time.Time) record {
-	return record{
-		value:      value,
+func newRecord(hash uint64, keyLen int, buf Buffer, expiration, now time.Time) *record {
+	if chunk, ok := buf.refCounter.(*preAllocatedBuffer); ok {
+		chunk.track(hash)
+	}
+
+	return &record{
+		key:        buf.buf[:keyLen],
+		value:      newBuffer(buf.refCounter, buf.buf[keyLen:]),
 		expiration: expiration,
+		version:    atomic.AddUint64(&versions, 1),
+		accessed:   now.UnixNano(),
+	}
+}
+
+func (o *record) get() (Buffer, bool) {
+	if !o.value.inUse() {
+		return Buffer{}, false
+	}
+
+	return o.value, true
+}
+
+func (o *record) acquire(now time.Time) (Buffer, bool) {
+	if o.isExpired(now) {
+		return Buffer{}, false
+	}
+
+	return o.get()
+}
+
+func (o *record) hit(now time.Time) {
+	atomic.StoreInt64(&o.accessed, now.UnixNano())
+	atomic.AddUint32(&o.hits, 1)
+}
+
+func (o *record) meta() Meta {
+	return Meta{
+		Version: o.version,
+		Flags:   o.flags,
+	}
+}
+
+func (o *record) lastAccess() int64 {
+	return atomic.LoadInt64(&o.accessed)
+}
+
+func (o *record) hitCount() uint32 {
+	return atomic.LoadUint32(&o.hits)
+}
+
+func (o *record) isExpired(now time.Time) bool {
+	return !o.expiration.After(now)
+}
+
+func (o *record) release() {
+	o.value.release()
+}
+
+func (o *record) clone() *record {
+	return &record{
+		key:        o.key,
+		value:      o.value,
+		expiration: o.expiration,
+		version:    o.version,
+		flags:      o.flags,
+		accessed:   o.lastAccess(),
+		hits:       o.hitCount(),
+	}
+}
+
+func (o *record) expire(expiration time.Time) *record {
+	if chunk, ok := o.value.refCounter.(*preAllocatedBuffer); ok {
+		chunk.extend(expiration)
+	}
+
+	rec := o.clone()
+	rec.expiration = expiration
+
+	return rec
+}
+
+func (o *record) lookup(key []byte) *record {
+	for cursor := o; cursor != nil; cursor = cursor.next {
+		if bytes.Equal(cursor.key, key) {
+			return cursor
+		}
+	}
+
+	return nil
+}
+
+func (o *record) any(fn func(rec *record) bool) bool {
+	for cursor := o; cursor != nil; cursor = cursor.next {
+		if fn(cursor) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *record) remove(fn func(rec *record) bool) (*record, []*record) {
+	if !o.any(fn) {
+		return o, nil
+	}
+
+	var (
+		head, tail *record
+		removed    []*record
+	)
+
+	for cursor := o; cursor != nil; cursor = cursor.next {
+		if fn(cursor) {
+			removed = append(removed, cursor)
+			continue
+		}
+
+		rec := cursor.clone()
+
+		if head == nil {
+			head = rec
+		} else {
+			tail.next = rec
+		}
+
+		tail = rec
+	}
+
+	return head, removed
+}
+
+func (o *record) replace(rec *record) (*record, []*record) {
+	head, removed := o.remove(func(cursor *record) bool {
+		return bytes.Equal(cursor.key, rec.key)
+	})
+
+	rec.next = head
+
+	return rec, removed
+}
+
+// relocate returns a chain, where the old record is replaced by a moved copy of it.
+// The old record is matched by its key, version and expiration, because removing of other keys clones it.
+// The chain is returned as is, if the record is changed or removed.
+func (o *record) relocate(old, moved *record) (*record, bool) {
+	head, removed := o.remove(func(cursor *record) bool {
+		return cursor.version == old.version &&
+			cursor.expiration.Equal(old.expiration) &&
+			bytes.Equal(cursor.key, old.key)
+	})
+	if len(removed) == 0 {
+		return o, false
+	}
+
+	moved.next = head
+
+	return moved, true
+}
+
+type rangeItem struct {
+	key        []byte
+	value      Buffer
+	flags      uint32
+	expiration time.Time
+}
+
+func acquireChain(items []rangeItem, chain *record, now time.Time) []rangeItem {
+	for cursor := chain; cursor != nil; cursor = cursor.next {
+		buf, ok := cursor.acquire(now)
+		if !ok {
+			continue
+		}
+
+		items = append(items, rangeItem{
+			key:        cursor.key,
+			value:      buf,
+			flags:      cursor.flags,
+			expiration: cursor.expiration,
+		})
 	}
+
+	return items
 }
 
-func (o *record) get() Buffer {
-	o.value.inUse()
+func rangeItems(items []rangeItem, fn RangeFunc) error {
+	var err error
 
-	return o.value
+	for i := range items {
+		if err == nil {
+			err = fn(items[i].key, items[i].value.Bytes(), items[i].flags, items[i].expiration)
+		}
+
+		items[i].value.Free()
+	}
+
+	return err
 }
 
-func (o *record) isExpired() bool {
-	return !o.expiration.After(time.Now())
+func releaseRecords(records []*record) {
+	for _, rec := range records {
+		rec.release()
+	}
 }
 
-func (o *record) reset() {
-	o.value.Reset()
+func updateRecord(
+	pool DataPool,
+	rec *record,
+	key []byte,
+	expiration, now time.Time,
+	fn UpdateFunc,
+) (Buffer, time.Time, uint32, error) {
+	var (
+		value  []byte
+		exists bool
+		flags  uint32
+		buf    Buffer
+	)
+
+	if rec != nil {
+		buf, exists = rec.acquire(now)
+	}
+
+	if exists {
+		expiration = rec.expiration
+		flags = rec.flags
+		value = buf.Bytes()
+	}
+
+	updated, err := fn(value, exists)
+
+	if exists {
+		buf.Free()
+	}
+
+	if err != nil {
+		return Buffer{}, time.Time{}, 0, err
+	}
+
+	stored, err := pool.Copy(key, updated, expiration)
+
+	return stored, expiration, flags, err
 }
@@ -1 +1,117 @@
 package storages
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRecord(key string) *record {
+	now := time.Now()
+
+	return newRecord(0, len(key), newKeyValueBuffer(&mockRefCounter{}, []byte(key), []byte("value-"+key)), now, now)
+}
+
+func TestRecord_split(t *testing.T) {
+	rec := newTestRecord("key")
+
+	assert.Equal(t, []byte("key"), rec.key)
+	assert.Equal(t, []byte("value-key"), rec.value.Bytes())
+}
+
+func TestRecord_replace(t *testing.T) {
+	var chain *record
+
+	chain, replaced := chain.replace(newTestRecord("1"))
+	assert.Len(t, replaced, 0)
+
+	chain, replaced = chain.replace(newTestRecord("2"))
+	assert.Len(t, replaced, 0)
+
+	prev := chain.lookup([]byte("1"))
+	require.NotNil(t, prev)
+
+	chain, replaced = chain.replace(newTestRecord("1"))
+	require.Len(t, replaced, 1)
+	assert.Equal(t, prev, replaced[0])
+
+	count := 0
+
+	for cursor := chain; cursor != nil; cursor = cursor.next {
+		count++
+	}
+
+	assert.Equal(t, 2, count)
+	assert.NotNil(t, chain.lookup([]byte("1")))
+	assert.NotNil(t, chain.lookup([]byte("2")))
+	assert.Nil(t, chain.lookup([]byte("3")))
+}
+
+func TestRecord_remove(t *testing.T) {
+	var chain *record
+
+	for _, key := range []string{"1", "2", "3"} {
+		chain, _ = chain.replace(newTestRecord(key))
+	}
+
+	head, removed := chain.remove(func(rec *record) bool {
+		return bytes.Equal(rec.key, []byte("4"))
+	})
+	assert.Equal(t, chain, head)
+	assert.Len(t, removed, 0)
+
+	head, removed = chain.remove(func(rec *record) bool {
+		return bytes.Equal(rec.key, []byte("2"))
+	})
+	require.Len(t, removed, 1)
+	assert.Nil(t, head.lookup([]byte("2")))
+	assert.NotNil(t, head.lookup([]byte("1")))
+	assert.NotNil(t, head.lookup([]byte("3")))
+	// the published chain is unchanged
+	assert.NotNil(t, chain.lookup([]byte("2")))
+
+	head, removed = head.remove(func(rec *record) bool {
+		return true
+	})
+	assert.Nil(t, head)
+	assert.Len(t, removed, 2)
+}
+
+func TestRecord_relocate(t *testing.T) {
+	var chain *record
+
+	for _, key := range []string{"1", "2", "3"} {
+		chain, _ = chain.replace(newTestRecord(key))
+	}
+
+	old := chain.lookup([]byte("2"))
+	require.NotNil(t, old)
+
+	// removing of another key clones the old record
+	chain, _ = chain.remove(func(rec *record) bool {
+		return bytes.Equal(rec.key, []byte("1"))
+	})
+	require.NotSame(t, old, chain.lookup([]byte("2")))
+
+	moved := old.clone()
+
+	head, ok := chain.relocate(old, moved)
+	require.True(t, ok)
+	assert.Same(t, moved, head.lookup([]byte("2")))
+	assert.NotNil(t, head.lookup([]byte("3")))
+
+	// a changed expiration or a new value isn't replaced by a copy of the old record
+	expired, ok := chain.relocate(old, old.expire(old.expiration.Add(time.Minute)))
+	require.True(t, ok)
+
+	_, ok = expired.relocate(old, old.clone())
+	assert.False(t, ok)
+
+	stored, _ := chain.replace(newTestRecord("2"))
+
+	_, ok = stored.relocate(old, old.clone())
+	assert.False(t, ok)
+}
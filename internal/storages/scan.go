@@ -0,0 +1,133 @@
+package storages
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+	"time"
+)
+
+const (
+	endOfScan uint64 = 0
+)
+
+type ScanEntry struct {
+	Position uint64
+	Key      []byte
+}
+
+type scanCandidate struct {
+	hash  uint64
+	chain *record
+}
+
+type scanPage struct {
+	limit      int
+	candidates []scanCandidate
+}
+
+func newScanPage(limit int) *scanPage {
+	return &scanPage{
+		limit:      limit,
+		candidates: make([]scanCandidate, 0, limit),
+	}
+}
+
+func (o *scanPage) accepts(hash uint64) bool {
+	return len(o.candidates) < o.limit || hash < o.candidates[0].hash
+}
+
+func (o *scanPage) push(hash uint64, chain *record) {
+	candidate := scanCandidate{hash: hash, chain: chain}
+
+	if len(o.candidates) < o.limit {
+		heap.Push(o, candidate)
+		return
+	}
+
+	o.candidates[0] = candidate
+	heap.Fix(o, 0)
+}
+
+func (o *scanPage) sorted() []scanCandidate {
+	sort.Slice(o.candidates, func(i, j int) bool {
+		return o.candidates[i].hash < o.candidates[j].hash
+	})
+
+	return o.candidates
+}
+
+func (o *scanPage) Len() int {
+	return len(o.candidates)
+}
+
+func (o *scanPage) Less(i, j int) bool {
+	return o.candidates[i].hash > o.candidates[j].hash
+}
+
+func (o *scanPage) Swap(i, j int) {
+	o.candidates[i], o.candidates[j] = o.candidates[j], o.candidates[i]
+}
+
+func (o *scanPage) Push(v interface{}) {
+	o.candidates = append(o.candidates, v.(scanCandidate))
+}
+
+func (o *scanPage) Pop() interface{} {
+	last := o.candidates[len(o.candidates)-1]
+	o.candidates = o.candidates[:len(o.candidates)-1]
+
+	return last
+}
+
+func matchScan(rec *record, prefix []byte, now time.Time) bool {
+	buf, ok := rec.acquire(now)
+	if !ok {
+		return false
+	}
+
+	defer buf.Free()
+
+	return bytes.HasPrefix(rec.key, prefix)
+}
+
+func collectScan(entries []ScanEntry, hash uint64, chain *record, prefix []byte, now time.Time) []ScanEntry {
+	for cursor := chain; cursor != nil; cursor = cursor.next {
+		buf, ok := cursor.acquire(now)
+		if !ok {
+			continue
+		}
+
+		if bytes.HasPrefix(cursor.key, prefix) {
+			entries = append(entries, ScanEntry{
+				Position: hash,
+				Key:      append([]byte(nil), cursor.key...),
+			})
+		}
+
+		buf.Free()
+	}
+
+	return entries
+}
+
+func countPositions(entries []ScanEntry) int {
+	count := 0
+
+	for i := range entries {
+		if i == 0 || entries[i].Position != entries[i-1].Position {
+			count++
+		}
+	}
+
+	return count
+}
+
+func nextCursor(entries []ScanEntry, limit int) uint64 {
+	if len(entries) == 0 || countPositions(entries) < limit {
+		return endOfScan
+	}
+
+	// an overflow means the end of scanning
+	return entries[len(entries)-1].Position + 1
+}
@@ -0,0 +1,413 @@
+package storages
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+)
+
+const (
+	snapshotMagic          = "KVSS"
+	snapshotVersion uint16 = 2
+
+	snapshotTagEnd    byte = 0
+	snapshotTagRecord byte = 1
+
+	maxSnapshotItemSize = 1 << 31
+)
+
+var (
+	snapshotTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type Snapshot struct {
+	sync.Mutex
+
+	path       string
+	interval   time.Duration
+	storages   Storages
+	timeSource config.TimeSource
+	last       time.Time
+}
+
+func NewSnapshot(conf config.Config, storages Storages) *Snapshot {
+	return &Snapshot{
+		path:       conf.SnapshotPath(),
+		interval:   conf.SnapshotInterval(),
+		storages:   storages,
+		timeSource: conf.TimeSource(),
+		last:       conf.TimeSource().Now(),
+	}
+}
+
+func (o *Snapshot) ID() string {
+	return "snapshot"
+}
+
+func (o *Snapshot) Clean(ctx context.Context) error {
+	o.Lock()
+	passed := o.timeSource.Now().Sub(o.last)
+	o.Unlock()
+
+	if passed < o.interval {
+		return nil
+	}
+
+	return o.Save(ctx)
+}
+
+func (o *Snapshot) Save(ctx context.Context) error {
+	o.Lock()
+	defer o.Unlock()
+
+	now := o.timeSource.Now()
+	tmpPath := o.path + ".tmp"
+
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	err = o.write(ctx, f, now)
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpPath)
+
+		return err
+	}
+
+	err = os.Rename(tmpPath, o.path)
+	if err != nil {
+		return err
+	}
+
+	o.last = now
+
+	return nil
+}
+
+func (o *Snapshot) write(ctx context.Context, f *os.File, now time.Time) error {
+	w := newSnapshotWriter(f)
+
+	err := o.storages.Range(ctx, func(key, value []byte, flags uint32, expiration time.Time) error {
+		if !expiration.After(now) {
+			return nil
+		}
+
+		return w.write(key, value, flags, expiration)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = w.close()
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+func (o *Snapshot) Load() (int, error) {
+	f, err := os.Open(o.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	_, err = readSnapshot(f, func(_, _ []byte, _ uint32, _ time.Time) error {
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+
+	_, err = readSnapshot(f, func(key, value []byte, flags uint32, expiration time.Time) error {
+		err := o.storages.AddUntilIf(key, value, flags, expiration, Always)
+		switch err {
+		case nil:
+			restored++
+			return nil
+		case ErrInvalidTTL:
+			return nil
+		default:
+			return err
+		}
+	})
+
+	return restored, err
+}
+
+type snapshotWriter struct {
+	buf   *bufio.Writer
+	crc   hash.Hash32
+	w     io.Writer
+	count uint64
+	num   [binary.MaxVarintLen64]byte
+}
+
+func newSnapshotWriter(w io.Writer) *snapshotWriter {
+	writer := &snapshotWriter{
+		buf: bufio.NewWriter(w),
+		crc: crc32.New(snapshotTable),
+	}
+
+	writer.w = io.MultiWriter(writer.buf, writer.crc)
+
+	return writer
+}
+
+func (o *snapshotWriter) write(key, value []byte, flags uint32, expiration time.Time) error {
+	if o.count == 0 {
+		err := o.header()
+		if err != nil {
+			return err
+		}
+	}
+
+	o.count++
+
+	err := o.writeAll([]byte{snapshotTagRecord})
+	if err != nil {
+		return err
+	}
+
+	err = o.writeFixed(uint64(unixNano(expiration)))
+	if err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint32(o.num[:4], flags)
+
+	err = o.writeAll(o.num[:4])
+	if err != nil {
+		return err
+	}
+
+	for _, item := range [][]byte{key, value} {
+		err = o.writeVarint(len(item))
+		if err != nil {
+			return err
+		}
+
+		err = o.writeAll(item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *snapshotWriter) close() error {
+	if o.count == 0 {
+		err := o.header()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := o.writeAll([]byte{snapshotTagEnd})
+	if err != nil {
+		return err
+	}
+
+	err = o.writeFixed(o.count)
+	if err != nil {
+		return err
+	}
+
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, o.crc.Sum32())
+
+	_, err = o.buf.Write(sum)
+	if err != nil {
+		return err
+	}
+
+	return o.buf.Flush()
+}
+
+func (o *snapshotWriter) header() error {
+	version := make([]byte, 2)
+	binary.BigEndian.PutUint16(version, snapshotVersion)
+
+	return o.writeAll([]byte(snapshotMagic), version)
+}
+
+func (o *snapshotWriter) writeAll(data ...[]byte) error {
+	for _, d := range data {
+		_, err := o.w.Write(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *snapshotWriter) writeFixed(v uint64) error {
+	binary.BigEndian.PutUint64(o.num[:8], v)
+
+	return o.writeAll(o.num[:8])
+}
+
+func (o *snapshotWriter) writeVarint(v int) error {
+	n := binary.PutUvarint(o.num[:], uint64(v))
+
+	return o.writeAll(o.num[:n])
+}
+
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (o *snapshotReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	_, _ = o.crc.Write(p[:n])
+
+	return n, err
+}
+
+func (o *snapshotReader) ReadByte() (byte, error) {
+	b, err := o.r.ReadByte()
+	if err == nil {
+		_, _ = o.crc.Write([]byte{b})
+	}
+
+	return b, err
+}
+
+func readSnapshot(r io.Reader, fn RangeFunc) (uint64, error) {
+	reader := &snapshotReader{
+		r:   bufio.NewReader(r),
+		crc: crc32.New(snapshotTable),
+	}
+
+	header := make([]byte, len(snapshotMagic)+2)
+
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return 0, ErrSnapshotCorrupted
+	}
+
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return 0, ErrSnapshotCorrupted
+	}
+
+	if binary.BigEndian.Uint16(header[len(snapshotMagic):]) != snapshotVersion {
+		return 0, ErrSnapshotVersion
+	}
+
+	var (
+		count uint64
+		fixed = make([]byte, 8)
+		key   []byte
+		value []byte
+	)
+
+	for {
+		tag, err := reader.ReadByte()
+		if err != nil {
+			return count, ErrSnapshotCorrupted
+		}
+
+		if tag == snapshotTagEnd {
+			break
+		}
+
+		if tag != snapshotTagRecord {
+			return count, ErrSnapshotCorrupted
+		}
+
+		_, err = io.ReadFull(reader, fixed)
+		if err != nil {
+			return count, ErrSnapshotCorrupted
+		}
+
+		expiration := time.Unix(0, int64(binary.BigEndian.Uint64(fixed)))
+
+		_, err = io.ReadFull(reader, fixed[:4])
+		if err != nil {
+			return count, ErrSnapshotCorrupted
+		}
+
+		flags := binary.BigEndian.Uint32(fixed[:4])
+
+		key, err = readSnapshotItem(reader, key)
+		if err != nil {
+			return count, err
+		}
+
+		value, err = readSnapshotItem(reader, value)
+		if err != nil {
+			return count, err
+		}
+
+		err = fn(key, value, flags, expiration)
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	_, err = io.ReadFull(reader, fixed)
+	if err != nil || binary.BigEndian.Uint64(fixed) != count {
+		return count, ErrSnapshotCorrupted
+	}
+
+	expectedSum := reader.crc.Sum32()
+
+	_, err = io.ReadFull(reader.r, fixed[:4])
+	if err != nil || binary.BigEndian.Uint32(fixed[:4]) != expectedSum {
+		return count, ErrSnapshotCorrupted
+	}
+
+	return count, nil
+}
+
+func readSnapshotItem(reader *snapshotReader, buf []byte) ([]byte, error) {
+	sz, err := binary.ReadUvarint(reader)
+	if err != nil || sz > maxSnapshotItemSize {
+		return nil, ErrSnapshotCorrupted
+	}
+
+	if uint64(cap(buf)) < sz {
+		buf = make([]byte, sz)
+	}
+
+	buf = buf[:sz]
+
+	_, err = io.ReadFull(reader, buf)
+	if err != nil {
+		return nil, ErrSnapshotCorrupted
+	}
+
+	return buf, nil
+}
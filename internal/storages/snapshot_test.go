@@ -0,0 +1,128 @@
+package storages
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorages(t *testing.T, conf *mockConfig) Storages {
+	pool, err := NewDataPool(NewMemoryPool(1024, 0), conf.TimeS)
+	require.NoError(t, err)
+
+	storage, err := NewInMemStorages(conf, NewMapDictionary(pool, conf.TimeS), nil)
+	require.NoError(t, err)
+
+	return storage
+}
+
+func TestSnapshot_SaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvs-snapshot")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	now := time.Now()
+	conf := &mockConfig{
+		Exp:      1 * time.Minute,
+		TimeS:    constantTime(now),
+		Snapshot: filepath.Join(dir, "kvs.snapshot"),
+	}
+
+	values := map[string]string{
+		"/1": "value-1",
+		"/2": "value-2",
+		"/3": "",
+	}
+
+	storage := newTestStorages(t, conf)
+
+	for key, value := range values {
+		err = storage.Add([]byte(key), []byte(value), 0)
+		require.NoError(t, err)
+	}
+
+	err = storage.AddUntilIf([]byte("/4"), []byte("value-4"), 42, now.Add(time.Minute), Always)
+	require.NoError(t, err)
+
+	values["/4"] = "value-4"
+
+	err = NewSnapshot(conf, storage).Save(ctx)
+	require.NoError(t, err)
+
+	restoredStorage := newTestStorages(t, conf)
+
+	restored, err := NewSnapshot(conf, restoredStorage).Load()
+	require.NoError(t, err)
+	assert.Equal(t, len(values), restored)
+
+	for key, value := range values {
+		buf, err := restoredStorage.Get([]byte(key))
+		require.NoError(t, err)
+		assert.Equal(t, value, string(buf.Bytes()))
+
+		buf.Free()
+	}
+
+	buf, meta, err := restoredStorage.GetMeta([]byte("/4"))
+	require.NoError(t, err)
+	buf.Free()
+	assert.Equal(t, uint32(42), meta.Flags)
+}
+
+func TestSnapshot_LoadNotExist(t *testing.T) {
+	conf := &mockConfig{
+		TimeS:    constantTime(time.Now()),
+		Snapshot: filepath.Join(os.TempDir(), "kvs-not-exist.snapshot"),
+	}
+
+	restored, err := NewSnapshot(conf, &InMemStorages{}).Load()
+	require.NoError(t, err)
+	assert.Equal(t, 0, restored)
+}
+
+func TestSnapshot_LoadCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvs-snapshot")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	conf := &mockConfig{
+		Exp:      1 * time.Minute,
+		TimeS:    constantTime(time.Now()),
+		Snapshot: filepath.Join(dir, "kvs.snapshot"),
+	}
+
+	storage := newTestStorages(t, conf)
+
+	err = storage.Add([]byte("/1"), []byte("value-1"), 0)
+	require.NoError(t, err)
+
+	err = NewSnapshot(conf, storage).Save(ctx)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(conf.Snapshot)
+	require.NoError(t, err)
+
+	data[len(data)/2] ^= 0xFF
+
+	err = ioutil.WriteFile(conf.Snapshot, data, 0600)
+	require.NoError(t, err)
+
+	restoredStorage := newTestStorages(t, conf)
+
+	_, err = NewSnapshot(conf, restoredStorage).Load()
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrSnapshotCorrupted.Error())
+
+	_, err = restoredStorage.Get([]byte("/1"))
+	assert.EqualError(t, err, ErrKeyNotFound.Error())
+}
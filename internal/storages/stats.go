@@ -0,0 +1,81 @@
+package storages
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type Stats struct {
+	Collisions     uint64
+	Evictions      uint64
+	EvictedChunks  uint64
+	Relocations    uint64
+	Keys           uint64
+	Volatile       uint64
+	LiveBytes      uint64
+	Chunks         uint64
+	QueuedChunks   uint64
+	AllocatedBytes uint64
+	Hits           uint64
+	Misses         uint64
+	Expired        uint64
+	Partitions     []Stats
+}
+
+func (o *Stats) add(stats Stats) {
+	o.Collisions += stats.Collisions
+	o.Evictions += stats.Evictions
+	o.EvictedChunks += stats.EvictedChunks
+	o.Relocations += stats.Relocations
+	o.Keys += stats.Keys
+	o.Volatile += stats.Volatile
+	o.LiveBytes += stats.LiveBytes
+	o.Chunks += stats.Chunks
+	o.QueuedChunks += stats.QueuedChunks
+	o.AllocatedBytes += stats.AllocatedBytes
+	o.Hits += stats.Hits
+	o.Misses += stats.Misses
+	o.Expired += stats.Expired
+}
+
+type liveCounter struct {
+	keys     int64
+	volatile int64
+	bytes    int64
+}
+
+func (o *liveCounter) store(rec *record) {
+	atomic.AddInt64(&o.keys, 1)
+	atomic.AddInt64(&o.volatile, volatile(rec.expiration))
+	atomic.AddInt64(&o.bytes, int64(len(rec.key)+len(rec.value.buf)))
+}
+
+func (o *liveCounter) expire(previous, expiration time.Time) {
+	atomic.AddInt64(&o.volatile, volatile(expiration)-volatile(previous))
+}
+
+func (o *liveCounter) release(records []*record) {
+	for _, rec := range records {
+		atomic.AddInt64(&o.keys, -1)
+		atomic.AddInt64(&o.volatile, -volatile(rec.expiration))
+		atomic.AddInt64(&o.bytes, -int64(len(rec.key)+len(rec.value.buf)))
+	}
+
+	releaseRecords(records)
+}
+
+func (o *liveCounter) add(stats Stats) Stats {
+	stats.Keys = uint64(atomic.LoadInt64(&o.keys))
+	stats.Volatile = uint64(atomic.LoadInt64(&o.volatile))
+	stats.LiveBytes = uint64(atomic.LoadInt64(&o.bytes))
+
+	return stats
+}
+
+func volatile(expiration time.Time) int64 {
+	if expiration.Equal(NoExpiration) {
+		return 0
+	}
+
+	return 1
+}
@@ -2,6 +2,8 @@ package storages
 
 import (
 	"context"
+	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/7phs/kvs/internal/config"
@@ -10,36 +12,111 @@ import (
 
 var (
 	_ Storages = (*InMemStorages)(nil)
+
+	NoExpiration = time.Unix(0, math.MaxInt64)
 )
 
+const Persistent time.Duration = -1
+
+type RangeFunc func(key, value []byte, flags uint32, expiration time.Time) error
+
+type Meta struct {
+	Version uint64
+	Flags   uint32
+}
+
+type UpdateFunc func(value []byte, exists bool) ([]byte, error)
+
 type Storages interface {
 	ID() string
-	Add(key, body []byte) error
+	Add(key, body []byte, ttl time.Duration) error
+	AddUntil(key, body []byte, expiration time.Time) error
+	AddIf(key, body []byte, ttl time.Duration, cond Condition) error
+	AddUntilIf(key, body []byte, flags uint32, expiration time.Time, cond Condition) error
+	Update(key []byte, ttl time.Duration, fn UpdateFunc) error
+	Incr(key []byte, delta int64, ttl time.Duration) (int64, error)
 	Get(key []byte) (Buffer, error)
+	GetMeta(key []byte) (Buffer, Meta, error)
+	GetDelete(key []byte) (Buffer, error)
+	GetExpire(key []byte, ttl time.Duration) (Buffer, error)
+	GetExpireAt(key []byte, expiration time.Time) (Buffer, error)
+	GetBatch(keys [][]byte) ([]Buffer, []error)
+	AddBatch(items []BatchItem) []error
+	AddAll(items []BatchItem) error
+	TTL(key []byte) (time.Duration, error)
+	Expire(key []byte, ttl time.Duration) error
+	ExpireAt(key []byte, expiration time.Time) error
+	Persist(key []byte) (bool, error)
+	Delete(key []byte) error
+	DeleteIf(key []byte, cond Condition) error
+	Flush(ctx context.Context) (int, error)
+	Watch(ctx context.Context, prefix []byte, fn WatchFunc) error
+	Scan(prefix []byte, cursor uint64, limit int) ([][]byte, uint64)
+	Range(ctx context.Context, fn RangeFunc) error
+	Stats() Stats
 	Clean(ctx context.Context) error
 }
 
 type DataDictionary interface {
-	Add(key uint64, data []byte, expiration time.Time) error
-	Get(key uint64) (Buffer, error)
+	Add(hash uint64, key, data []byte, expiration time.Time) error
+	AddIf(hash uint64, key, data []byte, flags uint32, expiration time.Time, cond Condition) error
+	Update(hash uint64, key []byte, expiration time.Time, fn UpdateFunc) (time.Time, uint32, error)
+	Get(hash uint64, key []byte) (Buffer, error)
+	GetMeta(hash uint64, key []byte) (Buffer, Meta, error)
+	Expire(hash uint64, key []byte, expiration time.Time) (time.Time, error)
+	Expiration(hash uint64, key []byte) (time.Time, error)
+	Delete(hash uint64, key []byte) error
+	GetDelete(hash uint64, key []byte) (Buffer, error)
+	GetExpire(hash uint64, key []byte, expiration time.Time) (Buffer, error)
+	Scan(prefix []byte, cursor uint64, limit int) []ScanEntry
+	Range(ctx context.Context, fn RangeFunc) error
+	Victim(policy EvictionPolicy) (Victim, bool)
+	Evict(victim Victim) [][]byte
+	Compact(ctx context.Context, ratio float64) error
+	Stats() Stats
 	Clean(ctx context.Context) error
 }
 
+const maxEvictionAttempts = 3
+
+// InMemStorages changes a key in a dictionary, records the change to a journal and notifies watchers of it
+// under a lock of the key, so the journal and watchers receive changes of the key in the order they are applied.
 type InMemStorages struct {
 	dataDict DataDictionary
+	journal  Journal
+	watchers *watchers
+	eviction EvictionPolicy
+	locks    keyLocks
 
-	nonce      [32]byte
+	hits        uint64
+	misses      uint64
+	expiredHits uint64
+
+	nonce      [config.HashSeedSize]byte
 	expired    time.Duration
+	maxTTL     time.Duration
 	timeSource config.TimeSource
 }
 
 func NewInMemStorages(
 	config config.Config,
 	dataDict DataDictionary,
+	journal Journal,
 ) (Storages, error) {
+	if journal == nil {
+		journal = nopJournal{}
+	}
+
+	watchers := newWatchers()
+
 	return &InMemStorages{
 		dataDict:   dataDict,
+		journal:    journal,
+		watchers:   watchers,
+		eviction:   NewEvictionPolicy(config.EvictionPolicy()),
+		nonce:      config.HashSeed(),
 		expired:    config.Expiration(),
+		maxTTL:     config.MaxTTL(),
 		timeSource: config.TimeSource(),
 	}, nil
 }
@@ -48,14 +125,652 @@ func (o *InMemStorages) ID() string {
 	return "in-memory-storages"
 }
 
-func (o *InMemStorages) Add(key, body []byte) error {
-	expiration := o.timeSource.Now().Add(o.expired)
+func (o *InMemStorages) Add(key, body []byte, ttl time.Duration) error {
+	return o.AddIf(key, body, ttl, Always)
+}
+
+func (o *InMemStorages) AddIf(key, body []byte, ttl time.Duration, cond Condition) error {
+	expiration, err := o.expiration(o.timeSource.Now(), ttl)
+	if err != nil {
+		return err
+	}
+
+	return o.addUntil(key, body, 0, expiration, cond)
+}
+
+func (o *InMemStorages) AddUntil(key, body []byte, expiration time.Time) error {
+	return o.addUntil(key, body, 0, expiration, Always)
+}
+
+func (o *InMemStorages) AddUntilIf(key, body []byte, flags uint32, expiration time.Time, cond Condition) error {
+	return o.addUntil(key, body, flags, expiration, cond)
+}
+
+func (o *InMemStorages) addUntil(key, body []byte, flags uint32, expiration time.Time, cond Condition) error {
+	now := o.timeSource.Now()
+
+	if !expiration.After(now) {
+		return ErrInvalidTTL
+	}
+
+	expiration = o.limitExpiration(now, expiration)
+
+	hash := o.hash(key)
+
+	o.locks.lock(hash)
+
+	evicted, err := o.add(hash, key, body, flags, expiration, cond)
+	if err == nil {
+		o.watchers.notify(Event{Type: EventSet, Key: key, Value: body, Expiration: expiration})
+
+		err = o.journal.Add(key, body, flags, expiration)
+	}
+
+	o.locks.unlock(hash)
+
+	if journalErr := o.journalEvicted(evicted); err == nil {
+		err = journalErr
+	}
+
+	return err
+}
+
+func (o *InMemStorages) add(
+	hash uint64,
+	key, body []byte,
+	flags uint32,
+	expiration time.Time,
+	cond Condition,
+) ([][]byte, error) {
+	store := func() error {
+		if cond == Always && flags == 0 {
+			return o.dataDict.Add(hash, key, body, expiration)
+		}
+
+		return o.dataDict.AddIf(hash, key, body, flags, expiration, cond)
+	}
+
+	var (
+		err     = store()
+		evicted [][]byte
+	)
+
+	for attempt := 0; err == ErrOutOfLimit && o.eviction != nil && attempt < maxEvictionAttempts; attempt++ {
+		victim, ok := o.dataDict.Victim(o.eviction)
+		if !ok {
+			break
+		}
+
+		evicted = append(evicted, o.dataDict.Evict(victim)...)
+
+		err = store()
+	}
+
+	return evicted, err
+}
+
+// journalEvicted records deleting of evicted keys. It is called without a lock of a changed key,
+// since evicted keys may belong to other locks. A key stored again after the eviction is journaled
+// by its writer, so only absent keys are deleted under their own locks.
+func (o *InMemStorages) journalEvicted(keys [][]byte) error {
+	for _, key := range keys {
+		hash := o.hash(key)
+
+		o.locks.lock(hash)
+
+		_, err := o.dataDict.Expiration(hash, key)
+		if err != nil {
+			err = o.journal.Delete(key)
+		}
+
+		o.locks.unlock(hash)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *InMemStorages) Update(key []byte, ttl time.Duration, fn UpdateFunc) error {
+	expiration, err := o.expiration(o.timeSource.Now(), ttl)
+	if err != nil {
+		return err
+	}
+
+	var (
+		hash   = o.hash(key)
+		stored []byte
+		update = func(value []byte, exists bool) ([]byte, error) {
+			var err error
+
+			stored, err = fn(value, exists)
+
+			return stored, err
+		}
+	)
+
+	o.locks.lock(hash)
+
+	var (
+		storedExpiration time.Time
+		storedFlags      uint32
+		evicted          [][]byte
+	)
+
+	storedExpiration, storedFlags, err = o.dataDict.Update(hash, key, expiration, update)
+
+	for attempt := 0; err == ErrOutOfLimit && o.eviction != nil && attempt < maxEvictionAttempts; attempt++ {
+		victim, ok := o.dataDict.Victim(o.eviction)
+		if !ok {
+			break
+		}
+
+		evicted = append(evicted, o.dataDict.Evict(victim)...)
+
+		storedExpiration, storedFlags, err = o.dataDict.Update(hash, key, expiration, update)
+	}
+
+	if err == nil {
+		o.watchers.notify(Event{Type: EventSet, Key: key, Value: stored, Expiration: storedExpiration})
+
+		err = o.journal.Add(key, stored, storedFlags, storedExpiration)
+	}
+
+	o.locks.unlock(hash)
+
+	if journalErr := o.journalEvicted(evicted); err == nil {
+		err = journalErr
+	}
+
+	return err
+}
+
+func (o *InMemStorages) expiration(now time.Time, ttl time.Duration) (time.Time, error) {
+	switch {
+	case ttl == Persistent:
+		return o.limitExpiration(now, NoExpiration), nil
+	case ttl < 0:
+		return time.Time{}, ErrInvalidTTL
+	case ttl == 0:
+		ttl = o.expired
+	}
 
-	return o.dataDict.Add(o.hash(key), body, expiration)
+	return o.limitExpiration(now, now.Add(ttl)), nil
+}
+
+func (o *InMemStorages) limitExpiration(now, expiration time.Time) time.Time {
+	if o.maxTTL > 0 {
+		if limit := now.Add(o.maxTTL); expiration.After(limit) {
+			expiration = limit
+		}
+	}
+
+	if expiration.After(NoExpiration) {
+		return NoExpiration
+	}
+
+	return expiration
 }
 
 func (o *InMemStorages) Get(key []byte) (Buffer, error) {
-	return o.dataDict.Get(o.hash(key))
+	buf, err := o.dataDict.Get(o.hash(key), key)
+
+	o.countGet(err)
+
+	return buf, err
+}
+
+func (o *InMemStorages) GetMeta(key []byte) (Buffer, Meta, error) {
+	buf, meta, err := o.dataDict.GetMeta(o.hash(key), key)
+
+	o.countGet(err)
+
+	return buf, meta, err
+}
+
+func (o *InMemStorages) countGet(err error) {
+	switch err {
+	case nil:
+		atomic.AddUint64(&o.hits, 1)
+	case ErrKeyNotFound:
+		atomic.AddUint64(&o.misses, 1)
+	case ErrKeyExpired:
+		atomic.AddUint64(&o.expiredHits, 1)
+	}
+}
+
+func (o *InMemStorages) GetBatch(keys [][]byte) ([]Buffer, []error) {
+	var (
+		hashes = make([]uint64, len(keys))
+		values = make([]Buffer, len(keys))
+		errs   = make([]error, len(keys))
+	)
+
+	for i, key := range keys {
+		hashes[i] = o.hash(key)
+	}
+
+	if dict, ok := o.dataDict.(batchDictionary); ok {
+		dict.GetBatch(hashes, keys, values, errs)
+	} else {
+		for i, key := range keys {
+			values[i], errs[i] = o.dataDict.Get(hashes[i], key)
+		}
+	}
+
+	for _, err := range errs {
+		o.countGet(err)
+	}
+
+	return values, errs
+}
+
+func (o *InMemStorages) AddBatch(items []BatchItem) []error {
+	var (
+		now     = o.timeSource.Now()
+		errs    = make([]error, len(items))
+		records = make([]batchRecord, 0, len(items))
+		indexes = make([]int, 0, len(items))
+	)
+
+	for i, item := range items {
+		expiration, err := o.expiration(now, item.TTL)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		records = append(records, batchRecord{
+			hash:       o.hash(item.Key),
+			key:        item.Key,
+			value:      item.Value,
+			expiration: expiration,
+		})
+		indexes = append(indexes, i)
+	}
+
+	hashes := make([]uint64, len(records))
+	for i := range records {
+		hashes[i] = records[i].hash
+	}
+
+	stripes := o.locks.lockAll(hashes)
+
+	recordErrs := make([]error, len(records))
+
+	if dict, ok := o.dataDict.(batchDictionary); ok {
+		dict.AddBatch(records, recordErrs)
+	} else {
+		for i := range records {
+			recordErrs[i] = o.dataDict.Add(records[i].hash, records[i].key, records[i].value, records[i].expiration)
+		}
+	}
+
+	var evicted [][]byte
+
+	for i, rec := range records {
+		err := recordErrs[i]
+		if err == ErrOutOfLimit {
+			var keys [][]byte
+
+			keys, err = o.add(rec.hash, rec.key, rec.value, 0, rec.expiration, Always)
+			evicted = append(evicted, keys...)
+		}
+
+		if err == nil {
+			o.watchers.notify(Event{Type: EventSet, Key: rec.key, Value: rec.value, Expiration: rec.expiration})
+
+			err = o.journal.Add(rec.key, rec.value, 0, rec.expiration)
+		}
+
+		errs[indexes[i]] = err
+	}
+
+	o.locks.unlockAll(stripes)
+
+	if err := o.journalEvicted(evicted); err != nil {
+		for _, i := range indexes {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+	}
+
+	return errs
+}
+
+// AddAll stores all items or none of them. Items stored before a failed one get their previous values back,
+// and a previous value which doesn't fit into memory any more is deleted as an evicted one.
+func (o *InMemStorages) AddAll(items []BatchItem) error {
+	var (
+		now     = o.timeSource.Now()
+		records = make([]batchRecord, len(items))
+		hashes  = make([]uint64, len(items))
+	)
+
+	for i, item := range items {
+		expiration, err := o.expiration(now, item.TTL)
+		if err != nil {
+			return err
+		}
+
+		hashes[i] = o.hash(item.Key)
+		records[i] = batchRecord{hash: hashes[i], key: item.Key, value: item.Value, expiration: expiration}
+	}
+
+	stripes := o.locks.lockAll(hashes)
+
+	var (
+		previous = make([]previousRecord, 0, len(records))
+		evicted  [][]byte
+		err      error
+	)
+
+	for _, rec := range records {
+		previous = append(previous, o.previous(rec.hash, rec.key))
+
+		var keys [][]byte
+
+		keys, err = o.add(rec.hash, rec.key, rec.value, 0, rec.expiration, Always)
+		evicted = append(evicted, keys...)
+
+		if err != nil {
+			evicted = append(evicted, o.restore(records[:len(previous)], previous)...)
+			break
+		}
+	}
+
+	for i := 0; err == nil && i < len(records); i++ {
+		rec := &records[i]
+
+		o.watchers.notify(Event{Type: EventSet, Key: rec.key, Value: rec.value, Expiration: rec.expiration})
+
+		err = o.journal.Add(rec.key, rec.value, 0, rec.expiration)
+	}
+
+	o.locks.unlockAll(stripes)
+
+	if journalErr := o.journalEvicted(evicted); err == nil {
+		err = journalErr
+	}
+
+	return err
+}
+
+func (o *InMemStorages) previous(hash uint64, key []byte) previousRecord {
+	buf, meta, err := o.dataDict.GetMeta(hash, key)
+	if err != nil {
+		return previousRecord{}
+	}
+
+	defer buf.Free()
+
+	expiration, err := o.dataDict.Expiration(hash, key)
+	if err != nil {
+		return previousRecord{}
+	}
+
+	return previousRecord{
+		value:      append([]byte(nil), buf.Bytes()...),
+		flags:      meta.Flags,
+		expiration: expiration,
+		exists:     true,
+	}
+}
+
+// restore returns previous values in the reverse order, so a key repeated in a batch gets the value it had before.
+// Keys which previous values don't fit into memory are deleted and returned to be journaled as evicted.
+func (o *InMemStorages) restore(records []batchRecord, previous []previousRecord) [][]byte {
+	var deleted [][]byte
+
+	for i := len(records) - 1; i >= 0; i-- {
+		rec, prev := &records[i], &previous[i]
+
+		if prev.exists &&
+			o.dataDict.AddIf(rec.hash, rec.key, prev.value, prev.flags, prev.expiration, Always) == nil {
+			continue
+		}
+
+		_ = o.dataDict.Delete(rec.hash, rec.key)
+
+		if prev.exists {
+			deleted = append(deleted, rec.key)
+		}
+	}
+
+	return deleted
+}
+
+func (o *InMemStorages) TTL(key []byte) (time.Duration, error) {
+	expiration, err := o.dataDict.Expiration(o.hash(key), key)
+	if err != nil {
+		return 0, err
+	}
+
+	if expiration.Equal(NoExpiration) {
+		return Persistent, nil
+	}
+
+	ttl := expiration.Sub(o.timeSource.Now())
+	if ttl <= 0 {
+		return 0, ErrKeyExpired
+	}
+
+	return ttl, nil
+}
+
+func (o *InMemStorages) Expire(key []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+
+	return o.ExpireAt(key, o.timeSource.Now().Add(ttl))
+}
+
+func (o *InMemStorages) ExpireAt(key []byte, expiration time.Time) error {
+	now := o.timeSource.Now()
+
+	if !expiration.After(now) {
+		return ErrInvalidTTL
+	}
+
+	_, err := o.expire(key, o.limitExpiration(now, expiration))
+
+	return err
+}
+
+func (o *InMemStorages) Persist(key []byte) (bool, error) {
+	previous, err := o.expire(key, o.limitExpiration(o.timeSource.Now(), NoExpiration))
+	if err != nil {
+		return false, err
+	}
+
+	return !previous.Equal(NoExpiration), nil
+}
+
+func (o *InMemStorages) expire(key []byte, expiration time.Time) (time.Time, error) {
+	hash := o.hash(key)
+
+	o.locks.lock(hash)
+	defer o.locks.unlock(hash)
+
+	previous, err := o.dataDict.Expire(hash, key, expiration)
+	if err != nil {
+		return previous, err
+	}
+
+	o.watchers.notify(Event{Type: EventExpire, Key: key, Expiration: expiration})
+
+	return previous, o.journal.Expire(key, expiration)
+}
+
+func (o *InMemStorages) Delete(key []byte) error {
+	return o.DeleteIf(key, Always)
+}
+
+// DeleteIf checks a condition and deletes a key under the same lock of the key.
+func (o *InMemStorages) DeleteIf(key []byte, cond Condition) error {
+	hash := o.hash(key)
+
+	o.locks.lock(hash)
+	defer o.locks.unlock(hash)
+
+	if !o.met(hash, key, cond) {
+		return ErrConditionFailed
+	}
+
+	err := o.dataDict.Delete(hash, key)
+
+	switch err {
+	case nil, ErrKeyExpired:
+		o.watchers.notify(Event{Type: EventDelete, Key: key})
+
+		if journalErr := o.journal.Delete(key); journalErr != nil {
+			return journalErr
+		}
+	}
+
+	return err
+}
+
+func (o *InMemStorages) met(hash uint64, key []byte, cond Condition) bool {
+	if cond.kind == conditionAlways {
+		return true
+	}
+
+	buf, meta, err := o.dataDict.GetMeta(hash, key)
+	if err == nil {
+		buf.Free()
+	}
+
+	switch cond.kind {
+	case conditionIfNotExists:
+		return err != nil
+	case conditionIfExists:
+		return err == nil
+	case conditionIfVersion:
+		return err == nil && meta.Version == cond.version
+	default:
+		return true
+	}
+}
+
+func (o *InMemStorages) GetDelete(key []byte) (Buffer, error) {
+	hash := o.hash(key)
+
+	o.locks.lock(hash)
+	defer o.locks.unlock(hash)
+
+	buf, err := o.dataDict.GetDelete(hash, key)
+
+	o.countGet(err)
+
+	switch err {
+	case nil, ErrKeyExpired:
+		o.watchers.notify(Event{Type: EventDelete, Key: key})
+
+		if journalErr := o.journal.Delete(key); journalErr != nil {
+			if err == nil {
+				buf.Free()
+			}
+
+			return Buffer{}, journalErr
+		}
+	}
+
+	return buf, err
+}
+
+func (o *InMemStorages) GetExpire(key []byte, ttl time.Duration) (Buffer, error) {
+	if ttl <= 0 {
+		return Buffer{}, ErrInvalidTTL
+	}
+
+	return o.GetExpireAt(key, o.timeSource.Now().Add(ttl))
+}
+
+func (o *InMemStorages) GetExpireAt(key []byte, expiration time.Time) (Buffer, error) {
+	now := o.timeSource.Now()
+
+	if !expiration.After(now) {
+		return Buffer{}, ErrInvalidTTL
+	}
+
+	expiration = o.limitExpiration(now, expiration)
+	hash := o.hash(key)
+
+	o.locks.lock(hash)
+	defer o.locks.unlock(hash)
+
+	buf, err := o.dataDict.GetExpire(hash, key, expiration)
+
+	o.countGet(err)
+
+	if err != nil {
+		return Buffer{}, err
+	}
+
+	o.watchers.notify(Event{Type: EventExpire, Key: key, Expiration: expiration})
+
+	if err := o.journal.Expire(key, expiration); err != nil {
+		buf.Free()
+
+		return Buffer{}, err
+	}
+
+	return buf, nil
+}
+
+func (o *InMemStorages) Flush(ctx context.Context) (int, error) {
+	deleted := 0
+
+	err := o.dataDict.Range(ctx, func(key, _ []byte, _ uint32, _ time.Time) error {
+		switch err := o.Delete(key); err {
+		case nil:
+			deleted++
+			return nil
+		case ErrKeyNotFound, ErrKeyExpired:
+			return nil
+		default:
+			return err
+		}
+	})
+
+	return deleted, err
+}
+
+func (o *InMemStorages) Watch(ctx context.Context, prefix []byte, fn WatchFunc) error {
+	return o.watchers.watch(ctx, prefix, fn)
+}
+
+func (o *InMemStorages) Scan(prefix []byte, cursor uint64, limit int) ([][]byte, uint64) {
+	if limit <= 0 {
+		return nil, endOfScan
+	}
+
+	entries := o.dataDict.Scan(prefix, cursor, limit)
+	keys := make([][]byte, 0, len(entries))
+
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+
+	return keys, nextCursor(entries, limit)
+}
+
+func (o *InMemStorages) Range(ctx context.Context, fn RangeFunc) error {
+	return o.dataDict.Range(ctx, fn)
+}
+
+func (o *InMemStorages) Stats() Stats {
+	stats := o.dataDict.Stats()
+	stats.Hits = atomic.LoadUint64(&o.hits)
+	stats.Misses = atomic.LoadUint64(&o.misses)
+	stats.Expired = atomic.LoadUint64(&o.expiredHits)
+
+	return stats
 }
 
 func (o *InMemStorages) Clean(ctx context.Context) error {
@@ -2,10 +2,14 @@ package storages
 
 import (
 	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,17 +27,111 @@ func TestInMemStorages_Add(t *testing.T) {
 	value := []byte("test-value")
 
 	mockDict := &mockDataDictionary{}
-	mockDict.On("Add", hashedKey, value, expiration).Return(nil)
+	mockDict.On("Add", hashedKey, key, value, expiration).Return(nil)
 
-	storage, err := NewInMemStorages(conf, mockDict)
+	storage, err := NewInMemStorages(conf, mockDict, nil)
 	require.NoError(t, err)
 
-	err = storage.Add(key, value)
+	err = storage.Add(key, value, 0)
 	require.NoError(t, err)
 
 	mockDict.AssertExpectations(t)
 }
 
+func TestInMemStorages_AddTTL(t *testing.T) {
+	now := time.Now()
+
+	conf := &mockConfig{
+		Exp:   1 * time.Second,
+		TimeS: constantTime(now),
+	}
+
+	key := []byte("0123456789")
+	hashedKey := uint64(0x8208d73d0fcfef26)
+	ttl := 1 * time.Minute
+	value := []byte("test-value")
+
+	mockDict := &mockDataDictionary{}
+	mockDict.On("Add", hashedKey, key, value, now.Add(ttl)).Return(nil)
+	mockDict.On("Add", hashedKey, key, value, NoExpiration).Return(nil)
+
+	storage, err := NewInMemStorages(conf, mockDict, nil)
+	require.NoError(t, err)
+
+	err = storage.Add(key, value, ttl)
+	require.NoError(t, err)
+
+	err = storage.Add(key, value, Persistent)
+	require.NoError(t, err)
+
+	err = storage.Add(key, value, -ttl)
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrInvalidTTL.Error())
+
+	mockDict.AssertExpectations(t)
+}
+
+func TestInMemStorages_AddUntil(t *testing.T) {
+	now := time.Now()
+
+	conf := &mockConfig{
+		Exp:   1 * time.Second,
+		MaxT:  1 * time.Hour,
+		TimeS: constantTime(now),
+	}
+
+	key := []byte("0123456789")
+	hashedKey := uint64(0x8208d73d0fcfef26)
+	expiration := now.Add(1 * time.Minute)
+	value := []byte("test-value")
+
+	mockDict := &mockDataDictionary{}
+	mockDict.On("Add", hashedKey, key, value, expiration).Return(nil)
+	mockDict.On("Add", hashedKey, key, value, now.Add(conf.MaxT)).Return(nil)
+
+	storage, err := NewInMemStorages(conf, mockDict, nil)
+	require.NoError(t, err)
+
+	err = storage.AddUntil(key, value, expiration)
+	require.NoError(t, err)
+
+	// capped by max TTL
+	err = storage.AddUntil(key, value, now.Add(24*time.Hour))
+	require.NoError(t, err)
+
+	err = storage.AddUntil(key, value, now)
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrInvalidTTL.Error())
+
+	err = storage.AddUntil(key, value, now.Add(-time.Hour))
+	assert.Equal(t, ErrInvalidTTL, err)
+
+	mockDict.AssertExpectations(t)
+}
+
+func TestInMemStorages_AddUntilNoMaxTTL(t *testing.T) {
+	var (
+		now      = time.Now()
+		conf     = &mockConfig{Exp: time.Second, TimeS: constantTime(now)}
+		key      = []byte("key")
+		value    = []byte("value")
+		mockDict = &mockDataDictionary{}
+	)
+
+	mockDict.On("Add", mock.Anything, key, value, NoExpiration).Return(nil).Once()
+
+	storage, err := NewInMemStorages(conf, mockDict, nil)
+	require.NoError(t, err)
+
+	// an expiration out of int64 nanoseconds is clamped
+	require.NoError(t, storage.AddUntil(key, value, now.AddDate(300, 0, 0)))
+
+	assert.Equal(t, ErrInvalidTTL, storage.AddUntil(key, value, now.Add(-time.Nanosecond)))
+	assert.Equal(t, ErrInvalidTTL, storage.AddUntil(key, value, time.Unix(0, 0)))
+
+	mockDict.AssertExpectations(t)
+}
+
 func TestInMemStorages_Get(t *testing.T) {
 	now := time.Now()
 
@@ -47,9 +145,9 @@ func TestInMemStorages_Get(t *testing.T) {
 	value := []byte("test-value")
 
 	mockDict := &mockDataDictionary{}
-	mockDict.On("Get", hashedKey).Return(newBuffer(&mockRefCounter{}, value), nil)
+	mockDict.On("Get", hashedKey, key).Return(newBuffer(&mockRefCounter{}, value), nil)
 
-	storage, err := NewInMemStorages(conf, mockDict)
+	storage, err := NewInMemStorages(conf, mockDict, nil)
 	require.NoError(t, err)
 
 	storedValue, err := storage.Get(key)
@@ -72,9 +170,9 @@ func TestInMemStorages_GetNotFound(t *testing.T) {
 	hashedKey := uint64(0x8208d73d0fcfef26)
 
 	mockDict := &mockDataDictionary{}
-	mockDict.On("Get", hashedKey).Return(newBuffer(nil, nil), ErrKeyNotFound)
+	mockDict.On("Get", hashedKey, key).Return(newBuffer(nil, nil), ErrKeyNotFound)
 
-	storage, err := NewInMemStorages(conf, mockDict)
+	storage, err := NewInMemStorages(conf, mockDict, nil)
 	require.NoError(t, err)
 
 	_, err = storage.Get(key)
@@ -84,6 +182,144 @@ func TestInMemStorages_GetNotFound(t *testing.T) {
 	mockDict.AssertExpectations(t)
 }
 
+func TestInMemStorages_Delete(t *testing.T) {
+	conf := &mockConfig{}
+
+	key := []byte("0123456789")
+	hashedKey := uint64(0x8208d73d0fcfef26)
+
+	mockDict := &mockDataDictionary{}
+	mockDict.On("Delete", hashedKey, key).Return(ErrKeyNotFound)
+
+	storage, err := NewInMemStorages(conf, mockDict, nil)
+	require.NoError(t, err)
+
+	err = storage.Delete(key)
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrKeyNotFound.Error())
+
+	mockDict.AssertExpectations(t)
+}
+
+func TestInMemStorages_Scan(t *testing.T) {
+	conf := &mockConfig{}
+	prefix := []byte("/")
+	limit := 2
+
+	mockDict := &mockDataDictionary{}
+	mockDict.On("Scan", prefix, uint64(0), limit).Return([]ScanEntry{
+		{Position: 1, Key: []byte("/1")},
+		{Position: 1, Key: []byte("/2")},
+		{Position: 5, Key: []byte("/3")},
+	})
+	mockDict.On("Scan", prefix, uint64(6), limit).Return([]ScanEntry{
+		{Position: 7, Key: []byte("/4")},
+	})
+
+	storage, err := NewInMemStorages(conf, mockDict, nil)
+	require.NoError(t, err)
+
+	keys, cursor := storage.Scan(prefix, 0, limit)
+	assert.Equal(t, [][]byte{[]byte("/1"), []byte("/2"), []byte("/3")}, keys)
+	assert.Equal(t, uint64(6), cursor)
+
+	keys, cursor = storage.Scan(prefix, cursor, limit)
+	assert.Equal(t, [][]byte{[]byte("/4")}, keys)
+	assert.Equal(t, endOfScan, cursor)
+
+	mockDict.AssertExpectations(t)
+}
+
+func TestInMemStorages_ScanPages(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{Exp: time.Minute, TimeS: systemTime{}})
+
+			expected := make(map[string]bool)
+
+			for i := 0; i < 500; i++ {
+				key := "/" + strconv.Itoa(i)
+				require.NoError(t, storage.Add([]byte(key), []byte("v"), 0))
+				expected[key] = true
+			}
+
+			var (
+				scanned = make(map[string]bool)
+				cursor  = uint64(0)
+				keys    [][]byte
+			)
+
+			for {
+				keys, cursor = storage.Scan([]byte("/"), cursor, 7)
+				assert.LessOrEqual(t, len(keys), 7)
+
+				for _, key := range keys {
+					assert.False(t, scanned[string(key)], string(key))
+					scanned[string(key)] = true
+				}
+
+				if cursor == endOfScan {
+					break
+				}
+			}
+
+			assert.Equal(t, expected, scanned)
+		})
+	}
+}
+
+func TestInMemStorages_HashSeed(t *testing.T) {
+	key := []byte("0123456789")
+	hashedKey := uint64(0x8208d73d0fcfef26)
+
+	storage, err := NewInMemStorages(&mockConfig{}, &mockDataDictionary{}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, hashedKey, storage.(*InMemStorages).hash(key))
+
+	conf := &mockConfig{}
+	conf.Seed[0] = 1
+
+	seededStorage, err := NewInMemStorages(conf, &mockDataDictionary{}, nil)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, hashedKey, seededStorage.(*InMemStorages).hash(key))
+}
+
+func TestInMemStorages_Journal(t *testing.T) {
+	now := time.Now()
+
+	conf := &mockConfig{
+		Exp:   1 * time.Second,
+		TimeS: constantTime(now),
+	}
+
+	key := []byte("0123456789")
+	hashedKey := uint64(0x8208d73d0fcfef26)
+	expiration := now.Add(conf.Exp)
+	value := []byte("test-value")
+
+	mockDict := &mockDataDictionary{}
+	mockDict.On("Add", hashedKey, key, value, expiration).Return(nil)
+	mockDict.On("Delete", hashedKey, key).Return(nil)
+
+	journal := &mockJournal{}
+	journal.On("Add", key, value, uint32(0), expiration).Return(nil)
+	journal.On("Delete", key).Return(nil)
+
+	storage, err := NewInMemStorages(conf, mockDict, journal)
+	require.NoError(t, err)
+
+	err = storage.Add(key, value, 0)
+	require.NoError(t, err)
+
+	err = storage.Delete(key)
+	require.NoError(t, err)
+
+	mockDict.AssertExpectations(t)
+	journal.AssertExpectations(t)
+}
+
 func TestInMemStorages_Clean(t *testing.T) {
 	conf := &mockConfig{}
 
@@ -92,7 +328,7 @@ func TestInMemStorages_Clean(t *testing.T) {
 	mockDict := &mockDataDictionary{}
 	mockDict.On("Clean", ctx).Return(nil)
 
-	storage, err := NewInMemStorages(conf, mockDict)
+	storage, err := NewInMemStorages(conf, mockDict, nil)
 	require.NoError(t, err)
 
 	err = storage.Clean(ctx)
@@ -109,7 +345,7 @@ func TestInMemStorages_CleanFailed(t *testing.T) {
 	mockDict := &mockDataDictionary{}
 	mockDict.On("Clean", ctx).Return(ErrOutOfLimit)
 
-	storage, err := NewInMemStorages(conf, mockDict)
+	storage, err := NewInMemStorages(conf, mockDict, nil)
 	require.NoError(t, err)
 
 	err = storage.Clean(ctx)
@@ -118,3 +354,353 @@ func TestInMemStorages_CleanFailed(t *testing.T) {
 
 	mockDict.AssertExpectations(t)
 }
+
+func TestInMemStorages_TTL(t *testing.T) {
+	now := time.Now()
+
+	conf := &mockConfig{
+		Exp:   1 * time.Second,
+		TimeS: constantTime(now),
+	}
+
+	key := []byte("0123456789")
+	hashedKey := uint64(0x8208d73d0fcfef26)
+	unknownKey := []byte("unknown")
+
+	mockDict := &mockDataDictionary{}
+	mockDict.On("Expiration", hashedKey, key).Return(now.Add(time.Minute), nil)
+	mockDict.On("Expiration", mock.Anything, unknownKey).Return(time.Time{}, ErrKeyNotFound)
+
+	storage, err := NewInMemStorages(conf, mockDict, nil)
+	require.NoError(t, err)
+
+	ttl, err := storage.TTL(key)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	_, err = storage.TTL(unknownKey)
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	mockDict.AssertExpectations(t)
+}
+
+func TestInMemStorages_Update(t *testing.T) {
+	incr := func(value []byte, exists bool) ([]byte, error) {
+		if !exists {
+			return []byte("1"), nil
+		}
+
+		n, err := strconv.Atoi(string(value))
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(strconv.Itoa(n + 1)), nil
+	}
+
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("counter")
+
+			var wg sync.WaitGroup
+
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < 100; j++ {
+						assert.NoError(t, storage.Update(key, 0, incr))
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			assertValue(t, storage, key, []byte("800"))
+
+			// an existing key keeps its expiration
+			require.NoError(t, storage.Expire(key, time.Hour))
+			require.NoError(t, storage.Update(key, time.Second, incr))
+
+			ttl, err := storage.TTL(key)
+			require.NoError(t, err)
+			assert.Equal(t, time.Hour, ttl)
+
+			// a failed update keeps a value
+			require.NoError(t, storage.Add(key, []byte("text"), 0))
+			assert.Error(t, storage.Update(key, 0, incr))
+			assertValue(t, storage, key, []byte("text"))
+
+			assert.Equal(t, uint64(1), storage.Stats().Keys)
+		})
+	}
+}
+
+func TestInMemStorages_Flush(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			for i := 0; i < 10; i++ {
+				require.NoError(t, storage.Add([]byte(strconv.Itoa(i)), []byte("value"), 0))
+			}
+
+			deleted, err := storage.Flush(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, 10, deleted)
+			assert.Equal(t, uint64(0), storage.Stats().Keys)
+
+			_, err = storage.Get([]byte("1"))
+			assert.Equal(t, ErrKeyNotFound, err)
+		})
+	}
+}
+
+func TestInMemStorages_GetDelete(t *testing.T) {
+	const workers = 8
+
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("token")
+
+			_, err := storage.GetDelete(key)
+			assert.Equal(t, ErrKeyNotFound, err)
+
+			for round := 0; round < 20; round++ {
+				require.NoError(t, storage.Add(key, []byte("value"), 0))
+
+				var (
+					wg       sync.WaitGroup
+					consumed uint32
+				)
+
+				for i := 0; i < workers; i++ {
+					wg.Add(1)
+
+					go func() {
+						defer wg.Done()
+
+						buf, err := storage.GetDelete(key)
+						if err == ErrKeyNotFound {
+							return
+						}
+
+						if assert.NoError(t, err) {
+							assert.Equal(t, []byte("value"), buf.Bytes())
+							buf.Free()
+							atomic.AddUint32(&consumed, 1)
+						}
+					}()
+				}
+
+				wg.Wait()
+
+				assert.Equal(t, uint32(1), consumed)
+			}
+
+			assert.Equal(t, uint64(0), storage.Stats().Keys)
+		})
+	}
+}
+
+func TestInMemStorages_GetExpire(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(time.Now()),
+			})
+
+			key := []byte("session")
+
+			_, err := storage.GetExpire(key, time.Hour)
+			assert.Equal(t, ErrKeyNotFound, err)
+
+			require.NoError(t, storage.Add(key, []byte("value"), 0))
+
+			_, err = storage.GetExpire(key, 0)
+			assert.Equal(t, ErrInvalidTTL, err)
+
+			buf, err := storage.GetExpire(key, time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("value"), buf.Bytes())
+			buf.Free()
+
+			ttl, err := storage.TTL(key)
+			require.NoError(t, err)
+			assert.Equal(t, time.Hour, ttl)
+
+			buf, err = storage.GetExpireAt(key, NoExpiration)
+			require.NoError(t, err)
+			buf.Free()
+
+			ttl, err = storage.TTL(key)
+			require.NoError(t, err)
+			assert.Equal(t, Persistent, ttl)
+
+			stats := storage.Stats()
+			assert.Equal(t, uint64(2), stats.Hits)
+			assert.Equal(t, uint64(1), stats.Misses)
+			assert.Equal(t, uint64(1), stats.Keys)
+		})
+	}
+}
+
+func TestInMemStorages_GetDeleteJournal(t *testing.T) {
+	var (
+		key        = []byte("key")
+		conf       = &mockConfig{Exp: time.Minute, TimeS: constantTime(time.Now())}
+		mockDict   = &mockDataDictionary{}
+		journal    = &mockJournal{}
+		journalErr = Error("journal")
+	)
+
+	storage, err := NewInMemStorages(conf, mockDict, journal)
+	require.NoError(t, err)
+
+	hash := storage.(*InMemStorages).hash(key)
+
+	mockDict.On("GetDelete", hash, key).Return(Buffer{}, ErrKeyExpired).Once()
+	journal.On("Delete", key).Return(journalErr).Once()
+
+	_, err = storage.GetDelete(key)
+	assert.Equal(t, journalErr, err)
+
+	mockDict.AssertExpectations(t)
+	journal.AssertExpectations(t)
+}
+
+func TestInMemStorages_ExpireByTimeSource(t *testing.T) {
+	ctx := context.Background()
+
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			clock := newFakeClock(time.Now())
+			storage := newModeStorages(t, mode, &mockConfig{Exp: time.Minute, TimeS: clock})
+
+			require.NoError(t, storage.Add([]byte("short"), []byte("v1"), time.Second))
+			require.NoError(t, storage.Add([]byte("long"), []byte("v2"), time.Hour))
+
+			clock.Advance(time.Second - time.Nanosecond)
+			assertValue(t, storage, []byte("short"), []byte("v1"))
+
+			clock.Advance(time.Nanosecond)
+			_, err := storage.Get([]byte("short"))
+			assert.Equal(t, ErrKeyExpired, err)
+			assertValue(t, storage, []byte("long"), []byte("v2"))
+
+			ttl, err := storage.TTL([]byte("long"))
+			require.NoError(t, err)
+			assert.Equal(t, time.Hour-time.Second, ttl)
+
+			keys, _ := storage.Scan(nil, 0, 10)
+			assert.Equal(t, [][]byte{[]byte("long")}, keys)
+
+			// the expired key is kept until cleaning
+			assert.Equal(t, uint64(2), storage.Stats().Keys)
+
+			require.NoError(t, storage.Clean(ctx))
+			assert.Equal(t, uint64(1), storage.Stats().Keys)
+
+			_, err = storage.Get([]byte("short"))
+			assert.Equal(t, ErrKeyNotFound, err)
+
+			clock.Advance(time.Hour)
+			_, err = storage.Get([]byte("long"))
+			assert.Equal(t, ErrKeyExpired, err)
+		})
+	}
+}
+
+func TestInMemStorages_AddFarFuture(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		long  = []byte("long-lived-value-0123456789")
+		value = make([]byte, 30)
+	)
+
+	for name, fabric := range map[string]dictionaryFabric{
+		"map":     NewMapDictionary,
+		"syncmap": NewSyncMapDictionary,
+	} {
+		clock := newFakeClock(time.Now())
+
+		// two records of 32 bytes per chunk and no more than two chunks
+		pool, err := NewDataPool(NewMemoryPool(64, 2*64), clock)
+		require.NoError(t, err)
+
+		storage, err := NewInMemStorages(&mockConfig{Exp: time.Minute, TimeS: clock}, fabric(pool, clock), nil)
+		require.NoError(t, err)
+
+		require.NoError(t, storage.AddUntil([]byte("long"), long, clock.Now().AddDate(300, 0, 0)), name)
+		require.NoError(t, storage.Add([]byte("s0"), value, time.Second), name)
+		require.NoError(t, storage.Add([]byte("s1"), value, time.Second), name)
+
+		clock.Advance(time.Minute)
+		require.NoError(t, storage.Clean(ctx), name)
+
+		// a chunk of the long-lived key isn't reused
+		for i := 0; i < 4; i++ {
+			_ = storage.Add([]byte("n"+strconv.Itoa(i)), value, 0)
+		}
+
+		buf, err := storage.Get([]byte("long"))
+		require.NoError(t, err, name)
+		assert.Equal(t, long, buf.Bytes(), name)
+		buf.Free()
+
+		ttl, err := storage.TTL([]byte("long"))
+		require.NoError(t, err, name)
+		assert.Equal(t, Persistent, ttl, name)
+	}
+}
+
+func TestInMemStorages_Flags(t *testing.T) {
+	for _, mode := range testModes {
+		t.Run(string(mode), func(t *testing.T) {
+			now := time.Now()
+			storage := newModeStorages(t, mode, &mockConfig{
+				Exp:   time.Minute,
+				TimeS: constantTime(now),
+			})
+			key := []byte("key")
+
+			assertFlags := func(expected uint32) {
+				buf, meta, err := storage.GetMeta(key)
+				require.NoError(t, err)
+				buf.Free()
+				assert.Equal(t, expected, meta.Flags)
+			}
+
+			require.NoError(t, storage.AddUntilIf(key, []byte("1"), 42, now.Add(time.Minute), Always))
+			assertFlags(42)
+
+			// an expiration and an update keep flags
+			require.NoError(t, storage.Expire(key, time.Hour))
+			assertFlags(42)
+
+			_, err := storage.Incr(key, 1, 0)
+			require.NoError(t, err)
+			assertFlags(42)
+
+			// a new value is stored with its own flags
+			require.NoError(t, storage.Add(key, []byte("v"), 0))
+			assertFlags(0)
+		})
+	}
+}
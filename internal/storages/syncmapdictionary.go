@@ -1,52 +1,455 @@
 package storages
 
 import (
+	"bytes"
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/7phs/kvs/internal/config"
 	"golang.org/x/sync/errgroup"
 )
 
+// SyncMapDictionary reads chains of records without locks. Changing of chains is serialized by writeLock.
 type SyncMapDictionary struct {
 	sync.Map
 
-	pool DataPool
+	writeLock  sync.Mutex
+	pool       DataPool
+	timeSource config.TimeSource
+	collisions uint64
+
+	evictions     uint64
+	evictedChunks uint64
+	relocations   uint64
+
+	live liveCounter
 }
 
-func NewSyncMapDictionary(pool DataPool) DataDictionary {
+func NewSyncMapDictionary(pool DataPool, timeSource config.TimeSource) DataDictionary {
 	return &SyncMapDictionary{
-		pool: pool,
+		pool:       pool,
+		timeSource: timeSource,
 	}
 }
 
-func (o *SyncMapDictionary) Add(key uint64, data []byte, expiration time.Time) error {
-	buf, err := o.pool.Copy(data, expiration)
+func (o *SyncMapDictionary) Add(hash uint64, key, data []byte, expiration time.Time) error {
+	return o.AddIf(hash, key, data, 0, expiration, Always)
+}
+
+func (o *SyncMapDictionary) AddIf(
+	hash uint64,
+	key, data []byte,
+	flags uint32,
+	expiration time.Time,
+	cond Condition,
+) error {
+	buf, err := o.pool.Copy(key, data, expiration)
 	if err != nil {
 		return err
 	}
 
-	o.Store(key, newRecord(buf, expiration))
+	now := o.timeSource.Now()
+
+	o.writeLock.Lock()
+	chain := o.chain(hash)
+
+	if !cond.met(chain.lookup(key), now) {
+		o.writeLock.Unlock()
+		buf.release()
+
+		return ErrConditionFailed
+	}
+
+	rec := newRecord(hash, len(key), buf, expiration, now)
+	rec.flags = flags
+	o.live.store(rec)
+
+	head, replaced := chain.replace(rec)
+	o.Store(hash, head)
+	o.writeLock.Unlock()
+
+	if chain != nil && len(replaced) == 0 {
+		atomic.AddUint64(&o.collisions, 1)
+	}
+
+	o.live.release(replaced)
 
 	return nil
 }
 
-func (o *SyncMapDictionary) Get(key uint64) (Buffer, error) {
-	v, ok := o.Load(key)
-	if !ok {
+func (o *SyncMapDictionary) Update(
+	hash uint64,
+	key []byte,
+	expiration time.Time,
+	fn UpdateFunc,
+) (time.Time, uint32, error) {
+	now := o.timeSource.Now()
+
+	o.writeLock.Lock()
+	chain := o.chain(hash)
+
+	buf, expiration, flags, err := updateRecord(o.pool, chain.lookup(key), key, expiration, now, fn)
+	if err != nil {
+		o.writeLock.Unlock()
+
+		return time.Time{}, 0, err
+	}
+
+	rec := newRecord(hash, len(key), buf, expiration, now)
+	rec.flags = flags
+	o.live.store(rec)
+
+	head, replaced := chain.replace(rec)
+	o.Store(hash, head)
+	o.writeLock.Unlock()
+
+	if chain != nil && len(replaced) == 0 {
+		atomic.AddUint64(&o.collisions, 1)
+	}
+
+	o.live.release(replaced)
+
+	return expiration, flags, nil
+}
+
+func (o *SyncMapDictionary) Get(hash uint64, key []byte) (Buffer, error) {
+	buf, _, err := o.GetMeta(hash, key)
+
+	return buf, err
+}
+
+func (o *SyncMapDictionary) GetMeta(hash uint64, key []byte) (Buffer, Meta, error) {
+	for {
+		rec := o.chain(hash).lookup(key)
+		if rec == nil {
+			return Buffer{}, Meta{}, ErrKeyNotFound
+		}
+
+		now := o.timeSource.Now()
+
+		if rec.isExpired(now) {
+			return Buffer{}, Meta{}, ErrKeyExpired
+		}
+
+		// a chunk of a replaced record could be reclaimed before the reference, so the key is looked up again
+		if buf, ok := rec.get(); ok {
+			rec.hit(now)
+
+			return buf, rec.meta(), nil
+		}
+	}
+}
+
+func (o *SyncMapDictionary) Expiration(hash uint64, key []byte) (time.Time, error) {
+	rec := o.chain(hash).lookup(key)
+	if rec == nil {
+		return time.Time{}, ErrKeyNotFound
+	}
+
+	if rec.isExpired(o.timeSource.Now()) {
+		return time.Time{}, ErrKeyExpired
+	}
+
+	return rec.expiration, nil
+}
+
+func (o *SyncMapDictionary) Expire(hash uint64, key []byte, expiration time.Time) (time.Time, error) {
+	o.writeLock.Lock()
+	defer o.writeLock.Unlock()
+
+	chain := o.chain(hash)
+
+	rec := chain.lookup(key)
+	if rec == nil {
+		return time.Time{}, ErrKeyNotFound
+	}
+
+	if rec.isExpired(o.timeSource.Now()) {
+		return time.Time{}, ErrKeyExpired
+	}
+
+	extended := rec.expire(expiration)
+
+	head, _ := chain.relocate(rec, extended)
+	o.Store(hash, head)
+	o.live.expire(rec.expiration, expiration)
+
+	return rec.expiration, nil
+}
+
+func (o *SyncMapDictionary) Delete(hash uint64, key []byte) error {
+	o.writeLock.Lock()
+	head, removed := o.chain(hash).remove(func(rec *record) bool {
+		return bytes.Equal(rec.key, key)
+	})
+	o.set(hash, head)
+	o.writeLock.Unlock()
+
+	if len(removed) == 0 {
+		return ErrKeyNotFound
+	}
+
+	expired := removed[0].isExpired(o.timeSource.Now())
+
+	o.live.release(removed)
+
+	if expired {
+		return ErrKeyExpired
+	}
+
+	return nil
+}
+
+func (o *SyncMapDictionary) GetDelete(hash uint64, key []byte) (Buffer, error) {
+	var (
+		buf      Buffer
+		acquired bool
+		now      = o.timeSource.Now()
+	)
+
+	o.writeLock.Lock()
+	head, removed := o.chain(hash).remove(func(rec *record) bool {
+		return bytes.Equal(rec.key, key)
+	})
+	o.set(hash, head)
+
+	if len(removed) > 0 {
+		buf, acquired = removed[0].acquire(now)
+	}
+	o.writeLock.Unlock()
+
+	if len(removed) == 0 {
 		return Buffer{}, ErrKeyNotFound
 	}
 
-	rec, ok := v.(record)
-	if !ok {
+	o.live.release(removed)
+
+	if !acquired {
+		return Buffer{}, ErrKeyExpired
+	}
+
+	removed[0].hit(now)
+
+	return buf, nil
+}
+
+func (o *SyncMapDictionary) GetExpire(hash uint64, key []byte, expiration time.Time) (Buffer, error) {
+	o.writeLock.Lock()
+	defer o.writeLock.Unlock()
+
+	now := o.timeSource.Now()
+
+	chain := o.chain(hash)
+
+	rec := chain.lookup(key)
+	if rec == nil {
 		return Buffer{}, ErrKeyNotFound
 	}
 
-	if rec.isExpired() {
+	buf, ok := rec.acquire(now)
+	if !ok {
 		return Buffer{}, ErrKeyExpired
 	}
 
-	return rec.get(), nil
+	extended := rec.expire(expiration)
+	extended.hit(now)
+
+	head, _ := chain.relocate(rec, extended)
+	o.Store(hash, head)
+	o.live.expire(rec.expiration, expiration)
+
+	return buf, nil
+}
+
+func (o *SyncMapDictionary) Scan(prefix []byte, cursor uint64, limit int) []ScanEntry {
+	var (
+		page    = newScanPage(limit)
+		entries []ScanEntry
+		now     = o.timeSource.Now()
+		match   = func(rec *record) bool {
+			return matchScan(rec, prefix, now)
+		}
+	)
+
+	o.Map.Range(func(key, value interface{}) bool {
+		hash, ok := key.(uint64)
+		if !ok || hash < cursor || !page.accepts(hash) {
+			return true
+		}
+
+		chain, ok := value.(*record)
+		if ok && chain.any(match) {
+			page.push(hash, chain)
+		}
+
+		return true
+	})
+
+	for _, candidate := range page.sorted() {
+		entries = collectScan(entries, candidate.hash, candidate.chain, prefix, now)
+	}
+
+	return entries
+}
+
+func (o *SyncMapDictionary) Range(ctx context.Context, fn RangeFunc) error {
+	var (
+		items []rangeItem
+		now   = o.timeSource.Now()
+		err   error
+	)
+
+	o.Map.Range(func(_, value interface{}) bool {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return false
+		default:
+		}
+
+		chain, ok := value.(*record)
+		if !ok {
+			return true
+		}
+
+		items = acquireChain(items[:0], chain, now)
+		err = rangeItems(items, fn)
+
+		return err == nil
+	})
+
+	return err
+}
+
+func (o *SyncMapDictionary) Victim(policy EvictionPolicy) (Victim, bool) {
+	scores := newChunkScores(policy, o.pool)
+
+	o.Map.Range(func(_, value interface{}) bool {
+		chain, ok := value.(*record)
+		if ok {
+			scores.add(chain)
+		}
+
+		return !scores.sampled()
+	})
+
+	return scores.victim(o)
+}
+
+func (o *SyncMapDictionary) Evict(victim Victim) [][]byte {
+	var (
+		match   = inChunk(victim.chunk)
+		evicted []*record
+	)
+
+	o.writeLock.Lock()
+	for _, hash := range victim.chunk.trackedHashes() {
+		head, removed := o.chain(hash).remove(match)
+		if len(removed) > 0 {
+			o.set(hash, head)
+			evicted = append(evicted, removed...)
+		}
+	}
+	o.writeLock.Unlock()
+
+	return o.reclaim(evicted)
+}
+
+func (o *SyncMapDictionary) reclaim(evicted []*record) [][]byte {
+	if len(evicted) == 0 {
+		return nil
+	}
+
+	keys := evictedKeys(evicted)
+
+	o.live.release(evicted)
+
+	atomic.AddUint64(&o.evictions, uint64(len(evicted)))
+	atomic.AddUint64(&o.evictedChunks, 1)
+
+	_ = o.pool.Clean(context.Background())
+
+	return keys
+}
+
+func (o *SyncMapDictionary) Compact(ctx context.Context, ratio float64) error {
+	var (
+		usage       = newChunkUsage(o.pool, o.timeSource.Now())
+		relocations []relocation
+	)
+
+	o.Map.Range(func(_, value interface{}) bool {
+		chain, ok := value.(*record)
+		if ok {
+			usage.add(chain)
+		}
+
+		return true
+	})
+
+	usage.sparse(ratio)
+
+	if len(usage.live) > 0 {
+		o.Map.Range(func(key, value interface{}) bool {
+			hash, ok := key.(uint64)
+			if !ok {
+				return true
+			}
+
+			chain, _ := value.(*record)
+			relocations = usage.collect(relocations, hash, chain)
+
+			return true
+		})
+	}
+
+	relocated := relocateRecords(ctx, o.pool, relocations, func(hash uint64, old, moved *record) bool {
+		o.writeLock.Lock()
+		defer o.writeLock.Unlock()
+
+		head, ok := o.chain(hash).relocate(old, moved)
+		if ok {
+			o.Store(hash, head)
+		}
+
+		return ok
+	})
+
+	atomic.AddUint64(&o.relocations, relocated)
+
+	return o.pool.Clean(ctx)
+}
+
+func (o *SyncMapDictionary) Stats() Stats {
+	stats := o.pool.Stats()
+	stats.Collisions = atomic.LoadUint64(&o.collisions)
+	stats.Evictions = atomic.LoadUint64(&o.evictions)
+	stats.EvictedChunks = atomic.LoadUint64(&o.evictedChunks)
+	stats.Relocations = atomic.LoadUint64(&o.relocations)
+
+	return o.live.add(stats)
+}
+
+func (o *SyncMapDictionary) chain(hash uint64) *record {
+	v, ok := o.Load(hash)
+	if !ok {
+		return nil
+	}
+
+	rec, _ := v.(*record)
+
+	return rec
+}
+
+func (o *SyncMapDictionary) set(hash uint64, head *record) {
+	if head == nil {
+		o.Map.Delete(hash)
+		return
+	}
+
+	o.Store(hash, head)
 }
 
 func (o *SyncMapDictionary) Clean(ctx context.Context) error {
@@ -68,55 +471,52 @@ func (o *SyncMapDictionary) Clean(ctx context.Context) error {
 func (o *SyncMapDictionary) cleanDictionary(ctx context.Context) error {
 	v := [100]uint64{}
 	index := -1
-	now := time.Now()
+	now := o.timeSource.Now()
 
-	o.Range(func(key, value interface{}) bool {
+	isExpired := func(rec *record) bool {
+		return rec.isExpired(now)
+	}
+
+	o.Map.Range(func(key, value interface{}) bool {
 		select {
 		case <-ctx.Done():
 			return false
 		default:
 		}
 
-		rec, ok := value.(record)
+		rec, ok := value.(*record)
 		if !ok {
 			return true
 		}
 
-		if rec.expiration.After(now) {
+		if !rec.any(isExpired) {
 			return true
 		}
 
-		index++
-		v[index], ok = key.(uint64)
+		hash, ok := key.(uint64)
 		if !ok {
 			return true
 		}
 
-		if index == len(v)-1 {
-			return false
-		}
+		index++
+		v[index] = hash
 
-		return true
+		return index < len(v)-1
 	})
 
-	for _, key := range v {
+	for _, hash := range v[:index+1] {
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
 		}
 
-		v, ok := o.LoadAndDelete(key)
-		if !ok {
-			continue
-		}
-
-		rec, ok := v.(record)
-		if !ok {
-			continue
-		}
+		o.writeLock.Lock()
+		head, removed := o.chain(hash).remove(isExpired)
+		o.set(hash, head)
+		o.writeLock.Unlock()
 
-		rec.reset()
+		o.live.release(removed)
 	}
 
 	return nil
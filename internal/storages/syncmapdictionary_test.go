@@ -11,33 +11,118 @@ import (
 
 func TestSyncMapDictionary_Add(t *testing.T) {
 	hashedKey := uint64(0x8208d73d0fcfef26)
+	key := []byte("0123456789")
 	expiration := time.Now().Add(1 * time.Minute)
 	value := []byte("test-value")
 
 	dataPool := &mockDataPool{}
-	dataPool.On("Copy", value, expiration).Return(newBuffer(dataPool, value), nil)
+	dataPool.On("Copy", key, value, expiration).Return(newKeyValueBuffer(dataPool, key, value), nil)
 	dataPool.On("BufferInUse")
 
-	dict := NewSyncMapDictionary(dataPool)
+	dict := NewSyncMapDictionary(dataPool, systemTime{})
 
-	err := dict.Add(hashedKey, value, expiration)
+	err := dict.Add(hashedKey, key, value, expiration)
 	require.NoError(t, err)
 
-	storedValue, err := dict.Get(hashedKey)
+	storedValue, err := dict.Get(hashedKey, key)
 	require.NoError(t, err)
 	assert.Equal(t, value, storedValue.Bytes())
 
 	dataPool.AssertExpectations(t)
 }
 
+func TestSyncMapDictionary_AddReplace(t *testing.T) {
+	hashedKey := uint64(0x8208d73d0fcfef26)
+	key := []byte("0123456789")
+	expiration := time.Now().Add(1 * time.Minute)
+	value1 := []byte("test-value")
+	value2 := []byte("test-value2")
+
+	dataPool := &mockDataPool{}
+	dataPool.On("Copy", key, value1, expiration).Return(newKeyValueBuffer(dataPool, key, value1), nil)
+	dataPool.On("Copy", key, value2, expiration).Return(newKeyValueBuffer(dataPool, key, value2), nil)
+	dataPool.On("BufferInUse")
+	dataPool.On("BufferRelease").Once()
+	dataPool.On("Stats").Return(Stats{})
+
+	dict := NewSyncMapDictionary(dataPool, systemTime{})
+
+	err := dict.Add(hashedKey, key, value1, expiration)
+	require.NoError(t, err)
+
+	err = dict.Add(hashedKey, key, value2, expiration)
+	require.NoError(t, err)
+
+	storedValue, err := dict.Get(hashedKey, key)
+	require.NoError(t, err)
+	assert.Equal(t, value2, storedValue.Bytes())
+	assert.Equal(t, uint64(0), dict.Stats().Collisions)
+	assert.Equal(t, uint64(1), dict.Stats().Keys)
+
+	dataPool.AssertExpectations(t)
+}
+
+func TestSyncMapDictionary_Collision(t *testing.T) {
+	hashedKey := uint64(0x8208d73d0fcfef26)
+	key1 := []byte("0123456789")
+	value1 := []byte("test-value")
+	key2 := []byte("9876543210")
+	value2 := []byte("test-value2")
+	expiration := time.Now().Add(1 * time.Minute)
+
+	dataPool := &mockDataPool{}
+	dataPool.On("Copy", key1, value1, expiration).Return(newKeyValueBuffer(dataPool, key1, value1), nil)
+	dataPool.On("Copy", key2, value2, expiration).Return(newKeyValueBuffer(dataPool, key2, value2), nil)
+	dataPool.On("BufferInUse")
+	dataPool.On("BufferRelease").Once()
+	dataPool.On("Stats").Return(Stats{})
+
+	dict := NewSyncMapDictionary(dataPool, systemTime{})
+
+	err := dict.Add(hashedKey, key1, value1, expiration)
+	require.NoError(t, err)
+
+	err = dict.Add(hashedKey, key2, value2, expiration)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), dict.Stats().Collisions)
+	assert.Equal(t, uint64(2), dict.Stats().Keys)
+
+	storedValue1, err := dict.Get(hashedKey, key1)
+	require.NoError(t, err)
+	assert.Equal(t, value1, storedValue1.Bytes())
+
+	storedValue2, err := dict.Get(hashedKey, key2)
+	require.NoError(t, err)
+	assert.Equal(t, value2, storedValue2.Bytes())
+
+	_, err = dict.Get(hashedKey, []byte("another-key"))
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrKeyNotFound.Error())
+
+	err = dict.Delete(hashedKey, key1)
+	require.NoError(t, err)
+
+	_, err = dict.Get(hashedKey, key1)
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrKeyNotFound.Error())
+
+	storedValue2, err = dict.Get(hashedKey, key2)
+	require.NoError(t, err)
+	assert.Equal(t, value2, storedValue2.Bytes())
+
+	dataPool.AssertExpectations(t)
+}
+
 func TestSyncMapDictionary_GetNotFound(t *testing.T) {
 	hashedKey := uint64(0x8208d73d0fcfef26)
+	key := []byte("0123456789")
 
 	dataPool := &mockDataPool{}
 
-	dict := NewSyncMapDictionary(dataPool)
+	dict := NewSyncMapDictionary(dataPool, systemTime{})
 
-	_, err := dict.Get(hashedKey)
+	_, err := dict.Get(hashedKey, key)
 	require.Error(t, err)
 	assert.EqualError(t, err, ErrKeyNotFound.Error())
 
@@ -46,18 +131,19 @@ func TestSyncMapDictionary_GetNotFound(t *testing.T) {
 
 func TestSyncMapDictionary_GetExpiration(t *testing.T) {
 	hashedKey := uint64(0x8208d73d0fcfef26)
+	key := []byte("0123456789")
 	expiration := time.Now()
 	value := []byte("test-value")
 
 	dataPool := &mockDataPool{}
-	dataPool.On("Copy", value, expiration).Return(newBuffer(dataPool, value), nil)
+	dataPool.On("Copy", key, value, expiration).Return(newKeyValueBuffer(dataPool, key, value), nil)
 
-	dict := NewSyncMapDictionary(dataPool)
+	dict := NewSyncMapDictionary(dataPool, systemTime{})
 
-	err := dict.Add(hashedKey, value, expiration)
+	err := dict.Add(hashedKey, key, value, expiration)
 	require.NoError(t, err)
 
-	_, err = dict.Get(hashedKey)
+	_, err = dict.Get(hashedKey, key)
 	require.Error(t, err)
 	assert.EqualError(t, err, ErrKeyExpired.Error())
 
@@ -66,35 +152,38 @@ func TestSyncMapDictionary_GetExpiration(t *testing.T) {
 
 func TestSyncMapDictionary_Clean(t *testing.T) {
 	hashedKey1 := uint64(0x8208d73d0fcfef26)
+	key1 := []byte("0123456789")
 	value1 := []byte("test-value")
 	expiration1 := time.Now()
 	hashedKey2 := uint64(0x8208d73d0fcfef27)
+	key2 := []byte("9876543210")
 	value2 := []byte("test-value2")
 	expiration2 := time.Now().Add(1 * time.Minute)
 
 	ctx := context.Background()
 
 	dataPool := &mockDataPool{}
-	dataPool.On("Copy", value1, expiration1).Return(newBuffer(dataPool, value1), nil)
-	dataPool.On("Copy", value2, expiration2).Return(newBuffer(dataPool, value2), nil)
+	dataPool.On("Copy", key1, value1, expiration1).Return(newKeyValueBuffer(dataPool, key1, value1), nil)
+	dataPool.On("Copy", key2, value2, expiration2).Return(newKeyValueBuffer(dataPool, key2, value2), nil)
 	dataPool.On("BufferInUse")
+	dataPool.On("BufferRelease")
 	dataPool.On("Clean", ctx).Return(nil)
 
-	dict := NewSyncMapDictionary(dataPool)
+	dict := NewSyncMapDictionary(dataPool, systemTime{})
 
 	// Add
-	err := dict.Add(hashedKey1, value1, expiration1)
+	err := dict.Add(hashedKey1, key1, value1, expiration1)
 	require.NoError(t, err)
 
-	err = dict.Add(hashedKey2, value2, expiration2)
+	err = dict.Add(hashedKey2, key2, value2, expiration2)
 	require.NoError(t, err)
 
 	// Get: 1
-	_, err = dict.Get(hashedKey1)
+	_, err = dict.Get(hashedKey1, key1)
 	require.Error(t, err)
 	assert.EqualError(t, err, ErrKeyExpired.Error())
 
-	storedValue2, err := dict.Get(hashedKey2)
+	storedValue2, err := dict.Get(hashedKey2, key2)
 	require.NoError(t, err)
 	assert.Equal(t, value2, storedValue2.Bytes())
 
@@ -103,13 +192,138 @@ func TestSyncMapDictionary_Clean(t *testing.T) {
 	require.NoError(t, err)
 
 	// Get: 2
-	_, err = dict.Get(hashedKey1)
+	_, err = dict.Get(hashedKey1, key1)
 	require.Error(t, err)
 	assert.EqualError(t, err, ErrKeyNotFound.Error())
 
-	storedValue2, err = dict.Get(hashedKey2)
+	storedValue2, err = dict.Get(hashedKey2, key2)
 	require.NoError(t, err)
 	assert.Equal(t, value2, storedValue2.Bytes())
 
 	dataPool.AssertExpectations(t)
 }
+
+func TestSyncMapDictionary_Delete(t *testing.T) {
+	hashedKey := uint64(0x8208d73d0fcfef26)
+	key := []byte("0123456789")
+	expiration := time.Now().Add(1 * time.Minute)
+	value := []byte("test-value")
+
+	dataPool := &mockDataPool{}
+	dataPool.On("Copy", key, value, expiration).Return(newKeyValueBuffer(dataPool, key, value), nil)
+	dataPool.On("BufferRelease")
+
+	dict := NewSyncMapDictionary(dataPool, systemTime{})
+
+	err := dict.Add(hashedKey, key, value, expiration)
+	require.NoError(t, err)
+
+	err = dict.Delete(hashedKey, key)
+	require.NoError(t, err)
+
+	_, err = dict.Get(hashedKey, key)
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrKeyNotFound.Error())
+
+	err = dict.Delete(hashedKey, key)
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrKeyNotFound.Error())
+
+	dataPool.AssertExpectations(t)
+}
+
+func TestSyncMapDictionary_Scan(t *testing.T) {
+	expiration := time.Now().Add(1 * time.Minute)
+	keys := [][]byte{[]byte("/a1"), []byte("/b1"), []byte("/a2"), []byte("/a3")}
+	value := []byte("test-value")
+
+	dataPool := &mockDataPool{}
+
+	for _, key := range keys {
+		dataPool.On("Copy", key, value, expiration).Return(newKeyValueBuffer(dataPool, key, value), nil)
+	}
+
+	expiredKey := []byte("/a0")
+	dataPool.On("Copy", expiredKey, value, time.Time{}).Return(newKeyValueBuffer(dataPool, expiredKey, value), nil)
+
+	dict := NewSyncMapDictionary(dataPool, systemTime{})
+
+	for i, key := range keys {
+		err := dict.Add(uint64(i+1), key, value, expiration)
+		require.NoError(t, err)
+	}
+
+	err := dict.Add(0, expiredKey, value, time.Time{})
+	require.NoError(t, err)
+
+	dataPool.On("BufferInUse")
+	dataPool.On("BufferFree")
+
+	entries := dict.Scan([]byte("/a"), 0, 10)
+	assert.Equal(t, []ScanEntry{
+		{Position: 1, Key: keys[0]},
+		{Position: 3, Key: keys[2]},
+		{Position: 4, Key: keys[3]},
+	}, entries)
+
+	entries = dict.Scan(nil, 2, 2)
+	assert.Equal(t, []ScanEntry{
+		{Position: 2, Key: keys[1]},
+		{Position: 3, Key: keys[2]},
+	}, entries)
+
+	// keys are read under references of their chunks
+	assert.NotZero(t, countCalls(&dataPool.Mock, "BufferInUse"))
+	assert.Equal(t, countCalls(&dataPool.Mock, "BufferInUse"), countCalls(&dataPool.Mock, "BufferFree"))
+
+	dataPool.AssertExpectations(t)
+}
+
+func TestSyncMapDictionary_Range(t *testing.T) {
+	expiration := time.Now().Add(1 * time.Minute)
+	keys := [][]byte{[]byte("/1"), []byte("/2")}
+	value := []byte("test-value")
+	ctx := context.Background()
+
+	dataPool := &mockDataPool{}
+	dataPool.On("BufferInUse")
+	dataPool.On("BufferFree")
+
+	for _, key := range keys {
+		dataPool.On("Copy", key, value, expiration).Return(newKeyValueBuffer(dataPool, key, value), nil)
+	}
+
+	expiredKey := []byte("/0")
+	dataPool.On("Copy", expiredKey, value, time.Time{}).Return(newKeyValueBuffer(dataPool, expiredKey, value), nil)
+
+	dict := NewSyncMapDictionary(dataPool, systemTime{})
+
+	for i, key := range keys {
+		err := dict.Add(uint64(i+1), key, value, expiration)
+		require.NoError(t, err)
+	}
+
+	err := dict.Add(0, expiredKey, value, time.Time{})
+	require.NoError(t, err)
+
+	var rangedKeys [][]byte
+
+	err = dict.Range(ctx, func(key, rangedValue []byte, _ uint32, rangedExpiration time.Time) error {
+		rangedKeys = append(rangedKeys, append([]byte(nil), key...))
+
+		assert.Equal(t, value, rangedValue)
+		assert.Equal(t, expiration, rangedExpiration)
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, keys, rangedKeys)
+
+	err = dict.Range(ctx, func(_, _ []byte, _ uint32, _ time.Time) error {
+		return ErrOutOfLimit
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, ErrOutOfLimit.Error())
+
+	dataPool.AssertExpectations(t)
+}
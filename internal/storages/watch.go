@@ -0,0 +1,117 @@
+package storages
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+const watchBuffer = 1024
+
+type EventType int
+
+const (
+	EventSet EventType = iota + 1
+	EventExpire
+	EventDelete
+)
+
+func (o EventType) String() string {
+	switch o {
+	case EventSet:
+		return "set"
+	case EventExpire:
+		return "expire"
+	case EventDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+type Event struct {
+	Type       EventType
+	Key        []byte
+	Value      []byte
+	Expiration time.Time
+}
+
+type WatchFunc func(event Event) error
+
+type watcher struct {
+	prefix   []byte
+	events   chan Event
+	overflow chan struct{}
+	once     sync.Once
+}
+
+type watchers struct {
+	sync.RWMutex
+
+	list map[*watcher]struct{}
+}
+
+func newWatchers() *watchers {
+	return &watchers{
+		list: make(map[*watcher]struct{}),
+	}
+}
+
+func (o *watchers) watch(ctx context.Context, prefix []byte, fn WatchFunc) error {
+	w := &watcher{
+		prefix:   append([]byte(nil), prefix...),
+		events:   make(chan Event, watchBuffer),
+		overflow: make(chan struct{}),
+	}
+
+	o.Lock()
+	o.list[w] = struct{}{}
+	o.Unlock()
+
+	defer func() {
+		o.Lock()
+		delete(o.list, w)
+		o.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.overflow:
+			return ErrWatchOverflow
+		case event := <-w.events:
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (o *watchers) notify(event Event) {
+	o.RLock()
+	defer o.RUnlock()
+
+	copied := false
+
+	for w := range o.list {
+		if !bytes.HasPrefix(event.Key, w.prefix) {
+			continue
+		}
+
+		if !copied {
+			event.Key = append([]byte(nil), event.Key...)
+			event.Value = append([]byte(nil), event.Value...)
+			copied = true
+		}
+
+		select {
+		case w.events <- event:
+		default:
+			w.once.Do(func() {
+				close(w.overflow)
+			})
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package storages
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitWatchers waits for watchers subscribed in other goroutines.
+func waitWatchers(t *testing.T, storage Storages, count int) {
+	w := storage.(*InMemStorages).watchers
+
+	require.Eventually(t, func() bool {
+		w.RLock()
+		defer w.RUnlock()
+
+		return len(w.list) == count
+	}, time.Second, time.Millisecond)
+}
+
+func TestInMemStorages_Watch(t *testing.T) {
+	storage := newModeStorages(t, config.StorageModeMap, &mockConfig{
+		Exp:   time.Minute,
+		TimeS: constantTime(time.Now()),
+	})
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		events      = make(chan Event, 10)
+		done        = make(chan error)
+	)
+
+	go func() {
+		done <- storage.Watch(ctx, []byte("/a"), func(event Event) error {
+			events <- event
+			return nil
+		})
+	}()
+
+	waitWatchers(t, storage, 1)
+
+	value := []byte("v1")
+
+	require.NoError(t, storage.Add([]byte("/b"), []byte("skipped"), 0))
+	require.NoError(t, storage.Add([]byte("/a1"), value, 0))
+	copy(value, "xx")
+	require.NoError(t, storage.Expire([]byte("/a1"), time.Hour))
+	require.NoError(t, storage.Delete([]byte("/a1")))
+
+	event := <-events
+	assert.Equal(t, EventSet, event.Type)
+	assert.Equal(t, []byte("/a1"), event.Key)
+	assert.Equal(t, []byte("v1"), event.Value)
+
+	event = <-events
+	assert.Equal(t, EventExpire, event.Type)
+
+	event = <-events
+	assert.Equal(t, EventDelete, event.Type)
+	assert.Equal(t, []byte("/a1"), event.Key)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	waitWatchers(t, storage, 0)
+}
+
+func TestInMemStorages_WatchOverflow(t *testing.T) {
+	storage := newModeStorages(t, config.StorageModeMap, &mockConfig{
+		Exp:   time.Minute,
+		TimeS: constantTime(time.Now()),
+	})
+
+	var (
+		blocked = make(chan struct{})
+		done    = make(chan error)
+	)
+
+	go func() {
+		done <- storage.Watch(context.Background(), nil, func(event Event) error {
+			<-blocked
+			return nil
+		})
+	}()
+
+	waitWatchers(t, storage, 1)
+
+	// a writer isn't blocked by a slow watcher
+	for i := 0; i < watchBuffer+2; i++ {
+		require.NoError(t, storage.Add([]byte("/key"), []byte("value"), 0))
+	}
+
+	close(blocked)
+
+	assert.Equal(t, ErrWatchOverflow, <-done)
+}
+
+func TestInMemStorages_WatchConcurrentKey(t *testing.T) {
+	const (
+		workers = 8
+		writes  = 100
+	)
+
+	storage := newModeStorages(t, config.StorageModeSyncMap, &mockConfig{
+		Exp:   time.Minute,
+		TimeS: constantTime(time.Now()),
+	})
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		events      = make(chan Event, workers*writes)
+		done        = make(chan error)
+		key         = []byte("/key")
+		wg          sync.WaitGroup
+	)
+
+	go func() {
+		done <- storage.Watch(ctx, key, func(event Event) error {
+			events <- event
+			return nil
+		})
+	}()
+
+	waitWatchers(t, storage, 1)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < writes; i++ {
+				require.NoError(t, storage.Add(key, []byte(fmt.Sprintf("value-%d-%d", w, i)), 0))
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	var last Event
+
+	for i := 0; i < workers*writes; i++ {
+		last = <-events
+	}
+
+	// the last event is the last change applied to the key
+	assertValue(t, storage, key, last.Value)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
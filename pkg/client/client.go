@@ -0,0 +1,279 @@
+package client
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/7phs/kvs/pkg/protocol"
+)
+
+const (
+	ErrNotFound   Error = "not_found"
+	ErrBadRequest Error = "bad_request"
+	ErrOutOfLimit Error = "out_of_limit"
+	ErrNotReady   Error = "not_ready"
+	ErrServer     Error = "server_error"
+	ErrClosed     Error = "client_closed"
+)
+
+type Error string
+
+func (o Error) Error() string {
+	return string(o)
+}
+
+type Client struct {
+	sync.Mutex
+
+	conn    net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+	buf     []byte
+	frame   []byte
+	timeout time.Duration
+	err     error
+}
+
+func Dial(addr string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewClient(conn)
+	c.timeout = timeout
+
+	return c, nil
+}
+
+func NewClient(conn net.Conn) *Client {
+	return &Client{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+}
+
+func (c *Client) Get(key []byte) ([]byte, error) {
+	results, err := c.do([]protocol.Request{{Op: protocol.OpGet, Key: key}})
+	if err != nil {
+		return nil, err
+	}
+
+	return results[0].Value, results[0].Err
+}
+
+func (c *Client) Set(key, value []byte, ttl time.Duration) error {
+	results, err := c.do([]protocol.Request{{Op: protocol.OpSet, Key: key, Value: value, TTL: ttl}})
+	if err != nil {
+		return err
+	}
+
+	return results[0].Err
+}
+
+func (c *Client) Delete(key []byte) error {
+	results, err := c.do([]protocol.Request{{Op: protocol.OpDelete, Key: key}})
+	if err != nil {
+		return err
+	}
+
+	return results[0].Err
+}
+
+func (c *Client) TTL(key []byte) (time.Duration, error) {
+	results, err := c.do([]protocol.Request{{Op: protocol.OpTTL, Key: key}})
+	if err != nil {
+		return 0, err
+	}
+
+	return results[0].TTL, results[0].Err
+}
+
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{client: c}
+}
+
+func (c *Client) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.err == ErrClosed {
+		return nil
+	}
+
+	c.err = ErrClosed
+
+	return c.conn.Close()
+}
+
+func (c *Client) do(requests []protocol.Request) ([]Result, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	var err error
+
+	c.buf = c.buf[:0]
+
+	for _, req := range requests {
+		c.buf, err = protocol.AppendRequest(c.buf, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := c.roundTrip(requests)
+	if err != nil {
+		c.err = err
+		_ = c.conn.Close()
+
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (c *Client) roundTrip(requests []protocol.Request) ([]Result, error) {
+	var err error
+
+	if c.timeout > 0 {
+		err = c.conn.SetDeadline(time.Now().Add(c.timeout))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// responses are read, while requests are written, so a long pipeline can't stall on full socket buffers
+	written := make(chan error, 1)
+
+	go func(buf []byte) {
+		_, err := c.w.Write(buf)
+		if err == nil {
+			err = c.w.Flush()
+		}
+
+		if err != nil {
+			_ = c.conn.Close()
+		}
+
+		written <- err
+	}(c.buf)
+
+	results := make([]Result, len(requests))
+
+	for i, req := range requests {
+		c.frame, err = protocol.ReadFrame(c.r, c.frame)
+		if err != nil {
+			return nil, c.interrupt(err, written)
+		}
+
+		resp, err := protocol.ParseResponse(c.frame)
+		if err != nil {
+			return nil, c.interrupt(err, written)
+		}
+
+		results[i], err = newResult(req.Op, resp)
+		if err != nil {
+			return nil, c.interrupt(err, written)
+		}
+	}
+
+	err = <-written
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (c *Client) interrupt(err error, written <-chan error) error {
+	_ = c.conn.Close()
+	<-written
+
+	return err
+}
+
+type Result struct {
+	Value []byte
+	TTL   time.Duration
+	Err   error
+}
+
+func newResult(op protocol.Op, resp protocol.Response) (Result, error) {
+	var result Result
+
+	switch resp.Status {
+	case protocol.StatusOK:
+	case protocol.StatusNotFound:
+		return Result{Err: ErrNotFound}, nil
+	case protocol.StatusBadRequest:
+		return Result{Err: ErrBadRequest}, nil
+	case protocol.StatusOutOfLimit:
+		return Result{Err: ErrOutOfLimit}, nil
+	case protocol.StatusNotReady:
+		return Result{Err: ErrNotReady}, nil
+	default:
+		return Result{Err: ErrServer}, nil
+	}
+
+	switch op {
+	case protocol.OpGet:
+		// a payload refers to a buffer of the client reused by the next response
+		result.Value = append([]byte(nil), resp.Payload...)
+	case protocol.OpTTL:
+		ttl, err := resp.TTL()
+		if err != nil {
+			return Result{}, err
+		}
+
+		result.TTL = ttl
+	}
+
+	return result, nil
+}
+
+type Pipeline struct {
+	client   *Client
+	requests []protocol.Request
+}
+
+func (p *Pipeline) Get(key []byte) *Pipeline {
+	p.requests = append(p.requests, protocol.Request{Op: protocol.OpGet, Key: key})
+
+	return p
+}
+
+func (p *Pipeline) Set(key, value []byte, ttl time.Duration) *Pipeline {
+	p.requests = append(p.requests, protocol.Request{Op: protocol.OpSet, Key: key, Value: value, TTL: ttl})
+
+	return p
+}
+
+func (p *Pipeline) Delete(key []byte) *Pipeline {
+	p.requests = append(p.requests, protocol.Request{Op: protocol.OpDelete, Key: key})
+
+	return p
+}
+
+func (p *Pipeline) TTL(key []byte) *Pipeline {
+	p.requests = append(p.requests, protocol.Request{Op: protocol.OpTTL, Key: key})
+
+	return p
+}
+
+func (p *Pipeline) Exec() ([]Result, error) {
+	requests := p.requests
+	p.requests = nil
+
+	if len(requests) == 0 {
+		return nil, nil
+	}
+
+	return p.client.do(requests)
+}
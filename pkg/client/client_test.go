@@ -0,0 +1,125 @@
+package client
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/7phs/kvs/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveFake answers commands of the binary protocol by a map without expiration.
+func serveFake(conn net.Conn) {
+	var (
+		r     = bufio.NewReader(conn)
+		w     = bufio.NewWriter(conn)
+		data  = map[string][]byte{}
+		frame []byte
+		err   error
+	)
+
+	defer conn.Close()
+
+	for {
+		frame, err = protocol.ReadFrame(r, frame)
+		if err != nil {
+			return
+		}
+
+		var resp []byte
+
+		req, err := protocol.ParseRequest(frame)
+		if err != nil {
+			resp = protocol.AppendResponse(nil, protocol.StatusBadRequest, nil)
+		} else {
+			value, ok := data[string(req.Key)]
+
+			switch {
+			case req.Op == protocol.OpSet:
+				data[string(req.Key)] = append([]byte(nil), req.Value...)
+				resp = protocol.AppendResponse(nil, protocol.StatusOK, nil)
+			case !ok:
+				resp = protocol.AppendResponse(nil, protocol.StatusNotFound, nil)
+			case req.Op == protocol.OpGet:
+				resp = protocol.AppendResponse(nil, protocol.StatusOK, value)
+			case req.Op == protocol.OpDelete:
+				delete(data, string(req.Key))
+				resp = protocol.AppendResponse(nil, protocol.StatusOK, nil)
+			case req.Op == protocol.OpTTL:
+				resp = protocol.AppendTTLResponse(nil, time.Minute)
+			}
+		}
+
+		_, _ = w.Write(resp)
+
+		if r.Buffered() == 0 {
+			_ = w.Flush()
+		}
+	}
+}
+
+func newTestClient() *Client {
+	clientConn, serverConn := net.Pipe()
+
+	go serveFake(serverConn)
+
+	return NewClient(clientConn)
+}
+
+func TestClient(t *testing.T) {
+	c := newTestClient()
+	defer c.Close()
+
+	_, err := c.Get([]byte("key"))
+	assert.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, c.Set([]byte("key"), []byte("value"), time.Minute))
+
+	value, err := c.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	ttl, err := c.TTL([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	require.NoError(t, c.Delete([]byte("key")))
+	assert.Equal(t, ErrNotFound, c.Delete([]byte("key")))
+}
+
+func TestClient_Pipeline(t *testing.T) {
+	c := newTestClient()
+	defer c.Close()
+
+	results, err := c.Pipeline().
+		Set([]byte("k1"), []byte("v1"), 0).
+		Set([]byte("k2"), []byte("v2"), 0).
+		Get([]byte("k1")).
+		Get([]byte("k3")).
+		Delete([]byte("k2")).
+		TTL([]byte("k1")).
+		Exec()
+	require.NoError(t, err)
+
+	assert.Equal(t, []Result{
+		{},
+		{},
+		{Value: []byte("v1")},
+		{Err: ErrNotFound},
+		{},
+		{TTL: time.Minute},
+	}, results)
+}
+
+func TestClient_Closed(t *testing.T) {
+	c := newTestClient()
+
+	require.NoError(t, c.Close())
+	require.NoError(t, c.Close())
+
+	_, err := c.Get([]byte("key"))
+	assert.Equal(t, ErrClosed, err)
+}
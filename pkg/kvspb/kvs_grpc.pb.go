@@ -0,0 +1,448 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package kvspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// KVSClient is the client API for KVS service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type KVSClient interface {
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchGetResponse, error)
+	BatchSet(ctx context.Context, in *BatchSetRequest, opts ...grpc.CallOption) (*BatchSetResponse, error)
+	// Scan streams keys with a prefix.
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (KVS_ScanClient, error)
+	// Watch streams changes of keys with a prefix. Evicted and expired keys aren't reported.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KVS_WatchClient, error)
+	// Load stores a stream of items and returns a number of stored and failed ones.
+	Load(ctx context.Context, opts ...grpc.CallOption) (KVS_LoadClient, error)
+}
+
+type kVSClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewKVSClient(cc grpc.ClientConnInterface) KVSClient {
+	return &kVSClient{cc}
+}
+
+func (c *kVSClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, "/kvs.v1.KVS/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVSClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error) {
+	out := new(SetResponse)
+	err := c.cc.Invoke(ctx, "/kvs.v1.KVS/Set", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVSClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/kvs.v1.KVS/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVSClient) BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchGetResponse, error) {
+	out := new(BatchGetResponse)
+	err := c.cc.Invoke(ctx, "/kvs.v1.KVS/BatchGet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVSClient) BatchSet(ctx context.Context, in *BatchSetRequest, opts ...grpc.CallOption) (*BatchSetResponse, error) {
+	out := new(BatchSetResponse)
+	err := c.cc.Invoke(ctx, "/kvs.v1.KVS/BatchSet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVSClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (KVS_ScanClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KVS_ServiceDesc.Streams[0], "/kvs.v1.KVS/Scan", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVSScanClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KVS_ScanClient interface {
+	Recv() (*ScanResponse, error)
+	grpc.ClientStream
+}
+
+type kVSScanClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVSScanClient) Recv() (*ScanResponse, error) {
+	m := new(ScanResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVSClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KVS_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KVS_ServiceDesc.Streams[1], "/kvs.v1.KVS/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVSWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KVS_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type kVSWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVSWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVSClient) Load(ctx context.Context, opts ...grpc.CallOption) (KVS_LoadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KVS_ServiceDesc.Streams[2], "/kvs.v1.KVS/Load", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVSLoadClient{stream}
+	return x, nil
+}
+
+type KVS_LoadClient interface {
+	Send(*SetRequest) error
+	CloseAndRecv() (*LoadResponse, error)
+	grpc.ClientStream
+}
+
+type kVSLoadClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVSLoadClient) Send(m *SetRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kVSLoadClient) CloseAndRecv() (*LoadResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(LoadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// KVSServer is the server API for KVS service.
+// All implementations must embed UnimplementedKVSServer
+// for forward compatibility
+type KVSServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	BatchGet(context.Context, *BatchGetRequest) (*BatchGetResponse, error)
+	BatchSet(context.Context, *BatchSetRequest) (*BatchSetResponse, error)
+	// Scan streams keys with a prefix.
+	Scan(*ScanRequest, KVS_ScanServer) error
+	// Watch streams changes of keys with a prefix. Evicted and expired keys aren't reported.
+	Watch(*WatchRequest, KVS_WatchServer) error
+	// Load stores a stream of items and returns a number of stored and failed ones.
+	Load(KVS_LoadServer) error
+	mustEmbedUnimplementedKVSServer()
+}
+
+// UnimplementedKVSServer must be embedded to have forward compatible implementations.
+type UnimplementedKVSServer struct {
+}
+
+func (UnimplementedKVSServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedKVSServer) Set(context.Context, *SetRequest) (*SetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (UnimplementedKVSServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedKVSServer) BatchGet(context.Context, *BatchGetRequest) (*BatchGetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGet not implemented")
+}
+func (UnimplementedKVSServer) BatchSet(context.Context, *BatchSetRequest) (*BatchSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchSet not implemented")
+}
+func (UnimplementedKVSServer) Scan(*ScanRequest, KVS_ScanServer) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedKVSServer) Watch(*WatchRequest, KVS_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedKVSServer) Load(KVS_LoadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Load not implemented")
+}
+func (UnimplementedKVSServer) mustEmbedUnimplementedKVSServer() {}
+
+// UnsafeKVSServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to KVSServer will
+// result in compilation errors.
+type UnsafeKVSServer interface {
+	mustEmbedUnimplementedKVSServer()
+}
+
+func RegisterKVSServer(s grpc.ServiceRegistrar, srv KVSServer) {
+	s.RegisterService(&KVS_ServiceDesc, srv)
+}
+
+func _KVS_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVSServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kvs.v1.KVS/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVSServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVS_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVSServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kvs.v1.KVS/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVSServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVS_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVSServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kvs.v1.KVS/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVSServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVS_BatchGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVSServer).BatchGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kvs.v1.KVS/BatchGet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVSServer).BatchGet(ctx, req.(*BatchGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVS_BatchSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVSServer).BatchSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kvs.v1.KVS/BatchSet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVSServer).BatchSet(ctx, req.(*BatchSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVS_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVSServer).Scan(m, &kVSScanServer{stream})
+}
+
+type KVS_ScanServer interface {
+	Send(*ScanResponse) error
+	grpc.ServerStream
+}
+
+type kVSScanServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVSScanServer) Send(m *ScanResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KVS_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVSServer).Watch(m, &kVSWatchServer{stream})
+}
+
+type KVS_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type kVSWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVSWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KVS_Load_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KVSServer).Load(&kVSLoadServer{stream})
+}
+
+type KVS_LoadServer interface {
+	SendAndClose(*LoadResponse) error
+	Recv() (*SetRequest, error)
+	grpc.ServerStream
+}
+
+type kVSLoadServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVSLoadServer) SendAndClose(m *LoadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kVSLoadServer) Recv() (*SetRequest, error) {
+	m := new(SetRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// KVS_ServiceDesc is the grpc.ServiceDesc for KVS service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var KVS_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kvs.v1.KVS",
+	HandlerType: (*KVSServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _KVS_Get_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _KVS_Set_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _KVS_Delete_Handler,
+		},
+		{
+			MethodName: "BatchGet",
+			Handler:    _KVS_BatchGet_Handler,
+		},
+		{
+			MethodName: "BatchSet",
+			Handler:    _KVS_BatchSet_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scan",
+			Handler:       _KVS_Scan_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _KVS_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Load",
+			Handler:       _KVS_Load_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "kvs.proto",
+}
@@ -0,0 +1,219 @@
+package kvspb
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeServer keeps keys in a map.
+type fakeServer struct {
+	UnimplementedKVSServer
+	sync.Mutex
+
+	keys map[string][]byte
+}
+
+func (o *fakeServer) Get(_ context.Context, req *GetRequest) (*GetResponse, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	value, ok := o.keys[string(req.Key)]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "key_not_found")
+	}
+
+	return &GetResponse{Value: value}, nil
+}
+
+func (o *fakeServer) Set(_ context.Context, req *SetRequest) (*SetResponse, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	o.keys[string(req.Key)] = req.Value
+
+	return &SetResponse{}, nil
+}
+
+func (o *fakeServer) Delete(_ context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	delete(o.keys, string(req.Key))
+
+	return &DeleteResponse{}, nil
+}
+
+func (o *fakeServer) BatchGet(ctx context.Context, req *BatchGetRequest) (*BatchGetResponse, error) {
+	resp := &BatchGetResponse{}
+
+	for _, key := range req.Keys {
+		item := &GetItem{}
+
+		value, err := o.Get(ctx, &GetRequest{Key: key})
+		if err != nil {
+			item.Status = Status_NOT_FOUND
+		} else {
+			item.Value = value.Value
+		}
+
+		resp.Items = append(resp.Items, item)
+	}
+
+	return resp, nil
+}
+
+func (o *fakeServer) BatchSet(ctx context.Context, req *BatchSetRequest) (*BatchSetResponse, error) {
+	resp := &BatchSetResponse{}
+
+	for _, item := range req.Items {
+		_, _ = o.Set(ctx, &SetRequest{Key: item.Key, Value: item.Value})
+		resp.Statuses = append(resp.Statuses, Status_OK)
+	}
+
+	return resp, nil
+}
+
+func (o *fakeServer) Scan(req *ScanRequest, stream KVS_ScanServer) error {
+	for i := 0; i < 2; i++ {
+		err := stream.Send(&ScanResponse{Keys: [][]byte{append(req.Prefix, byte('0'+i))}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *fakeServer) Watch(req *WatchRequest, stream KVS_WatchServer) error {
+	return stream.Send(&WatchEvent{Type: EventType_EVENT_TYPE_DELETE, Key: req.Prefix})
+}
+
+func (o *fakeServer) Load(stream KVS_LoadServer) error {
+	resp := &LoadResponse{}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		_, _ = o.Set(stream.Context(), req)
+		resp.Stored++
+	}
+}
+
+func newTestClient(t *testing.T) (KVSClient, func()) {
+	listener := bufconn.Listen(1 << 20)
+
+	server := grpc.NewServer()
+	RegisterKVSServer(server, &fakeServer{keys: map[string][]byte{}})
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+	)
+	require.NoError(t, err)
+
+	return NewKVSClient(conn), func() {
+		_ = conn.Close()
+		server.Stop()
+	}
+}
+
+func TestKVSClient(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	_, err := client.Set(ctx, &SetRequest{Key: []byte("1"), Value: []byte("value-1")})
+	require.NoError(t, err)
+
+	resp, err := client.Get(ctx, &GetRequest{Key: []byte("1")})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value-1"), resp.Value)
+
+	_, err = client.Delete(ctx, &DeleteRequest{Key: []byte("1")})
+	require.NoError(t, err)
+
+	_, err = client.Get(ctx, &GetRequest{Key: []byte("1")})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	setResp, err := client.BatchSet(ctx, &BatchSetRequest{Items: []*SetItem{{Key: []byte("2"), Value: []byte("value-2")}}})
+	require.NoError(t, err)
+	assert.Equal(t, []Status{Status_OK}, setResp.Statuses)
+
+	getResp, err := client.BatchGet(ctx, &BatchGetRequest{Keys: [][]byte{[]byte("1"), []byte("2")}})
+	require.NoError(t, err)
+	require.Len(t, getResp.Items, 2)
+	assert.Equal(t, Status_NOT_FOUND, getResp.Items[0].Status)
+	assert.Equal(t, []byte("value-2"), getResp.Items[1].Value)
+}
+
+func TestKVSClient_Streams(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	scan, err := client.Scan(ctx, &ScanRequest{Prefix: []byte("/")})
+	require.NoError(t, err)
+
+	var keys [][]byte
+
+	for {
+		resp, err := scan.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+
+		keys = append(keys, resp.Keys...)
+	}
+
+	assert.Equal(t, [][]byte{[]byte("/0"), []byte("/1")}, keys)
+
+	watch, err := client.Watch(ctx, &WatchRequest{Prefix: []byte("/w")})
+	require.NoError(t, err)
+
+	event, err := watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, EventType_EVENT_TYPE_DELETE, event.Type)
+	assert.Equal(t, []byte("/w"), event.Key)
+
+	load, err := client.Load(ctx)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, load.Send(&SetRequest{Key: []byte{byte('a' + i)}, Value: []byte("v")}))
+	}
+
+	loadResp, err := load.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), loadResp.Stored)
+
+	resp, err := client.Get(ctx, &GetRequest{Key: []byte("c")})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), resp.Value)
+}
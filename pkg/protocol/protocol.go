@@ -0,0 +1,258 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	HeaderSize   = 4
+	MaxKeySize   = 1<<16 - 1
+	MaxFrameSize = 64 << 20
+
+	NoTTL time.Duration = -1
+
+	maxTTL     = time.Duration(1<<63 - 1)
+	noTTLValue = 1<<64 - 1
+
+	ErrMalformedFrame Error = "malformed_frame"
+	ErrFrameTooLarge  Error = "frame_too_large"
+	ErrKeyTooLarge    Error = "key_too_large"
+	ErrInvalidTTL     Error = "invalid_ttl"
+	ErrUnknownOp      Error = "unknown_op"
+)
+
+type Error string
+
+func (o Error) Error() string {
+	return string(o)
+}
+
+type Op byte
+
+const (
+	OpGet Op = iota + 1
+	OpSet
+	OpDelete
+	OpTTL
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpGet:
+		return "get"
+	case OpSet:
+		return "set"
+	case OpDelete:
+		return "delete"
+	case OpTTL:
+		return "ttl"
+	default:
+		return "unknown"
+	}
+}
+
+type Status byte
+
+const (
+	StatusOK Status = iota
+	StatusNotFound
+	StatusBadRequest
+	StatusOutOfLimit
+	StatusError
+	StatusNotReady
+)
+
+func (o Status) String() string {
+	switch o {
+	case StatusOK:
+		return "ok"
+	case StatusNotFound:
+		return "not_found"
+	case StatusBadRequest:
+		return "bad_request"
+	case StatusOutOfLimit:
+		return "out_of_limit"
+	case StatusNotReady:
+		return "not_ready"
+	default:
+		return "error"
+	}
+}
+
+type Request struct {
+	Op    Op
+	Key   []byte
+	TTL   time.Duration
+	Value []byte
+}
+
+type Response struct {
+	Status  Status
+	Payload []byte
+}
+
+func (o Response) TTL() (time.Duration, error) {
+	if len(o.Payload) != 8 {
+		return 0, ErrMalformedFrame
+	}
+
+	v := binary.BigEndian.Uint64(o.Payload)
+	if v == noTTLValue {
+		return NoTTL, nil
+	}
+
+	return time.Duration(v) * time.Millisecond, nil
+}
+
+func AppendRequest(dst []byte, req Request) ([]byte, error) {
+	if len(req.Key) > MaxKeySize {
+		return dst, ErrKeyTooLarge
+	}
+
+	if req.Op == OpSet && req.TTL != NoTTL && (req.TTL < 0 || req.TTL > 0 && req.TTL < time.Millisecond) {
+		return dst, ErrInvalidTTL
+	}
+
+	size := 1 + 2 + len(req.Key)
+	if req.Op == OpSet {
+		size += 8 + len(req.Value)
+	}
+
+	if size > MaxFrameSize {
+		return dst, ErrFrameTooLarge
+	}
+
+	dst = appendUint32(dst, uint32(size))
+	dst = append(dst, byte(req.Op))
+	dst = append(dst, byte(len(req.Key)>>8), byte(len(req.Key)))
+	dst = append(dst, req.Key...)
+
+	if req.Op == OpSet {
+		dst = appendUint64(dst, encodeTTL(req.TTL))
+		dst = append(dst, req.Value...)
+	}
+
+	return dst, nil
+}
+
+func ParseRequest(frame []byte) (Request, error) {
+	if len(frame) < 3 {
+		return Request{}, ErrMalformedFrame
+	}
+
+	req := Request{
+		Op: Op(frame[0]),
+	}
+
+	keySize := int(binary.BigEndian.Uint16(frame[1:3]))
+	frame = frame[3:]
+
+	if keySize > len(frame) {
+		return Request{}, ErrMalformedFrame
+	}
+
+	req.Key, frame = frame[:keySize:keySize], frame[keySize:]
+
+	switch req.Op {
+	case OpGet, OpDelete, OpTTL:
+		if len(frame) > 0 {
+			return Request{}, ErrMalformedFrame
+		}
+
+	case OpSet:
+		if len(frame) < 8 {
+			return Request{}, ErrMalformedFrame
+		}
+
+		ttl := binary.BigEndian.Uint64(frame[:8])
+
+		switch {
+		case ttl == noTTLValue:
+			req.TTL = NoTTL
+		case ttl > uint64(maxTTL/time.Millisecond):
+			return Request{}, ErrMalformedFrame
+		default:
+			req.TTL = time.Duration(ttl) * time.Millisecond
+		}
+
+		req.Value = frame[8:]
+
+	default:
+		return Request{}, ErrUnknownOp
+	}
+
+	return req, nil
+}
+
+func AppendResponse(dst []byte, status Status, payload []byte) []byte {
+	dst = appendUint32(dst, uint32(1+len(payload)))
+	dst = append(dst, byte(status))
+
+	return append(dst, payload...)
+}
+
+func AppendTTLResponse(dst []byte, ttl time.Duration) []byte {
+	var payload [8]byte
+
+	binary.BigEndian.PutUint64(payload[:], encodeTTL(ttl))
+
+	return AppendResponse(dst, StatusOK, payload[:])
+}
+
+func encodeTTL(ttl time.Duration) uint64 {
+	if ttl < 0 {
+		return noTTLValue
+	}
+
+	return uint64(ttl / time.Millisecond)
+}
+
+func ParseResponse(frame []byte) (Response, error) {
+	if len(frame) < 1 {
+		return Response{}, ErrMalformedFrame
+	}
+
+	return Response{
+		Status:  Status(frame[0]),
+		Payload: frame[1:],
+	}, nil
+}
+
+func ReadFrame(r io.Reader, buf []byte) ([]byte, error) {
+	var header [HeaderSize]byte
+
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return buf, err
+	}
+
+	size := int(binary.BigEndian.Uint32(header[:]))
+	if size > MaxFrameSize {
+		return buf, ErrFrameTooLarge
+	}
+
+	if cap(buf) < size {
+		buf = make([]byte, size)
+	}
+
+	buf = buf[:size]
+
+	_, err = io.ReadFull(r, buf)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return buf, err
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	return append(dst,
+		byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
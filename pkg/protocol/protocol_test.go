@@ -0,0 +1,130 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest(t *testing.T) {
+	requests := []Request{
+		{Op: OpGet, Key: []byte("key")},
+		{Op: OpSet, Key: []byte("key"), Value: []byte("value"), TTL: 90 * time.Second},
+		{Op: OpSet, Key: []byte("key"), Value: []byte{}},
+		{Op: OpSet, Key: []byte("key"), Value: []byte("value"), TTL: NoTTL},
+		{Op: OpDelete, Key: []byte("key")},
+		{Op: OpTTL, Key: []byte("key")},
+	}
+
+	var (
+		buf []byte
+		err error
+	)
+
+	for _, req := range requests {
+		buf, err = AppendRequest(buf, req)
+		require.NoError(t, err)
+	}
+
+	r := bytes.NewReader(buf)
+
+	var frame []byte
+
+	for _, expected := range requests {
+		frame, err = ReadFrame(r, frame)
+		require.NoError(t, err)
+
+		req, err := ParseRequest(frame)
+		require.NoError(t, err)
+		assert.Equal(t, expected.Op, req.Op)
+		assert.Equal(t, expected.Key, req.Key)
+		assert.Equal(t, expected.TTL, req.TTL)
+		assert.Equal(t, len(expected.Value), len(req.Value))
+		assert.Equal(t, string(expected.Value), string(req.Value))
+	}
+
+	_, err = ReadFrame(r, frame)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestAppendRequest_InvalidTTL(t *testing.T) {
+	for _, ttl := range []time.Duration{-2, -time.Second, time.Microsecond, time.Millisecond - 1} {
+		buf, err := AppendRequest(nil, Request{Op: OpSet, Key: []byte("key"), TTL: ttl})
+		assert.Equal(t, ErrInvalidTTL, err, ttl)
+		assert.Empty(t, buf, ttl)
+	}
+}
+
+func TestParseRequest_Malformed(t *testing.T) {
+	testSuites := []struct {
+		frame []byte
+		err   error
+	}{
+		{frame: []byte{byte(OpGet), 0}, err: ErrMalformedFrame},
+		{frame: []byte{byte(OpGet), 0, 4, 'k'}, err: ErrMalformedFrame},
+		{frame: []byte{byte(OpGet), 0, 1, 'k', 'v'}, err: ErrMalformedFrame},
+		{frame: []byte{byte(OpSet), 0, 1, 'k', 0, 0}, err: ErrMalformedFrame},
+		{frame: []byte{42, 0, 1, 'k'}, err: ErrUnknownOp},
+	}
+
+	for _, test := range testSuites {
+		_, err := ParseRequest(test.frame)
+		assert.Equal(t, test.err, err, test.frame)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	buf := AppendResponse(nil, StatusOK, []byte("value"))
+	buf = AppendResponse(buf, StatusNotFound, nil)
+	buf = AppendTTLResponse(buf, time.Minute)
+	buf = AppendTTLResponse(buf, NoTTL)
+
+	r := bytes.NewReader(buf)
+
+	frame, err := ReadFrame(r, nil)
+	require.NoError(t, err)
+
+	resp, err := ParseResponse(frame)
+	require.NoError(t, err)
+	assert.Equal(t, Response{Status: StatusOK, Payload: []byte("value")}, resp)
+
+	frame, err = ReadFrame(r, frame)
+	require.NoError(t, err)
+
+	resp, err = ParseResponse(frame)
+	require.NoError(t, err)
+	assert.Equal(t, StatusNotFound, resp.Status)
+	assert.Empty(t, resp.Payload)
+
+	frame, err = ReadFrame(r, frame)
+	require.NoError(t, err)
+
+	resp, err = ParseResponse(frame)
+	require.NoError(t, err)
+
+	ttl, err := resp.TTL()
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	frame, err = ReadFrame(r, frame)
+	require.NoError(t, err)
+
+	resp, err = ParseResponse(frame)
+	require.NoError(t, err)
+
+	ttl, err = resp.TTL()
+	require.NoError(t, err)
+	assert.Equal(t, NoTTL, ttl)
+}
+
+func TestReadFrame_Limit(t *testing.T) {
+	_, err := ReadFrame(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}), nil)
+	assert.Equal(t, ErrFrameTooLarge, err)
+
+	_, err = ReadFrame(bytes.NewReader([]byte{0, 0, 0, 4, 1}), nil)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
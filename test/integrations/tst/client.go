@@ -2,13 +2,20 @@ package tst
 
 import (
 	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
 
 type Client interface {
 	Add(key, value string) error
+	AddTTL(key, value string, ttl time.Duration) error
 	Get(key string) (string, error)
+	Delete(key string) error
+	Keys(prefix, cursor string, limit int) ([]string, string, error)
 }
 
 type defaultClient struct {
@@ -28,12 +35,48 @@ func (o *defaultClient) Add(key, value string) error {
 	return err
 }
 
+func (o *defaultClient) AddTTL(key, value string, ttl time.Duration) error {
+	_, err := o.do(fasthttp.MethodPost, "/"+key+"?ttl="+ttl.String(), []byte(value))
+
+	return err
+}
+
 func (o *defaultClient) Get(key string) (string, error) {
 	body, err := o.do(fasthttp.MethodGet, "/"+key)
 
 	return string(body), err
 }
 
+func (o *defaultClient) Delete(key string) error {
+	_, err := o.do(fasthttp.MethodDelete, "/"+key)
+
+	return err
+}
+
+func (o *defaultClient) Keys(prefix, cursor string, limit int) ([]string, string, error) {
+	query := url.Values{}
+	query.Set("prefix", prefix)
+	query.Set("cursor", cursor)
+	query.Set("limit", strconv.Itoa(limit))
+
+	body, err := o.do(fasthttp.MethodGet, "/_keys?"+query.Encode())
+	if err != nil {
+		return nil, "", err
+	}
+
+	var resp struct {
+		Keys   []string `json:"keys"`
+		Cursor string   `json:"cursor"`
+	}
+
+	err = json.Unmarshal(body, &resp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return resp.Keys, resp.Cursor, nil
+}
+
 func (o *defaultClient) do(method string, path string, body ...[]byte) ([]byte, error) {
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
@@ -29,6 +29,25 @@ func (suite *KvsSuite) TestAdd() {
 	suite.Equal(value, storedValue)
 }
 
+func (suite *KvsSuite) TestAddTTL() {
+	key := randomString()
+	value := randomString()
+	ttl := 1 * time.Second
+
+	err := suite.client.AddTTL(key, value, ttl)
+	suite.Require().NoError(err)
+
+	storedValue, err := suite.client.Get(key)
+	suite.Require().NoError(err)
+	suite.Equal(value, storedValue)
+
+	time.Sleep(2 * ttl)
+
+	_, err = suite.client.Get(key)
+	suite.Require().Error(err)
+	suite.EqualError(err, ErrNotFound.Error())
+}
+
 func (suite *KvsSuite) TestGetTwice() {
 	key := randomString()
 	value := randomString()
@@ -52,6 +71,55 @@ func (suite *KvsSuite) TestGetNotFound() {
 	suite.Empty(storedValue)
 }
 
+func (suite *KvsSuite) TestDelete() {
+	key := randomString()
+	value := randomString()
+
+	err := suite.client.Add(key, value)
+	suite.Require().NoError(err)
+
+	err = suite.client.Delete(key)
+	suite.Require().NoError(err)
+
+	_, err = suite.client.Get(key)
+	suite.Require().Error(err)
+	suite.EqualError(err, ErrNotFound.Error())
+
+	err = suite.client.Delete(key)
+	suite.Require().Error(err)
+	suite.EqualError(err, ErrNotFound.Error())
+}
+
+func (suite *KvsSuite) TestKeys() {
+	prefix := randomString()
+	keys := []string{prefix + "-1", prefix + "-2", prefix + "-3"}
+
+	for _, key := range keys {
+		err := suite.client.Add(key, randomString())
+		suite.Require().NoError(err)
+	}
+
+	var (
+		storedKeys []string
+		cursor     = "0"
+	)
+
+	for {
+		page, next, err := suite.client.Keys("/"+prefix, cursor, 2)
+		suite.Require().NoError(err)
+
+		storedKeys = append(storedKeys, page...)
+
+		if next == "0" {
+			break
+		}
+
+		cursor = next
+	}
+
+	suite.ElementsMatch([]string{"/" + keys[0], "/" + keys[1], "/" + keys[2]}, storedKeys)
+}
+
 func randomString() string {
 	return strconv.FormatInt(1000000+rand.Int63(), 16)
 }